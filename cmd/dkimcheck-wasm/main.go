@@ -0,0 +1,83 @@
+//go:build js && wasm
+
+// Command dkimcheck-wasm exposes the configuration checker to JavaScript.
+// Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o dkimcheck.wasm ./cmd/dkimcheck-wasm
+//
+// and load it with wasm_exec.js from the Go distribution. It registers
+//
+//	dkimcheck(text, {module: "dkim_signing", strict: false})
+//
+// which parses text as dkim_signing.conf, or dkim.conf if module is "dkim",
+// and returns {errors, warnings}. Every entry has code, severity, file,
+// line, column and message. Includes are not read; try includes are reported as skipped.
+package main
+
+import (
+	"strings"
+	"syscall/js"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+func main() {
+	js.Global().Set("dkimcheck", js.FuncOf(check))
+	select {}
+}
+
+func check(_ js.Value, args []js.Value) any {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return map[string]any{"errors": []any{message(dkim.Diagnostic{Severity: dkim.SeverityError, Message: "dkimcheck expects the configuration text"})}}
+	}
+	module, strict := "dkim_signing", false
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		if m := args[1].Get("module"); m.Type() == js.TypeString {
+			module = m.String()
+		}
+		strict = args[1].Get("strict").Truthy()
+	}
+
+	var warnings []any
+	opts := []dkim.Option{
+		dkim.WithRecovery(),
+		dkim.WithFilename(module + ".conf"),
+		dkim.WithWarningHandler(func(w dkim.Warning) {
+			warnings = append(warnings, message(w.Diagnostic()))
+		}),
+	}
+	if strict {
+		opts = append(opts, dkim.WithStrict())
+	}
+
+	var err error
+	r := strings.NewReader(args[0].String())
+	if module == "dkim" {
+		_, err = dkim.ParseDKIMConf(r, opts...)
+	} else {
+		var conf *dkim.DKIMSigningConf
+		conf, err = dkim.ParseDKIMSigningConf(r, opts...)
+		if conf != nil {
+			for _, w := range dkim.CheckDelegation(dkim.EffectiveSigningConf{Conf: conf}) {
+				warnings = append(warnings, message(w.Finding().Diagnostic()))
+			}
+		}
+	}
+	var errs []any
+	for _, d := range dkim.ErrorDiagnostics(err) {
+		errs = append(errs, message(d))
+	}
+	return map[string]any{"errors": errs, "warnings": warnings}
+}
+
+func message(d dkim.Diagnostic) map[string]any {
+	return map[string]any{
+		"code":     string(d.Code),
+		"severity": string(d.Severity),
+		"file":     d.File,
+		"line":     d.Line,
+		"column":   d.Column,
+		"domain":   d.Domain,
+		"message":  d.Message,
+	}
+}
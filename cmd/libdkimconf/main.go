@@ -0,0 +1,64 @@
+//go:build cgo
+
+// Command libdkimconf builds the parser as a C shared library for tooling
+// written in other languages:
+//
+//	go build -buildmode=c-shared -o libdkimconf.so ./cmd/libdkimconf
+//
+// ParseSigningConfJSON takes the text of a dkim_signing.conf and returns a
+// JSON object {"conf": ..., "errors": [...], "warnings": [...]}, where conf
+// is the parsed DKIMSigningConf, partial if there are errors, and errors and
+// warnings are Diagnostic values with code, severity, position and message. Includes are read from the
+// host filesystem. The result must be released with FreeString.
+//
+// From Python:
+//
+//	lib = ctypes.CDLL("./libdkimconf.so")
+//	lib.ParseSigningConfJSON.restype = ctypes.c_void_p
+//	ptr = lib.ParseSigningConfJSON(text.encode())
+//	result = json.loads(ctypes.string_at(ptr))
+//	lib.FreeString(ptr)
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+type result struct {
+	Conf     *dkim.DKIMSigningConf `json:"conf"`
+	Errors   []dkim.Diagnostic     `json:"errors"`
+	Warnings []dkim.Diagnostic     `json:"warnings"`
+}
+
+//export ParseSigningConfJSON
+func ParseSigningConfJSON(input *C.char) *C.char {
+	conf, err := dkim.ParseDKIMSigningConf(strings.NewReader(C.GoString(input)), dkim.WithRecovery())
+	res := result{Conf: conf, Errors: []dkim.Diagnostic{}, Warnings: []dkim.Diagnostic{}}
+	res.Errors = append(res.Errors, dkim.ErrorDiagnostics(err)...)
+	if conf != nil {
+		for _, w := range conf.Warnings {
+			res.Warnings = append(res.Warnings, w.Diagnostic())
+		}
+	}
+	out, err := json.Marshal(res)
+	if err != nil {
+		out, _ = json.Marshal(result{Errors: dkim.ErrorDiagnostics(err), Warnings: []dkim.Diagnostic{}})
+	}
+	return C.CString(string(out))
+}
+
+//export FreeString
+func FreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
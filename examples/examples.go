@@ -0,0 +1,96 @@
+// Package examples embeds the example rspamd configurations of this
+// repository, so tests and documentation tools can load known-good configs
+// by name. Each example holds dkim.conf, dkim_signing.conf and the map files
+// of its maps.d directory.
+package examples
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+//go:embed 1 2 3
+var files embed.FS
+
+// Example is an embedded configuration. Versions lists the rspamd release
+// series it is known to work with, such as "3.x".
+type Example struct {
+	Name        string
+	Description string
+	Versions    []string
+	dir         string
+}
+
+var registry = []Example{
+	{
+		Name:        "signed-domains-map",
+		Description: "Keys and selectors from maps (path_map, selector_map), signing by the From header domain without eSLD reduction.",
+		Versions:    []string{"2.x", "3.x"},
+		dir:         "1",
+	},
+	{
+		Name:        "wildcard-rule",
+		Description: "Selector and path maps with a global selector and path and a \"*\" domain rule as fallback.",
+		Versions:    []string{"2.x", "3.x"},
+		dir:         "2",
+	},
+	{
+		Name:        "templated-path",
+		Description: "A $domain key path template with a selector map and try_fallback; dkim.conf disables the dkim module.",
+		Versions:    []string{"3.x"},
+		dir:         "3",
+	},
+}
+
+// All returns every example in registry order.
+func All() []Example {
+	return append([]Example(nil), registry...)
+}
+
+// Get returns the example called name.
+func Get(name string) (Example, bool) {
+	for _, e := range registry {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Example{}, false
+}
+
+// ForVersion returns the examples known to work with the rspamd version,
+// given as a series such as "3.x" or a release such as "3.8.4".
+func ForVersion(version string) []Example {
+	series, _, _ := strings.Cut(version, ".")
+	var out []Example
+	for _, e := range registry {
+		for _, v := range e.Versions {
+			if v == version || strings.TrimSuffix(v, ".x") == series {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// FS returns the files of the example, with dkim.conf and
+// dkim_signing.conf at the top. It fails for an Example not returned by
+// this package, such as the zero value.
+func (e Example) FS() (fs.FS, error) {
+	if e.dir == "" {
+		return nil, fmt.Errorf("examples: %q is not an embedded example", e.Name)
+	}
+	return fs.Sub(files, e.dir)
+}
+
+// ReadFile returns the content of a file of the example, such as
+// "dkim_signing.conf" or "maps.d/dkim_selectors.map".
+func (e Example) ReadFile(name string) ([]byte, error) {
+	fsys, err := e.FS()
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(fsys, name)
+}
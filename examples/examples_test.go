@@ -0,0 +1,66 @@
+package examples
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExamplesParse(t *testing.T) {
+	all := All()
+	require.Len(t, all, 3)
+	for _, e := range all {
+		got, ok := Get(e.Name)
+		require.True(t, ok)
+		require.Equal(t, e.Description, got.Description)
+
+		data, err := e.ReadFile("dkim.conf")
+		require.NoError(t, err, e.Name)
+		_, err = dkim.ParseDKIMConf(bytes.NewReader(data))
+		require.NoError(t, err, e.Name)
+
+		data, err = e.ReadFile("dkim_signing.conf")
+		require.NoError(t, err, e.Name)
+		_, err = dkim.ParseDKIMSigningConf(bytes.NewReader(data))
+		require.NoError(t, err, e.Name)
+
+		fsys, err := e.FS()
+		require.NoError(t, err)
+		maps, err := fs.Glob(fsys, "maps.d/*.map")
+		require.NoError(t, err)
+		require.NotEmpty(t, maps, e.Name)
+		for _, name := range maps {
+			data, err := e.ReadFile(name)
+			require.NoError(t, err)
+			_, err = dkim.ParseMapEntries(bytes.NewReader(data))
+			require.NoError(t, err, name)
+		}
+	}
+	missing, ok := Get("missing")
+	require.False(t, ok)
+	_, err := missing.FS()
+	require.EqualError(t, err, `examples: "" is not an embedded example`)
+	_, err = Example{Name: "custom"}.ReadFile("dkim.conf")
+	require.EqualError(t, err, `examples: "custom" is not an embedded example`)
+}
+
+func TestForVersion(t *testing.T) {
+	names := func(list []Example) []string {
+		var out []string
+		for _, e := range list {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+	require.Equal(t, []string{"signed-domains-map", "wildcard-rule", "templated-path"}, names(ForVersion("3.8.4")))
+	require.Equal(t, []string{"signed-domains-map", "wildcard-rule"}, names(ForVersion("2.x")))
+	require.Empty(t, ForVersion("1.9"))
+
+	e, _ := Get("templated-path")
+	data, err := e.ReadFile("maps.d/dkim_selectors.map")
+	require.NoError(t, err)
+	require.Contains(t, string(data), "test.mailer.com")
+}
@@ -0,0 +1,176 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Algorithm is a DKIM signing algorithm as written in the a= tag.
+type Algorithm string
+
+const (
+	RSASHA256     Algorithm = "rsa-sha256"
+	Ed25519SHA256 Algorithm = "ed25519-sha256"
+)
+
+// KeyAlgorithm returns the algorithm signatures made with key use.
+func KeyAlgorithm(key crypto.Signer) (Algorithm, error) {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return RSASHA256, nil
+	case ed25519.PrivateKey:
+		return Ed25519SHA256, nil
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// AlgorithmPolicy lists the algorithms each domain must be signed with. A
+// domain is signed with exactly the listed algorithms: []Algorithm{RSASHA256}
+// allows RSA only, []Algorithm{Ed25519SHA256, RSASHA256} requires dual
+// signing.
+type AlgorithmPolicy struct {
+	// Default applies to domains without an entry in Domains. If nil, such
+	// domains are not checked.
+	Default []Algorithm
+	Domains map[string][]Algorithm
+}
+
+// For returns the algorithms required for domain, or nil if the policy does
+// not cover it. Domains keys are matched the way Resolve matches domain
+// rules, so when several keys name the same domain the result does not
+// depend on map order.
+func (p AlgorithmPolicy) For(domain string) []Algorithm {
+	if algs, ok := lookup(p.Domains, domain); ok {
+		return algs
+	}
+	return p.Default
+}
+
+// AlgorithmViolation is a domain whose keys do not match the algorithms
+// required by an AlgorithmPolicy. Problem describes the mismatch.
+type AlgorithmViolation struct {
+	Domain  string
+	Want    []Algorithm
+	Have    []Algorithm
+	Problem string
+}
+
+// Plan returns the keys used to sign mail for domain, with their Algorithm
+// set, after checking them against policy. It fails if a key cannot be read,
+// uses an algorithm the policy does not allow, or a required algorithm has
+// no key.
+func (e EffectiveSigningConf) Plan(domain string, policy AlgorithmPolicy) ([]SigningKey, error) {
+	keys, v := e.plan(domain, policy)
+	if v != nil {
+		return nil, fmt.Errorf("domain %q: %s", v.Domain, v.Problem)
+	}
+	return keys, nil
+}
+
+func (e EffectiveSigningConf) plan(domain string, policy AlgorithmPolicy) ([]SigningKey, *AlgorithmViolation) {
+	want := policy.For(domain)
+	keys := e.ResolveAll(domain)
+	if len(keys) == 0 {
+		if len(want) == 0 {
+			return nil, nil
+		}
+		return nil, &AlgorithmViolation{Domain: domain, Want: want, Problem: "no signing key"}
+	}
+	for i := range keys {
+		k := &keys[i]
+		signer, err := e.signer(*k)
+		if err != nil {
+			return nil, &AlgorithmViolation{Domain: k.Domain, Want: want, Problem: fmt.Sprintf("read key %q: %v", k.Path, err)}
+		}
+		if k.Algorithm, err = KeyAlgorithm(signer); err != nil {
+			return nil, &AlgorithmViolation{Domain: k.Domain, Want: want, Problem: err.Error()}
+		}
+	}
+	key := keys[0]
+	if want == nil {
+		return keys, nil
+	}
+
+	have := make([]Algorithm, len(keys))
+	for i, k := range keys {
+		have[i] = k.Algorithm
+	}
+	allowed := make(map[Algorithm]bool, len(want))
+	for _, alg := range want {
+		allowed[alg] = true
+	}
+	for _, k := range keys {
+		if !allowed[k.Algorithm] {
+			return nil, &AlgorithmViolation{Domain: key.Domain, Want: want, Have: have,
+				Problem: fmt.Sprintf("selector %q uses %s, which the policy does not allow", k.Selector, k.Algorithm)}
+		}
+		delete(allowed, k.Algorithm)
+	}
+	if len(allowed) > 0 {
+		var missing []string
+		for _, alg := range want {
+			if allowed[alg] {
+				missing = append(missing, string(alg))
+			}
+		}
+		return nil, &AlgorithmViolation{Domain: key.Domain, Want: want, Have: have,
+			Problem: "no key for " + strings.Join(missing, ", ")}
+	}
+	return keys, nil
+}
+
+// CheckAlgorithmPolicy reports every domain covered by policy whose signing
+// keys violate it. The domains checked are those named in policy.Domains and,
+// if policy.Default is set, those with a domain rule or map entry.
+func CheckAlgorithmPolicy(eff EffectiveSigningConf, policy AlgorithmPolicy) []AlgorithmViolation {
+	domains := make(map[string]bool)
+	for domain := range policy.Domains {
+		domains[domainKey(domain)] = true
+	}
+	if policy.Default != nil {
+		for domain := range configuredDomains(eff) {
+			domains[domain] = true
+		}
+	}
+
+	var out []AlgorithmViolation
+	for domain := range domains {
+		if _, v := eff.plan(domain, policy); v != nil {
+			out = append(out, *v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Domain < out[j].Domain })
+	return out
+}
+
+// Domains returns the domains named by domain rules (other than "*") and map
+// entries of e, lowercased and sorted.
+func (e EffectiveSigningConf) Domains() []string {
+	return sortedKeys(configuredDomains(e))
+}
+
+// configuredDomains returns the normalized domains named by domain rules
+// (other than "*") and map entries of eff.
+func configuredDomains(eff EffectiveSigningConf) map[string]bool {
+	domains := make(map[string]bool)
+	if eff.Conf != nil {
+		for key := range eff.Conf.Domain {
+			if key != "*" {
+				domains[domainKey(key)] = true
+			}
+		}
+	}
+	if eff.Maps != nil {
+		for _, m := range []map[string]string{eff.Maps.Selectors, eff.Maps.Paths, eff.Maps.SignedDomains} {
+			for key := range m {
+				domains[domainKey(key)] = true
+			}
+		}
+	}
+	return domains
+}
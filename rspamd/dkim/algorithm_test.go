@@ -0,0 +1,98 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlgorithmPolicyFor(t *testing.T) {
+	rsaOnly := []Algorithm{RSASHA256}
+	edOnly := []Algorithm{Ed25519SHA256}
+	dual := []Algorithm{Ed25519SHA256, RSASHA256}
+	policy := AlgorithmPolicy{Default: rsaOnly, Domains: map[string][]Algorithm{
+		"Example.com":  edOnly,
+		"example.com.": dual,
+		"EXAMPLE.COM":  rsaOnly,
+		"münchen.de":   edOnly,
+		"@tenant.org":  dual,
+	}}
+	for i := 0; i < 20; i++ {
+		// No key is written as example.com, so the first sorted one wins.
+		require.Equal(t, rsaOnly, policy.For("example.com"))
+	}
+	require.Equal(t, edOnly, policy.For("Example.com"))
+	require.Equal(t, edOnly, policy.For("xn--mnchen-3ya.de"))
+	require.Equal(t, edOnly, policy.For("MÜNCHEN.de."))
+	require.Equal(t, dual, policy.For("tenant.org"))
+	require.Equal(t, rsaOnly, policy.For("other.org"))
+
+	policy.Domains["example.com"] = dual
+	require.Equal(t, dual, policy.For("EXAMPLE.com"))
+}
+
+func TestAlgorithmPolicy(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsaPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rsa.key"), rsaPem, 0o600))
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ed.key"), edPem, 0o600))
+
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "rsa", Path: filepath.Join(dir, "rsa.key")},
+				"b.com": {Selector: "ed", Path: filepath.Join(dir, "ed.key")},
+				"c.com": {Selector: "rsa", Path: filepath.Join(dir, "rsa.key")},
+			},
+		},
+	}
+	policy := AlgorithmPolicy{
+		Default: []Algorithm{RSASHA256},
+		Domains: map[string][]Algorithm{
+			"C.com": {Ed25519SHA256, RSASHA256},
+			"d.com": {RSASHA256},
+		},
+	}
+
+	keys, err := eff.Plan("a.com", policy)
+	require.NoError(t, err)
+	require.Equal(t, []SigningKey{{Domain: "a.com", Selector: "rsa", Path: filepath.Join(dir, "rsa.key"), Source: "domain", Algorithm: RSASHA256}}, keys)
+
+	_, err = eff.Plan("b.com", policy)
+	require.EqualError(t, err, `domain "b.com": selector "ed" uses ed25519-sha256, which the policy does not allow`)
+
+	keys, err = eff.Plan("b.com", AlgorithmPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, Ed25519SHA256, keys[0].Algorithm)
+
+	require.Equal(t, []AlgorithmViolation{
+		{Domain: "b.com", Want: []Algorithm{RSASHA256}, Have: []Algorithm{Ed25519SHA256}, Problem: `selector "ed" uses ed25519-sha256, which the policy does not allow`},
+		{Domain: "c.com", Want: []Algorithm{Ed25519SHA256, RSASHA256}, Have: []Algorithm{RSASHA256}, Problem: "no key for ed25519-sha256"},
+		{Domain: "d.com", Want: []Algorithm{RSASHA256}, Problem: "no signing key"},
+	}, CheckAlgorithmPolicy(eff, policy))
+	require.Equal(t, []string{"a.com", "b.com", "c.com"}, eff.Domains())
+
+	eff.Conf.Domain["c.com"] = DomainRule{Selectors: []DomainSelector{
+		{Selector: "ed", Path: filepath.Join(dir, "ed.key")},
+		{Selector: "rsa", Path: filepath.Join(dir, "rsa.key")},
+	}}
+	keys, err = eff.Plan("c.com", policy)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, Ed25519SHA256, keys[0].Algorithm)
+	require.Equal(t, RSASHA256, keys[1].Algorithm)
+}
@@ -0,0 +1,65 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrChangeRejected is returned by ApplyChangeSet when a hook rejects the
+// change set. The hooks in this package wrap it.
+var ErrChangeRejected = errors.New("change rejected")
+
+// ChangeSet is a set of file changes about to be applied, with what approval
+// hooks need to judge it. Time is when it is applied; ApplyChangeSet sets it
+// to the current time if it is zero.
+type ChangeSet struct {
+	Changes []FileChange
+	Ticket  string
+	Author  string
+	Time    time.Time
+}
+
+// ApplyHook checks a change set before anything is written. Returning an
+// error stops the change set from being applied.
+type ApplyHook func(ChangeSet) error
+
+// ApplyChangeSet runs hooks in order and, if none rejects cs, writes its
+// changes to sink as Apply does.
+func ApplyChangeSet(sink Sink, cs ChangeSet, hooks ...ApplyHook) (int, error) {
+	if cs.Time.IsZero() {
+		cs.Time = time.Now()
+	}
+	for _, hook := range hooks {
+		if err := hook(cs); err != nil {
+			return 0, err
+		}
+	}
+	return Apply(sink, cs.Changes)
+}
+
+// RequireTicket rejects change sets whose Ticket does not match pattern,
+// such as `^OPS-[0-9]+$`.
+func RequireTicket(pattern *regexp.Regexp) ApplyHook {
+	return func(cs ChangeSet) error {
+		if cs.Ticket == "" {
+			return fmt.Errorf("%w: no ticket reference", ErrChangeRejected)
+		}
+		if !pattern.MatchString(cs.Ticket) {
+			return fmt.Errorf("%w: ticket %q does not match %s", ErrChangeRejected, cs.Ticket, pattern)
+		}
+		return nil
+	}
+}
+
+// Freeze rejects change sets applied from start up to, but not including,
+// end.
+func Freeze(start, end time.Time) ApplyHook {
+	return func(cs ChangeSet) error {
+		if !cs.Time.Before(start) && cs.Time.Before(end) {
+			return fmt.Errorf("%w: change freeze until %s", ErrChangeRejected, end.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
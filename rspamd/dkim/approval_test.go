@@ -0,0 +1,43 @@
+package dkim
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyChangeSet(t *testing.T) {
+	freezeStart := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+	freezeEnd := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	hooks := []ApplyHook{RequireTicket(regexp.MustCompile(`^OPS-[0-9]+$`)), Freeze(freezeStart, freezeEnd)}
+	changes := []FileChange{{Name: "maps/selectors.map", Data: []byte("a.com s2\n")}}
+
+	dir := t.TempDir()
+	_, err := ApplyChangeSet(DirSink(dir), ChangeSet{Changes: changes, Time: freezeStart.AddDate(0, 0, -1)}, hooks...)
+	require.ErrorIs(t, err, ErrChangeRejected)
+	require.EqualError(t, err, "change rejected: no ticket reference")
+
+	_, err = ApplyChangeSet(DirSink(dir), ChangeSet{Changes: changes, Ticket: "ops-1"}, hooks...)
+	require.EqualError(t, err, `change rejected: ticket "ops-1" does not match ^OPS-[0-9]+$`)
+
+	_, err = ApplyChangeSet(DirSink(dir), ChangeSet{Changes: changes, Ticket: "OPS-7", Time: freezeStart.Add(time.Hour)}, hooks...)
+	require.EqualError(t, err, "change rejected: change freeze until 2025-01-06T00:00:00Z")
+	require.NoDirExists(t, dir+"/maps")
+
+	custom := func(cs ChangeSet) error {
+		if cs.Author == "" {
+			return errors.New("anonymous change")
+		}
+		return nil
+	}
+	_, err = ApplyChangeSet(DirSink(dir), ChangeSet{Changes: changes, Ticket: "OPS-7", Time: freezeEnd}, append(hooks, custom)...)
+	require.EqualError(t, err, "anonymous change")
+
+	n, err := ApplyChangeSet(DirSink(dir), ChangeSet{Changes: changes, Ticket: "OPS-7", Author: "ops", Time: freezeEnd}, append(hooks, custom)...)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.FileExists(t, dir+"/maps/selectors.map")
+}
@@ -0,0 +1,248 @@
+//go:build !dkimconf_noarchive
+
+package dkim
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() { enableFeature(FeatureArchive) }
+
+// OpenArchive reads a .zip, .tar, .tar.gz or .tgz archive of an rspamd
+// configuration directory into memory and returns it as a filesystem, for
+// use with WithFS. With WithLimits, the unpacked files together may not
+// exceed InputSize bytes nor number more than MapEntries.
+func OpenArchive(name string, opts ...Option) (fs.FS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadArchive(f, name, opts...)
+}
+
+// ReadArchive is like OpenArchive but reads the archive from r. The format
+// is chosen from the extension of name.
+func ReadArchive(r io.Reader, name string, opts ...Option) (fs.FS, error) {
+	a := &archiveReader{limits: newParseOptions(opts).limits}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		var read int64
+		data, err := io.ReadAll(newLimitReader(r, &read, a.limits.InputSize))
+		if err != nil {
+			return nil, fmt.Errorf("read zip %q: %w", name, err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("read zip %q: %w", name, err)
+		}
+		fsys, err := a.readZip(zr)
+		if err != nil {
+			return nil, fmt.Errorf("read zip %q: %w", name, err)
+		}
+		return fsys, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read gzip %q: %w", name, err)
+		}
+		defer gz.Close()
+		return a.readTar(gz, name)
+	case strings.HasSuffix(lower, ".tar"):
+		return a.readTar(r, name)
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", name)
+	}
+}
+
+// archiveReader unpacks archives within limits: InputSize bounds the bytes
+// unpacked and MapEntries the number of entries.
+type archiveReader struct {
+	limits  Limits
+	read    int64
+	entries int
+}
+
+func (a *archiveReader) readZip(zr *zip.Reader) (fs.FS, error) {
+	fsys := memFS{}
+	for _, f := range zr.File {
+		if err := a.entry(); err != nil {
+			return nil, err
+		}
+		p := path.Clean(strings.TrimPrefix(f.Name, "/"))
+		if !f.Mode().IsRegular() || !fs.ValidPath(p) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := a.readEntry(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		fsys[p] = &memFile{name: path.Base(p), data: data, mode: f.Mode(), modTime: f.Modified}
+	}
+	return fsys, nil
+}
+
+func (a *archiveReader) readTar(r io.Reader, name string) (fs.FS, error) {
+	fsys := memFS{}
+	// Bound the unpacked stream too, so headers without data count.
+	var read int64
+	tr := tar.NewReader(newLimitReader(r, &read, a.limits.InputSize))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err == nil {
+			err = a.entry()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar %q: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		p := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(p) {
+			continue
+		}
+		data, err := a.readEntry(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read tar %q: %w", name, err)
+		}
+		fsys[p] = &memFile{name: path.Base(p), data: data, mode: hdr.FileInfo().Mode(), modTime: hdr.ModTime}
+	}
+}
+
+// entry counts an entry of the archive against MapEntries.
+func (a *archiveReader) entry() error {
+	a.entries++
+	if max := a.limits.MapEntries; max > 0 && a.entries > max {
+		return fmt.Errorf("%w: more than %d archive entries", ErrLimitExceeded, max)
+	}
+	return nil
+}
+
+// readEntry reads the content of an entry, failing once the entries read
+// so far together exceed InputSize.
+func (a *archiveReader) readEntry(r io.Reader) ([]byte, error) {
+	max := a.limits.InputSize
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max-a.read+1))
+	a.read += int64(len(data))
+	if err == nil && a.read > max {
+		err = fmt.Errorf("%w: archive contents larger than %d bytes", ErrLimitExceeded, max)
+	}
+	return data, err
+}
+
+// memFS is an in-memory filesystem of regular files keyed by slash
+// separated path. Directories are implied by the file paths.
+type memFS map[string]*memFile
+
+type memFile struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := m[name]; ok {
+		return &openMemFile{memFile: f, r: bytes.NewReader(f.data)}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for p, f := range m {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		rest := p[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			dir := rest[:i]
+			if !seen[dir] {
+				seen[dir] = true
+				entries = append(entries, fs.FileInfoToDirEntry(&memFile{name: dir, mode: fs.ModeDir | 0o755}))
+			}
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(f))
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &memDir{memFile: &memFile{name: path.Base(name), mode: fs.ModeDir | 0o755}, entries: entries}, nil
+}
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Size() int64        { return int64(len(f.data)) }
+func (f *memFile) Mode() fs.FileMode  { return f.mode }
+func (f *memFile) ModTime() time.Time { return f.modTime }
+func (f *memFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *memFile) Sys() any           { return nil }
+
+type openMemFile struct {
+	*memFile
+	r *bytes.Reader
+}
+
+func (f *openMemFile) Stat() (fs.FileInfo, error) { return f.memFile, nil }
+func (f *openMemFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *openMemFile) Close() error               { return nil }
+
+type memDir struct {
+	*memFile
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.memFile, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
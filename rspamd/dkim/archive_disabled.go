@@ -0,0 +1,19 @@
+//go:build dkimconf_noarchive
+
+package dkim
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// OpenArchive fails in builds with the dkimconf_noarchive tag.
+func OpenArchive(name string, opts ...Option) (fs.FS, error) {
+	return nil, fmt.Errorf("open %q: %w", name, ErrFeatureDisabled)
+}
+
+// ReadArchive fails in builds with the dkimconf_noarchive tag.
+func ReadArchive(r io.Reader, name string, opts ...Option) (fs.FS, error) {
+	return nil, fmt.Errorf("read %q: %w", name, ErrFeatureDisabled)
+}
@@ -0,0 +1,15 @@
+//go:build dkimconf_noarchive
+
+package dkim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveDisabled(t *testing.T) {
+	require.False(t, HasFeature(FeatureArchive))
+	_, err := OpenArchive("conf.tar.gz")
+	require.ErrorIs(t, err, ErrFeatureDisabled)
+}
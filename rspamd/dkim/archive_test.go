@@ -0,0 +1,118 @@
+//go:build !dkimconf_noarchive
+
+package dkim
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+var archiveFiles = map[string]string{
+	"etc/rspamd/local.d/dkim_signing.conf":    `selector = "archived"; .include(glob=true) "parts/*.inc"`,
+	"etc/rspamd/local.d/parts/a.inc":          `use_domain = "header";`,
+	"etc/rspamd/local.d/maps.d/selectors.map": "example.com s1\n",
+	"etc/rspamd/override.d/dkim_signing.conf": `selector = "override";`,
+}
+
+func TestReadArchiveTarGz(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range archiveFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	fsys, err := ReadArchive(&buf, "rspamd.tar.gz")
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(fsys, "etc/rspamd/local.d/maps.d/selectors.map"))
+	checkArchive(t, fsys)
+}
+
+func TestReadArchiveZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range archiveFiles {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	fsys, err := ReadArchive(&buf, "rspamd.zip")
+	require.NoError(t, err)
+	checkArchive(t, fsys)
+
+	_, err = ReadArchive(&buf, "rspamd.rar")
+	require.Error(t, err)
+}
+
+func checkArchive(t *testing.T, fsys fs.FS) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, "etc/rspamd/local.d/maps.d/selectors.map")
+	require.NoError(t, err)
+	require.Equal(t, "example.com s1\n", string(data))
+
+	entries, err := fs.ReadDir(fsys, "etc/rspamd")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`.include "$LOCAL_CONFDIR/local.d/dkim_signing.conf"`), WithFS(fsys))
+	require.NoError(t, err)
+	require.Equal(t, "archived", conf.Selector)
+	require.Equal(t, "header", conf.UseDomain)
+	require.Equal(t, "/etc/rspamd/local.d/parts/a.inc", conf.Includes[1].Path)
+}
+
+func TestReadArchiveLimits(t *testing.T) {
+	// A gzip bomb: a small archive holding one large, compressible file.
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	big := strings.Repeat("x", 1<<20)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "big.conf", Mode: 0o644, Size: int64(len(big)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte(big))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	bomb := buf.Bytes()
+	require.Less(t, len(bomb), 8<<10)
+
+	_, err = ReadArchive(bytes.NewReader(bomb), "bomb.tgz", WithLimits(Limits{InputSize: 64 << 10}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	_, err = ReadArchive(bytes.NewReader(bomb), "bomb.tgz")
+	require.NoError(t, err)
+
+	buf.Reset()
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.conf", "b.conf", "c.conf"} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(big))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	zipped := buf.Bytes()
+
+	_, err = ReadArchive(bytes.NewReader(zipped), "bomb.zip", WithLimits(Limits{InputSize: 2 << 20}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	_, err = ReadArchive(bytes.NewReader(zipped), "bomb.zip", WithLimits(Limits{MapEntries: 2}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	fsys, err := ReadArchive(bytes.NewReader(zipped), "bomb.zip", WithLimits(Limits{InputSize: 4 << 20, MapEntries: 3}))
+	require.NoError(t, err)
+	data, err := fs.ReadFile(fsys, "c.conf")
+	require.NoError(t, err)
+	require.Len(t, data, len(big))
+}
@@ -0,0 +1,52 @@
+package dkim
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+)
+
+// SetCacheOptions rewrites dkim_cache_size and dkim_cache_expire in root, a
+// dkim.conf parsed with Parse, keeping its other keys and comments for
+// Marshal. Options already set keep their place; new ones are added at the
+// end. A zero size or expiry removes the option, leaving rspamd's default.
+func SetCacheOptions(root *ucl.Value, size int64, expire time.Duration) error {
+	if root == nil || root.Kind != ucl.Object {
+		return fmt.Errorf("set cache options: not an object")
+	}
+	if len(root.Keys) == 1 && root.Keys[0] == "dkim" && root.Fields["dkim"].Kind == ucl.Object {
+		root = root.Fields["dkim"]
+	}
+	if size < 0 || expire < 0 {
+		return fmt.Errorf("set cache options: negative size or expiry")
+	}
+	setScalar(root, "dkim_cache_size", size != 0, &ucl.Value{Kind: ucl.Int, Raw: strconv.FormatInt(size, 10), Int: size})
+	setScalar(root, "dkim_cache_expire", expire != 0, &ucl.Value{Kind: ucl.Time, Raw: formatDuration(expire), Time: expire})
+	return nil
+}
+
+// setScalar sets key of obj to val, keeping the position and comments of
+// the value it replaces, or removes key if set is false.
+func setScalar(obj *ucl.Value, key string, set bool, val *ucl.Value) {
+	old, ok := obj.Fields[key]
+	if !set {
+		if ok {
+			delete(obj.Fields, key)
+			for i, k := range obj.Keys {
+				if k == key {
+					obj.Keys = append(obj.Keys[:i:i], obj.Keys[i+1:]...)
+					break
+				}
+			}
+		}
+		return
+	}
+	if ok {
+		val.Pos, val.Comments = old.Pos, old.Comments
+	} else {
+		obj.Keys = append(obj.Keys, key)
+	}
+	obj.Fields[key] = val
+}
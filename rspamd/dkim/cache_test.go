@@ -0,0 +1,40 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOptions(t *testing.T) {
+	input := "# keys cached per worker\ndkim_cache_size = 2k;\ndkim_cache_expire = 1d;\nsign_headers = \"from\";\n"
+	conf, err := ParseDKIMConf(strings.NewReader(input), WithStrict())
+	require.NoError(t, err)
+	require.EqualValues(t, 2000, conf.CacheSize)
+	require.Equal(t, 24*time.Hour, conf.CacheExpire)
+
+	root, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.NoError(t, SetCacheOptions(root, conf.CacheSize*2, 12*time.Hour))
+	out, err := Marshal(root)
+	require.NoError(t, err)
+	require.Equal(t, "# keys cached per worker\ndkim_cache_size = 4000;\ndkim_cache_expire = 12h;\nsign_headers = \"from\";\n", string(out))
+
+	back, err := ParseDKIMConf(strings.NewReader(string(out)))
+	require.NoError(t, err)
+	require.EqualValues(t, 4000, back.CacheSize)
+	require.Equal(t, 12*time.Hour, back.CacheExpire)
+
+	root, err = Parse(strings.NewReader("dkim {\n  dkim_cache_size = 10;\n}\n"))
+	require.NoError(t, err)
+	require.NoError(t, SetCacheOptions(root, 0, time.Minute))
+	out, err = Marshal(root)
+	require.NoError(t, err)
+	require.Equal(t, "dkim {\n  dkim_cache_expire = 1min;\n}\n", string(out))
+
+	_, err = ParseDKIMConf(strings.NewReader("dkim_cache_expire = soon;"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse dkim_cache_expire: invalid duration "soon"`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+}
@@ -0,0 +1,136 @@
+package dkim
+
+import (
+	"errors"
+	"sort"
+)
+
+// Code identifies a kind of diagnostic. Codes are stable across releases so
+// that tools can map them to documentation; a code is never reused for a
+// different problem.
+type Code string
+
+// Parser diagnostics.
+const (
+	CodeSyntax            Code = "DKIMCONF0001"
+	CodeUnterminated      Code = "DKIMCONF0002"
+	CodeInvalidEscape     Code = "DKIMCONF0003"
+	CodeInvalidValue      Code = "DKIMCONF0004"
+	CodeUnknownDirective  Code = "DKIMCONF0005"
+	CodeIncludeParams     Code = "DKIMCONF0006"
+	CodeIncludeUnreadable Code = "DKIMCONF0007"
+	CodeIncludeCycle      Code = "DKIMCONF0008"
+	CodeDuplicateKey      Code = "DKIMCONF0009"
+	CodeUnknownKey        Code = "DKIMCONF0010"
+	CodeLimitExceeded     Code = "DKIMCONF0011"
+	CodeInvalidMapLine    Code = "DKIMCONF0012"
+	CodeInvalidAnnotation Code = "DKIMCONF0013"
+	CodeMapNotLoaded      Code = "DKIMCONF0014"
+	CodeKeyCase           Code = "DKIMCONF0015"
+	CodeInvalidEncoding   Code = "DKIMCONF0016"
+	CodeIgnoredKey        Code = "DKIMCONF0017"
+	CodeDeprecatedKey     Code = "DKIMCONF0018"
+	CodeSuspiciousValue   Code = "DKIMCONF0019"
+)
+
+// Check diagnostics, see Finding.
+const (
+	CodeTenantKey         Code = "DKIMCONF0101"
+	CodeAlgorithm         Code = "DKIMCONF0102"
+	CodeDelegation        Code = "DKIMCONF0103"
+	CodeRotationDue       Code = "DKIMCONF0104"
+	CodeRotationOverdue   Code = "DKIMCONF0105"
+	CodeDuplicateDomain   Code = "DKIMCONF0106"
+	CodeModuleDisabled    Code = "DKIMCONF0107"
+	CodeSignHeaders       Code = "DKIMCONF0108"
+	CodeWhitelistedSigner Code = "DKIMCONF0109"
+)
+
+// Severity is how serious a diagnostic is, using the SARIF level names.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// CodeInfo describes a diagnostic code.
+type CodeInfo struct {
+	Code     Code
+	Severity Severity
+	Title    string
+}
+
+var codes = map[Code]CodeInfo{
+	CodeSyntax:            {CodeSyntax, SeverityError, "Syntax error"},
+	CodeUnterminated:      {CodeUnterminated, SeverityError, "Unterminated string, heredoc or comment"},
+	CodeInvalidEscape:     {CodeInvalidEscape, SeverityError, "Invalid escape sequence"},
+	CodeInvalidValue:      {CodeInvalidValue, SeverityError, "Invalid option value"},
+	CodeUnknownDirective:  {CodeUnknownDirective, SeverityError, "Unknown directive"},
+	CodeIncludeParams:     {CodeIncludeParams, SeverityError, "Invalid include parameters"},
+	CodeIncludeUnreadable: {CodeIncludeUnreadable, SeverityError, "Included file cannot be read"},
+	CodeIncludeCycle:      {CodeIncludeCycle, SeverityError, "Include cycle or nesting too deep"},
+	CodeDuplicateKey:      {CodeDuplicateKey, SeverityWarning, "Repeated key"},
+	CodeUnknownKey:        {CodeUnknownKey, SeverityError, "Unknown key"},
+	CodeLimitExceeded:     {CodeLimitExceeded, SeverityError, "Resource limit exceeded"},
+	CodeInvalidMapLine:    {CodeInvalidMapLine, SeverityError, "Invalid map line"},
+	CodeInvalidAnnotation: {CodeInvalidAnnotation, SeverityError, "Invalid map annotation"},
+	CodeMapNotLoaded:      {CodeMapNotLoaded, SeverityNote, "Map not loaded"},
+	CodeKeyCase:           {CodeKeyCase, SeverityNote, "Key matched ignoring case"},
+	CodeInvalidEncoding:   {CodeInvalidEncoding, SeverityError, "Invalid UTF-8"},
+	CodeIgnoredKey:        {CodeIgnoredKey, SeverityWarning, "Unknown key ignored"},
+	CodeDeprecatedKey:     {CodeDeprecatedKey, SeverityWarning, "Deprecated key"},
+	CodeSuspiciousValue:   {CodeSuspiciousValue, SeverityWarning, "Suspicious value"},
+	CodeTenantKey:         {CodeTenantKey, SeverityError, "Key belongs to another tenant"},
+	CodeAlgorithm:         {CodeAlgorithm, SeverityError, "Signing algorithm policy violated"},
+	CodeDelegation:        {CodeDelegation, SeverityWarning, "Signing domain may not align"},
+	CodeRotationDue:       {CodeRotationDue, SeverityNote, "Selector rotation due"},
+	CodeRotationOverdue:   {CodeRotationOverdue, SeverityWarning, "Selector rotation overdue"},
+	CodeDuplicateDomain:   {CodeDuplicateDomain, SeverityWarning, "Domain listed in different case or with a trailing dot"},
+	CodeModuleDisabled:    {CodeModuleDisabled, SeverityError, "Signing enabled without the dkim module"},
+	CodeSignHeaders:       {CodeSignHeaders, SeverityWarning, "sign_headers not used as configured"},
+	CodeWhitelistedSigner: {CodeWhitelistedSigner, SeverityWarning, "Signing domain is a whitelisted signer"},
+}
+
+// Codes returns every diagnostic code in order.
+func Codes() []CodeInfo {
+	out := make([]CodeInfo, 0, len(codes))
+	for _, info := range codes {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// LookupCode returns the description of code.
+func LookupCode(code Code) (CodeInfo, bool) {
+	info, ok := codes[code]
+	return info, ok
+}
+
+// codedError attaches a Code to an error.
+type codedError struct {
+	code Code
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func withCode(code Code, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// CodeOf returns the diagnostic code of err, or "" if err is not a
+// diagnostic of this package.
+func CodeOf(err error) Code {
+	var ce *codedError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, ErrLimitExceeded):
+		return CodeLimitExceeded
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package dkim
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodes(t *testing.T) {
+	format := regexp.MustCompile(`^DKIMCONF\d{4}$`)
+	list := Codes()
+	require.NotEmpty(t, list)
+	for i, info := range list {
+		require.Regexp(t, format, string(info.Code))
+		require.NotEmpty(t, info.Title)
+		require.Contains(t, []Severity{SeverityError, SeverityWarning, SeverityNote}, info.Severity)
+		if i > 0 {
+			require.Less(t, list[i-1].Code, info.Code)
+		}
+	}
+
+	info, ok := LookupCode(CodeUnknownKey)
+	require.True(t, ok)
+	require.Equal(t, "Unknown key", info.Title)
+	_, ok = LookupCode("DKIMCONF9999")
+	require.False(t, ok)
+}
+
+func TestCodeOf(t *testing.T) {
+	for input, want := range map[string]Code{
+		`selector = "s1`:                                 CodeUnterminated,
+		`selector = "\u12";`:                             CodeInvalidEscape,
+		`selector = ;`:                                   CodeSyntax,
+		`enabled = maybe;`:                               CodeInvalidValue,
+		`.frobnicate "x"`:                                CodeUnknownDirective,
+		`.include(priority=99) "/x.conf"`:                CodeIncludeParams,
+		`.include "/nonexistent/x.conf"`:                 CodeIncludeUnreadable,
+		`selectr = "s1";`:                                CodeUnknownKey,
+		`selector = "` + strings.Repeat("a", 100) + `";`: CodeLimitExceeded,
+	} {
+		_, err := ParseDKIMSigningConf(strings.NewReader(input), WithStrict(), WithLimits(Limits{StringLength: 50}))
+		var pe *ParseError
+		require.True(t, errors.As(err, &pe), input)
+		require.Equal(t, want, pe.Code(), input)
+		require.Equal(t, want, CodeOf(err), input)
+	}
+
+	_, err := ParseDKIMSigningConf(strings.NewReader("selector = \"s1\";\nselector = \"s2\";\n"), WithDuplicateKeys(DuplicateFail))
+	require.Equal(t, CodeDuplicateKey, CodeOf(err))
+	_, err = ParseMapEntries(strings.NewReader("lonely\n"))
+	require.Equal(t, CodeInvalidMapLine, CodeOf(err))
+	require.Equal(t, Code(""), CodeOf(errors.New("other")))
+}
@@ -0,0 +1,202 @@
+package dkim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Committer is a Sink that can write several files all or nothing. Apply
+// uses Commit when the sink supports it.
+type Committer interface {
+	Sink
+	Commit(changes []FileChange) error
+}
+
+// journalName is the file, in the root of a DirSink, that lists the renames
+// of a commit in progress.
+const journalName = ".dkimconf-journal"
+
+// journalEntry is a staged file and the name it is renamed to.
+type journalEntry struct {
+	Temp string `json:"temp"`
+	Name string `json:"name"`
+}
+
+// Commit writes changes so that either all of them or none are visible,
+// even if the process crashes: every file is first written to a temporary
+// file next to its target and synced, then a journal of the renames is
+// synced, and only then are the files renamed into place. A commit
+// interrupted after the journal was written is completed by Recover, which
+// Commit runs first, so a selectors map never refers to a key that was not
+// written.
+func (d DirSink) Commit(changes []FileChange) error {
+	if err := checkWritable("commit changes"); err != nil {
+		return err
+	}
+	if err := d.Recover(); err != nil {
+		return err
+	}
+	entries, err := d.stage(changes)
+	if err != nil {
+		return err
+	}
+	if err := d.writeJournal(entries); err != nil {
+		for _, e := range entries {
+			os.Remove(d.path(e.Temp))
+		}
+		return err
+	}
+	return d.finish(entries)
+}
+
+// Recover completes a commit that was interrupted after its journal was
+// written. It does nothing if no commit was interrupted.
+func (d DirSink) Recover() error {
+	if err := checkWritable("recover commit"); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(d.path(journalName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("read %s: %w", journalName, err)
+	}
+	return d.finish(entries)
+}
+
+// stage writes every change to a synced temporary file. On failure the
+// files staged so far are removed.
+func (d DirSink) stage(changes []FileChange) ([]journalEntry, error) {
+	var entries []journalEntry
+	fail := func(err error) ([]journalEntry, error) {
+		for _, e := range entries {
+			os.Remove(d.path(e.Temp))
+		}
+		return nil, err
+	}
+	for _, c := range changes {
+		if !fs.ValidPath(c.Name) || c.Name == "." || c.Name == journalName {
+			return fail(fmt.Errorf("invalid file name %q", c.Name))
+		}
+		perm := c.Perm
+		if perm == 0 {
+			perm = 0o644
+		}
+		target := d.path(c.Name)
+		if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+			return fail(fmt.Errorf("write %s: is a directory", c.Name))
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return fail(err)
+		}
+		f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp*")
+		if err != nil {
+			return fail(err)
+		}
+		rel, _ := filepath.Rel(string(d), f.Name())
+		entries = append(entries, journalEntry{Temp: filepath.ToSlash(rel), Name: c.Name})
+		if err := writeSynced(f, c.Data, perm); err != nil {
+			return fail(fmt.Errorf("write %s: %w", c.Name, err))
+		}
+	}
+	return entries, nil
+}
+
+// writeJournal atomically writes the journal of entries.
+func (d DirSink) writeJournal(entries []journalEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(d), journalName+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := writeSynced(f, data, 0o600); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), d.path(journalName)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(string(d))
+}
+
+// finish renames the staged files of entries into place and removes the
+// journal. Entries whose temporary file is gone were renamed before.
+func (d DirSink) finish(entries []journalEntry) error {
+	for _, e := range entries {
+		if err := e.check(); err != nil {
+			return fmt.Errorf("read %s: %w", journalName, err)
+		}
+	}
+	dirs := make(map[string]bool)
+	for _, e := range entries {
+		err := os.Rename(d.path(e.Temp), d.path(e.Name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("rename %s: %w", e.Name, err)
+		}
+		dirs[filepath.Dir(d.path(e.Name))] = true
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(d.path(journalName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return syncDir(string(d))
+}
+
+// check rejects entries naming files outside the sink, such as those of a
+// tampered journal, before anything is renamed.
+func (e journalEntry) check() error {
+	for _, name := range []string{e.Temp, e.Name} {
+		if !filepath.IsLocal(filepath.FromSlash(name)) || name == journalName {
+			return fmt.Errorf("invalid file name %q", name)
+		}
+	}
+	return nil
+}
+
+func (d DirSink) path(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+// writeSynced writes data to f, sets its mode, syncs and closes it.
+func writeSynced(f *os.File, data []byte, perm fs.FileMode) error {
+	_, err := f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir makes the renames in dir durable.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
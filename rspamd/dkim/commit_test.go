@@ -0,0 +1,95 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSinkCommit(t *testing.T) {
+	dir := t.TempDir()
+	sink := DirSink(dir)
+	require.NoError(t, sink.Commit([]FileChange{
+		{Name: "keys/a.com.s2.key", Data: []byte("key"), Perm: 0o600},
+		{Name: "maps/selectors.map", Data: []byte("a.com s2\n")},
+	}))
+	data, err := os.ReadFile(filepath.Join(dir, "maps", "selectors.map"))
+	require.NoError(t, err)
+	require.Equal(t, "a.com s2\n", string(data))
+	require.NoFileExists(t, filepath.Join(dir, journalName))
+	requireNoTempFiles(t, dir)
+
+	// A failure while staging leaves the previous files untouched.
+	err = sink.Commit([]FileChange{
+		{Name: "maps/selectors.map", Data: []byte("a.com s3\n")},
+		{Name: "maps"},
+	})
+	require.Error(t, err)
+	data, err = os.ReadFile(filepath.Join(dir, "maps", "selectors.map"))
+	require.NoError(t, err)
+	require.Equal(t, "a.com s2\n", string(data))
+	requireNoTempFiles(t, dir)
+}
+
+func TestDirSinkRecover(t *testing.T) {
+	dir := t.TempDir()
+	sink := DirSink(dir)
+	entries, err := sink.stage([]FileChange{
+		{Name: "keys/a.com.s3.key", Data: []byte("key")},
+		{Name: "maps/selectors.map", Data: []byte("a.com s3\n")},
+	})
+	require.NoError(t, err)
+	require.NoError(t, sink.writeJournal(entries))
+	// Simulate a crash after the first rename.
+	require.NoError(t, os.Rename(sink.path(entries[0].Temp), sink.path(entries[0].Name)))
+	require.NoFileExists(t, filepath.Join(dir, "maps", "selectors.map"))
+
+	require.NoError(t, sink.Recover())
+	data, err := os.ReadFile(filepath.Join(dir, "maps", "selectors.map"))
+	require.NoError(t, err)
+	require.Equal(t, "a.com s3\n", string(data))
+	require.FileExists(t, filepath.Join(dir, "keys", "a.com.s3.key"))
+	require.NoFileExists(t, filepath.Join(dir, journalName))
+	requireNoTempFiles(t, dir)
+}
+
+func TestDirSinkRecoverTamperedJournal(t *testing.T) {
+	outside := t.TempDir()
+	victim := filepath.Join(outside, "victim")
+	require.NoError(t, os.WriteFile(victim, []byte("keep"), 0o644))
+	dir := filepath.Join(outside, "sink")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "staged"), []byte("evil"), 0o644))
+	sink := DirSink(dir)
+
+	for _, entries := range [][]journalEntry{
+		{{Temp: "staged", Name: "../victim"}},
+		{{Temp: "staged", Name: victim}},
+		{{Temp: "../victim", Name: "moved"}},
+		{{Temp: victim, Name: "moved"}},
+		{{Temp: "staged", Name: "a/../../victim"}},
+		{{Temp: "staged", Name: journalName}},
+	} {
+		require.NoError(t, sink.writeJournal(entries))
+		err := sink.Recover()
+		require.ErrorContains(t, err, "invalid file name", entries)
+		require.ErrorContains(t, sink.Commit([]FileChange{{Name: "a.map"}}), "invalid file name")
+
+		data, err := os.ReadFile(victim)
+		require.NoError(t, err)
+		require.Equal(t, "keep", string(data))
+		require.FileExists(t, filepath.Join(dir, "staged"))
+		require.NoFileExists(t, filepath.Join(dir, "moved"))
+		require.NoFileExists(t, filepath.Join(dir, "a.map"))
+	}
+}
+
+func requireNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		require.NotContains(t, d.Name(), ".tmp", path)
+		return err
+	}))
+}
@@ -0,0 +1,155 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Compliance requirement names, after the Gmail and Yahoo bulk sender
+// requirements.
+const (
+	RequireDKIMAllDomains        = "dkim-all-domains"
+	RequireAlignedDomain         = "aligned-d"
+	RequireKeySize               = "rsa-1024"
+	RequireListUnsubscribeSigned = "list-unsubscribe-signed"
+)
+
+// minComplianceRSABits is the smallest RSA key mailbox providers accept.
+const minComplianceRSABits = 1024
+
+// ComplianceResult is the outcome of one requirement. Details names the
+// domains or settings that made it fail.
+type ComplianceResult struct {
+	Requirement string
+	Pass        bool
+	Details     []string
+}
+
+// ComplianceReport holds one result per requirement, in a fixed order.
+type ComplianceReport struct {
+	Results []ComplianceResult
+}
+
+// Pass reports whether every requirement passed.
+func (r ComplianceReport) Pass() bool {
+	for _, res := range r.Results {
+		if !res.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultSignHeaders is the rspamd default for the dkim module's
+// sign_headers option, used when module leaves it unset.
+const defaultSignHeaders = "(o)from:(x)sender:(o)reply-to:(o)subject:(x)date:(x)message-id:" +
+	"(o)to:(o)cc:(x)mime-version:(x)content-type:(x)content-transfer-encoding:" +
+	"resent-to:resent-cc:resent-from:resent-sender:resent-message-id:" +
+	"(x)in-reply-to:(x)references:list-id:list-help:list-owner:list-unsubscribe:" +
+	"list-unsubscribe-post:list-subscribe:list-post:(x)openpgp:(x)autocrypt"
+
+// CheckCompliance evaluates eff and the dkim module config against the
+// mailbox provider requirements for bulk senders: every sending domain has a
+// DKIM key, the d= domain aligns with the From domain, RSA keys have at
+// least 1024 bits, and List-Unsubscribe and List-Unsubscribe-Post are signed.
+// domains lists the sending domains; if nil, the domains with a domain rule
+// or map entry are used. module may be nil to assume rspamd's defaults.
+func CheckCompliance(eff EffectiveSigningConf, module *DKIMConf, domains []string) ComplianceReport {
+	present, aligned, size := checkDomainKeys(eff, domains, minComplianceRSABits, true)
+	unsub := ComplianceResult{Requirement: RequireListUnsubscribeSigned, Pass: true}
+	for _, name := range missingSignHeaders(module, []string{"list-unsubscribe", "list-unsubscribe-post"}) {
+		unsub.fail("sign_headers does not include %s", name)
+	}
+
+	return ComplianceReport{Results: []ComplianceResult{present, aligned, size, unsub}}
+}
+
+func (r *ComplianceResult) fail(format string, args ...any) {
+	r.Pass = false
+	r.Details = append(r.Details, fmt.Sprintf(format, args...))
+}
+
+// checkDomainKeys checks that each domain has a readable key whose d= domain
+// aligns with it and, for RSA keys, has at least minBits bits. Every key of
+// a selectors array is checked. If rsaOnly is set, domains signed with
+// Ed25519 keys only fail the size check. Nil domains mean those configured
+// in eff.
+func checkDomainKeys(eff EffectiveSigningConf, domains []string, minBits int, rsaOnly bool) (present, aligned, size ComplianceResult) {
+	if domains == nil {
+		for domain := range configuredDomains(eff) {
+			domains = append(domains, domain)
+		}
+	}
+	domains = append([]string(nil), domains...)
+	sort.Strings(domains)
+
+	present = ComplianceResult{Requirement: RequireDKIMAllDomains, Pass: true}
+	aligned = ComplianceResult{Requirement: RequireAlignedDomain, Pass: true}
+	size = ComplianceResult{Requirement: fmt.Sprintf("rsa-%d", minBits), Pass: true}
+	for _, domain := range domains {
+		from := strings.ToLower(strings.TrimSuffix(domain, "."))
+		keys := eff.ResolveAll(from)
+		if len(keys) == 0 {
+			present.fail("%s: no signing key", from)
+			continue
+		}
+		var hasRSA, hasEd25519 bool
+		for _, key := range keys {
+			signer, err := eff.signer(key)
+			if err != nil {
+				present.fail("%s: read key %q: %v", from, key.Path, err)
+				continue
+			}
+			if !relaxedAligned(from, key.Domain) {
+				aligned.fail("%s: signed as d=%s", from, key.Domain)
+			}
+			switch k := signer.(type) {
+			case *rsa.PrivateKey:
+				hasRSA = true
+				if bits := k.N.BitLen(); bits < minBits {
+					size.fail("%s: %d-bit RSA key", from, bits)
+				}
+			case ed25519.PrivateKey:
+				hasEd25519 = true
+			}
+		}
+		if rsaOnly && hasEd25519 && !hasRSA {
+			size.fail("%s: Ed25519 key only, which not all providers verify", from)
+		}
+	}
+	if eff.Conf != nil {
+		for _, opt := range []struct{ name, val string }{
+			{"use_domain", eff.Conf.UseDomain},
+			{"use_domain_sign_local", eff.Conf.UseDomainSignLocal},
+			{"use_domain_sign_networks", eff.Conf.UseDomainSignNetworks},
+		} {
+			if v := strings.ToLower(opt.val); v != "" && v != "header" {
+				aligned.fail("%s = %q does not sign with the From domain", opt.name, opt.val)
+			}
+		}
+	}
+	return present, aligned, size
+}
+
+// missingSignHeaders returns the names not covered by the sign_headers of
+// module, or of rspamd's default if module is nil or leaves it unset.
+func missingSignHeaders(module *DKIMConf, names []string) []string {
+	headers := parseSignHeaders(defaultSignHeaders)
+	if module != nil && module.SignHeaders != "" {
+		headers = module.SignHeaderList
+	}
+	signed := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		signed[strings.ToLower(h.Name)] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !signed[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,65 @@
+package dkim
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCompliance(t *testing.T) {
+	// Allow generating and parsing the undersized key being checked for.
+	t.Setenv("GODEBUG", "rsa1024min=0")
+	dir := t.TempDir()
+	for name, bits := range map[string]int{"good.key": 1024, "weak.key": 512} {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		require.NoError(t, err)
+		data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+	writeEd25519Key(t, filepath.Join(dir, "ed.key"))
+
+	good := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "s1", Path: filepath.Join(dir, "good.key")},
+				"b.com": {Selector: "s1", Path: filepath.Join(dir, "good.key"), SigningDomain: "mail.b.com"},
+				"d.com": {Selectors: []DomainSelector{
+					{Selector: "ed", Path: filepath.Join(dir, "ed.key")},
+					{Selector: "rsa", Path: filepath.Join(dir, "good.key")},
+				}},
+			},
+		},
+	}
+	report := CheckCompliance(good, nil, nil)
+	require.True(t, report.Pass(), "%+v", report)
+	require.Len(t, report.Results, 4)
+
+	bad := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			UseDomain: "envelope",
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "s1", Path: filepath.Join(dir, "weak.key")},
+				"b.com": {Selector: "s1", Path: filepath.Join(dir, "good.key"), SigningDomain: "esp.net"},
+				"e.com": {Selector: "ed", Path: filepath.Join(dir, "ed.key")},
+			},
+		},
+	}
+	module, err := ParseDKIMConf(strings.NewReader(`sign_headers = "from:to:subject:list-unsubscribe";`))
+	require.NoError(t, err)
+
+	report = CheckCompliance(bad, module, []string{"a.com", "b.com", "c.com", "e.com"})
+	require.False(t, report.Pass())
+	require.Equal(t, []ComplianceResult{
+		{Requirement: RequireDKIMAllDomains, Details: []string{"c.com: no signing key"}},
+		{Requirement: RequireAlignedDomain, Details: []string{"b.com: signed as d=esp.net", `use_domain = "envelope" does not sign with the From domain`}},
+		{Requirement: RequireKeySize, Details: []string{"a.com: 512-bit RSA key", "e.com: Ed25519 key only, which not all providers verify"}},
+		{Requirement: RequireListUnsubscribeSigned, Details: []string{"sign_headers does not include list-unsubscribe-post"}},
+	}, report.Results)
+}
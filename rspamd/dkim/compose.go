@@ -0,0 +1,55 @@
+package dkim
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// Paths under which ComposeDKIMSigningConf presents its inputs, as reported
+// in errors, warnings and Includes.
+const (
+	ComposeDefaultsPath = "/etc/rspamd/modules.d/dkim_signing.conf"
+	ComposeLocalPath    = "/etc/rspamd/local.d/dkim_signing.conf"
+	ComposeOverridePath = "/etc/rspamd/override.d/dkim_signing.conf"
+)
+
+var composeRoot = `.include "` + ComposeDefaultsPath + "\"\n" + localIncludes(ComposeLocalPath, ComposeOverridePath)
+
+// localIncludes includes the local and override files the way modules.d
+// files do: local.d is merged into the defaults, while override.d replaces
+// any value or section it sets.
+func localIncludes(local, override string) string {
+	return `.include(try=true,priority=1,duplicate=merge) "` + local + `"
+.include(try=true,priority=10) "` + override + `"
+`
+}
+
+// ComposeDKIMSigningConf builds the effective dkim_signing configuration from
+// the module defaults and the local.d and override.d files, applying the
+// precedence rspamd uses. local and override may be nil if the file does not
+// exist. Includes inside the inputs are read as usual.
+func ComposeDKIMSigningConf(defaults, local, override io.Reader, opts ...Option) (*DKIMSigningConf, error) {
+	if defaults == nil {
+		defaults = strings.NewReader("")
+	}
+	opts = append(opts, func(o *parseOptions) {
+		files := map[string]io.Reader{
+			RootedPath(o.rootPrefix, ComposeDefaultsPath): defaults,
+			RootedPath(o.rootPrefix, ComposeLocalPath):    local,
+			RootedPath(o.rootPrefix, ComposeOverridePath): override,
+		}
+		open := o.open
+		o.open = func(name string) (io.ReadCloser, error) {
+			r, ok := files[name]
+			if !ok {
+				return open(name)
+			}
+			if r == nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+			return io.NopCloser(r), nil
+		}
+	})
+	return ParseDKIMSigningConf(strings.NewReader(composeRoot), opts...)
+}
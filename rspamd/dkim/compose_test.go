@@ -0,0 +1,51 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposeDKIMSigningConf(t *testing.T) {
+	defaults := `
+enabled = true;
+selector = "dkim";
+use_esld = true;
+domain {
+  a.com { selector = "a"; }
+}
+`
+	local := `
+selector = "s2024";
+domain {
+  b.com { selector = "b"; }
+}
+`
+	conf, err := ComposeDKIMSigningConf(strings.NewReader(defaults), strings.NewReader(local), nil)
+	require.NoError(t, err)
+	require.True(t, *conf.Enabled)
+	require.Equal(t, "s2024", conf.Selector)
+	require.Equal(t, map[string]DomainRule{"a.com": {Selector: "a"}, "b.com": {Selector: "b"}}, conf.Domain)
+	require.Equal(t, []Include{
+		{Path: ComposeDefaultsPath, Resolved: true},
+		{Path: ComposeLocalPath, Resolved: true},
+		{Path: ComposeOverridePath},
+	}, conf.Includes)
+
+	// override.d replaces whole sections instead of merging them.
+	override := `
+use_esld = false;
+domain {
+  c.com { selector = "c"; }
+}
+`
+	conf, err = ComposeDKIMSigningConf(strings.NewReader(defaults), strings.NewReader(local), strings.NewReader(override))
+	require.NoError(t, err)
+	require.Equal(t, "s2024", conf.Selector)
+	require.False(t, *conf.UseESLD)
+	require.Equal(t, map[string]DomainRule{"c.com": {Selector: "c"}}, conf.Domain)
+
+	_, err = ComposeDKIMSigningConf(nil, strings.NewReader(`selector = "s1`), nil)
+	require.EqualError(t, err, ComposeLocalPath+":1:12: [DKIMCONF0002] unterminated string")
+}
@@ -2,16 +2,56 @@ package dkim
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf16"
 )
 
+// DKIMConf is the dkim module configuration. CheckPubkey and MinBits are the
+// verification policy: whether the public key in DNS is checked and the
+// smallest RSA key, in bits, accepted; MinBits is 0 when unset. CacheSize
+// and CacheExpire size the cache of DNS keys (dkim_cache_size and
+// dkim_cache_expire), 0 when unset. TimeJitter is the clock skew allowed
+// for signature timestamps and MaxSigs the most signatures checked per
+// message, both 0 when unset. TrustedOnly and SkipMulti are the
+// trusted_only and skip_multi flags, nil when unset. WhitelistedSignersMap
+// lists the entries of whitelisted_signers_map: map paths or URLs, or domains
+// written inline. The Symbol fields are the symbol options as
+// written; Symbols applies the defaults.
 type DKIMConf struct {
-	Enabled     *bool
-	SignHeaders string
-	SignHeaderList []SignHeader
+	Enabled               *bool
+	CheckPubkey           *bool
+	TrustedOnly           *bool
+	SkipMulti             *bool
+	MinBits               int
+	CacheSize             int64
+	CacheExpire           time.Duration
+	TimeJitter            time.Duration
+	MaxSigs               int
+	WhitelistedSignersMap []string
+	Symbol                string
+	SymbolAllow           string
+	SymbolReject          string
+	SymbolTempfail        string
+	SymbolNA              string
+	SymbolPermfail        string
+	SignHeaders           string
+	SignHeaderList        []SignHeader
+	Keys                  []string
+	Root                  *Section `json:"-"`
+	Arrays                map[string][]string
+	Sections              map[string]*Section
+	Includes              []Include
+	Warnings              []Warning
 }
 
 type SignHeader struct {
@@ -20,58 +60,398 @@ type SignHeader struct {
 	OptionalOversigned bool
 }
 
+// DKIMSymbols are the symbols the dkim module inserts for each verification
+// result.
+type DKIMSymbols struct {
+	Allow    string
+	Reject   string
+	Tempfail string
+	NA       string
+	Permfail string
+}
+
+// DefaultDKIMSymbols are the symbols rspamd uses when dkim.conf does not
+// name them.
+var DefaultDKIMSymbols = DKIMSymbols{
+	Allow:    "R_DKIM_ALLOW",
+	Reject:   "R_DKIM_REJECT",
+	Tempfail: "R_DKIM_TEMPFAIL",
+	NA:       "R_DKIM_NA",
+	Permfail: "R_DKIM_PERMFAIL",
+}
+
+// Symbols returns the result symbols of c, with rspamd's defaults for those
+// not set. c may be nil.
+func (c *DKIMConf) Symbols() DKIMSymbols {
+	out := DefaultDKIMSymbols
+	if c == nil {
+		return out
+	}
+	for _, s := range []struct {
+		dst *string
+		val string
+	}{
+		{&out.Allow, c.SymbolAllow},
+		{&out.Reject, c.SymbolReject},
+		{&out.Tempfail, c.SymbolTempfail},
+		{&out.NA, c.SymbolNA},
+		{&out.Permfail, c.SymbolPermfail},
+	} {
+		if s.val != "" {
+			*s.dst = s.val
+		}
+	}
+	return out
+}
+
+// DKIMSigningConf is the dkim_signing module configuration. UseRedis,
+// KeyPrefix and SelectorPrefix configure keys stored in Redis: the hashes
+// holding the keys and the selectors of each domain. SignCondition is the
+// Lua source of sign_condition, usually written as a heredoc, as read.
+// SignNetworks is the sign_networks option as written when it is a single
+// value; SignNetworkList holds its networks, given as a list or a single
+// network, and SignNetworksMap the map file it refers to otherwise.
+//
+// AllowHdrFromMismatchLocal allows a From/envelope domain mismatch for mail
+// from local networks only, AllowHdrFromMultiple signs mail with several
+// From headers using the first, and AllowEnvfromEmpty signs mail with an
+// empty envelope sender, such as bounces. AuthOnly is the legacy option
+// signing mail from authenticated users only, superseded by
+// sign_authenticated and sign_local.
 type DKIMSigningConf struct {
-	Enabled              *bool
-	AllowUsernameMismatch *bool
-	SignAuthenticated    *bool
-	SignLocal            *bool
-	SignInbound          *bool
-	UseDomain            string
-	UseDomainSignLocal   string
-	UseDomainSignNetworks string
-	AllowHdrFromMismatch *bool
-	UseESLD              *bool
-	TryFallback          *bool
-	Path                 string
-	Selector             string
-	PathMap              string
-	SelectorMap          string
-	Domain               map[string]DomainRule
+	Enabled                   *bool
+	AllowUsernameMismatch     *bool
+	SignAuthenticated         *bool
+	SignLocal                 *bool
+	SignInbound               *bool
+	UseDomain                 string
+	UseDomainSignLocal        string
+	UseDomainSignNetworks     string
+	AllowHdrFromMismatch      *bool
+	AllowHdrFromMismatchLocal *bool
+	AllowHdrFromMultiple      *bool
+	AllowEnvfromEmpty         *bool
+	AuthOnly                  *bool
+	UseESLD                   *bool
+	TryFallback               *bool
+	Path                      string
+	Selector                  string
+	PathMap                   string
+	SelectorMap               string
+	SignNetworks              string
+	SignNetworkList           []netip.Prefix
+	SignNetworksMap           string
+	UseRedis                  *bool
+	KeyPrefix                 string
+	SelectorPrefix            string
+	SignCondition             string
+	Domain                    map[string]DomainRule
+	Keys                      []string
+	Root                      *Section `json:"-"`
+	Arrays                    map[string][]string
+	Sections                  map[string]*Section
+	Includes                  []Include
+	Warnings                  []Warning
 }
 
+// DomainRule is an entry of the dkim_signing domain block. SigningDomain is
+// the optional `domain` key naming the d= domain when mail for this domain
+// is signed by a third party's key; it defaults to the rule's own domain.
+// Selectors holds the `selectors [ { selector = ...; path = ...; } ]` array
+// used to sign with several keys, such as an RSA and an Ed25519 key; a plain
+// `selectors = ["rsa", "ed25519"]` list shares the rule's path. RawKey is
+// the `key` option holding the private key inline, as a PEM blob or raw
+// base64, in place of a path; see ParseRawKey.
 type DomainRule struct {
+	Selector      string
+	Path          string
+	RawKey        string
+	SigningDomain string
+	Selectors     []DomainSelector
+}
+
+// DomainSelector is an entry of a domain rule's selectors array. Without a
+// Path or RawKey it uses the rule's, see Resolve.
+type DomainSelector struct {
 	Selector string
 	Path     string
+	RawKey   string
+}
+
+// Section is a nested `name { ... }` block. Values holds its scalar
+// assignments, Arrays its `[ ... ]` list values, Sections the blocks
+// nested inside it and Blocks its arrays of blocks, `[ { ... }, { ... } ]`.
+// Comments holds, per key, the comments written before it, such as
+// "# rotated 2024-01", with their markers.
+type Section struct {
+	Values   map[string]string
+	Arrays   map[string][]string
+	Sections map[string]*Section
+	Blocks   map[string][]*Section
+	Comments map[string][]string
+
+	// priority records the include priority each key was set with and
+	// pos where it was set. order lists the keys as first set, quoted the
+	// scalars written as strings and elems the array elements, for Parse.
+	priority map[string]int
+	pos      map[string]position
+	order    []string
+	quoted   map[string]bool
+	elems    map[string][]element
+}
+
+// element is where an array element appeared and whether it was quoted.
+type element struct {
+	pos    position
+	quoted bool
+}
+
+func newSection() *Section {
+	return &Section{
+		Values:   make(map[string]string),
+		Arrays:   make(map[string][]string),
+		Sections: make(map[string]*Section),
+		Blocks:   make(map[string][]*Section),
+		Comments: make(map[string][]string),
+		priority: make(map[string]int),
+		pos:      make(map[string]position),
+		quoted:   make(map[string]bool),
+		elems:    make(map[string][]element),
+	}
+}
+
+// unwrapModule returns the body of a `name { ... }` block if it is the only
+// entry of root, as in files taken from rspamd.conf rather than local.d.
+func unwrapModule(root *Section, name string) *Section {
+	sec, ok := root.Sections[name]
+	if !ok || len(root.Sections) != 1 || len(root.Values) != 0 || len(root.Arrays) != 0 {
+		return root
+	}
+	return sec
+}
+
+// note records that key was set by the entry starting with tok.
+func (s *Section) note(key string, tok token) {
+	s.pos[key] = tok.pos
+	if len(tok.comments) > 0 {
+		s.Comments[key] = append(s.Comments[key], tok.comments...)
+	}
+}
+
+// collect appends vals to the array at key, first turning a scalar value
+// there into the array's first element. The key keeps its first position.
+func (s *Section) collect(key string, tok token, vals []string, elems []element) {
+	if old, ok := s.Values[key]; ok {
+		s.Arrays[key] = []string{old}
+		s.elems[key] = []element{{pos: s.pos[key], quoted: s.quoted[key]}}
+		delete(s.Values, key)
+		delete(s.quoted, key)
+	}
+	s.Arrays[key] = append(s.Arrays[key], vals...)
+	s.elems[key] = append(s.elems[key], elems...)
+	s.Comments[key] = append(s.Comments[key], tok.comments...)
 }
 
-func ParseDKIMConf(r io.Reader) (*DKIMConf, error) {
-	assignments, _, err := parseRspamdConfig(r)
+// errorAt attaches the position key was set at to err.
+func (s *Section) errorAt(key string, err error) error {
+	if pos, ok := s.pos[key]; ok {
+		return errorAt(pos, err)
+	}
+	return err
+}
+
+// Keys returns the keys of s in the order they were first set. Keys without
+// a recorded order, as in sections built by MergeHosts, follow in sorted
+// order.
+func (s *Section) Keys() []string {
+	seen := make(map[string]bool, len(s.order))
+	var keys []string
+	for _, key := range s.order {
+		if !seen[key] && s.has(key) {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for _, m := range []map[string]bool{keySet(s.Values), keySet(s.Arrays), keySet(s.Sections), keySet(s.Blocks)} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				rest = append(rest, key)
+			}
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// Value returns the scalar assigned to key in s as a typed Value.
+func (s *Section) Value(key string) (Value, bool) {
+	val, ok := s.Values[key]
+	return Value(val), ok
+}
+
+func ParseDKIMConf(r io.Reader, opts ...Option) (*DKIMConf, error) {
+	doc, err := parseRspamdConfig(r, newParseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	conf, err := doc.dkimConf(unwrapModule(doc.root, "dkim"))
 	if err != nil {
 		return nil, err
 	}
+	return conf, doc.err()
+}
+
+// dkimConf builds a DKIMConf from root. Errors are recorded in doc in
+// recovery mode and returned otherwise.
+func (doc *document) dkimConf(root *Section) (*DKIMConf, error) {
+	doc.foldKeys(root, dkimConfKeys)
+	assignments := root.Values
 
 	conf := &DKIMConf{
-		SignHeaders: assignments["sign_headers"],
+		SignHeaders:    assignments["sign_headers"],
+		Symbol:         assignments["symbol"],
+		SymbolAllow:    assignments["symbol_allow"],
+		SymbolReject:   assignments["symbol_reject"],
+		SymbolTempfail: assignments["symbol_tempfail"],
+		SymbolNA:       assignments["symbol_na"],
+		SymbolPermfail: assignments["symbol_permfail"],
+		Keys:           root.Keys(),
+		Root:           root,
+		Arrays:         root.Arrays,
+		Sections:       root.Sections,
+		Includes:       doc.includes,
+		Warnings:       doc.warnings,
 	}
 	if conf.SignHeaders != "" {
 		conf.SignHeaderList = parseSignHeaders(conf.SignHeaders)
 	}
-	if val, ok := assignments["enabled"]; ok {
-		parsed, err := parseBool(val)
+	if val, ok := assignments["whitelisted_signers_map"]; ok {
+		conf.WhitelistedSignersMap = []string{val}
+	} else if list, ok := root.Arrays["whitelisted_signers_map"]; ok {
+		conf.WhitelistedSignersMap = append([]string(nil), list...)
+	}
+	for _, b := range []struct {
+		dst **bool
+		key string
+	}{
+		{&conf.Enabled, "enabled"},
+		{&conf.CheckPubkey, "check_pubkey"},
+		{&conf.TrustedOnly, "trusted_only"},
+		{&conf.SkipMulti, "skip_multi"},
+	} {
+		if val, ok := assignments[b.key]; ok {
+			parsed, err := parseBool(val)
+			if err != nil {
+				if err := doc.report(root.errorAt(b.key, fmt.Errorf("parse %s: %w", b.key, err))); err != nil {
+					return nil, err
+				}
+			} else {
+				*b.dst = &parsed
+			}
+		}
+	}
+	if val, ok := root.Value("dkim_cache_size"); ok {
+		n, err := val.Size()
+		if err == nil && n < 0 {
+			err = fmt.Errorf("dkim_cache_size %d is negative", n)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("parse enabled: %w", err)
+			if err := doc.report(root.errorAt("dkim_cache_size", withCode(CodeInvalidValue, fmt.Errorf("parse dkim_cache_size: %w", err)))); err != nil {
+				return nil, err
+			}
+		} else {
+			conf.CacheSize = n
 		}
-		conf.Enabled = &parsed
 	}
-
+	if val, ok := root.Value("dkim_cache_expire"); ok {
+		d, err := val.Duration()
+		if err == nil && d < 0 {
+			err = fmt.Errorf("dkim_cache_expire %s is negative", val)
+		}
+		if err != nil {
+			if err := doc.report(root.errorAt("dkim_cache_expire", withCode(CodeInvalidValue, fmt.Errorf("parse dkim_cache_expire: %w", err)))); err != nil {
+				return nil, err
+			}
+		} else {
+			conf.CacheExpire = d
+		}
+	}
+	if val, ok := root.Value("time_jitter"); ok {
+		d, err := val.Duration()
+		if err == nil && d < 0 {
+			err = fmt.Errorf("time_jitter %s is negative", val)
+		}
+		if err != nil {
+			if err := doc.report(root.errorAt("time_jitter", withCode(CodeInvalidValue, fmt.Errorf("parse time_jitter: %w", err)))); err != nil {
+				return nil, err
+			}
+		} else {
+			conf.TimeJitter = d
+		}
+	}
+	if val, ok := root.Value("max_sigs"); ok {
+		n, err := val.Int()
+		if err == nil && n < 1 {
+			err = fmt.Errorf("max_sigs %d must be positive", n)
+		} else if err == nil && n > math.MaxInt32 {
+			err = fmt.Errorf("max_sigs %d out of range", n)
+		}
+		if err != nil {
+			if err := doc.report(root.errorAt("max_sigs", withCode(CodeInvalidValue, fmt.Errorf("parse max_sigs: %w", err)))); err != nil {
+				return nil, err
+			}
+		} else {
+			conf.MaxSigs = int(n)
+		}
+	}
+	if val, ok := root.Value("min_bits"); ok {
+		n, err := val.Int()
+		if err == nil && (n < 0 || n > 1<<16) {
+			err = fmt.Errorf("min_bits %d out of range", n)
+		}
+		if err != nil {
+			if err := doc.report(root.errorAt("min_bits", withCode(CodeInvalidValue, fmt.Errorf("parse min_bits: %w", err)))); err != nil {
+				return nil, err
+			}
+		} else {
+			conf.MinBits = int(n)
+		}
+	}
+	if err := doc.checkKeys(root, dkimConfKeys, ""); err != nil {
+		return nil, err
+	}
+	doc.checkDeprecated(root, dkimConfDeprecated)
+	conf.Warnings = doc.warnings
 	return conf, nil
 }
 
-func ParseDKIMSigningConf(r io.Reader) (*DKIMSigningConf, error) {
-	assignments, domain, err := parseRspamdConfig(r)
+func ParseDKIMSigningConf(r io.Reader, opts ...Option) (*DKIMSigningConf, error) {
+	doc, err := parseRspamdConfig(r, newParseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	conf, err := doc.dkimSigningConf(unwrapModule(doc.root, "dkim_signing"))
 	if err != nil {
 		return nil, err
 	}
+	return conf, doc.err()
+}
+
+// dkimSigningConf builds a DKIMSigningConf from root, handling errors like
+// dkimConf.
+func (doc *document) dkimSigningConf(root *Section) (*DKIMSigningConf, error) {
+	doc.foldKeys(root, dkimSigningConfKeys)
+	assignments := root.Values
+	var domain map[string]*Section
+	if sec, ok := root.Sections["domain"]; ok {
+		domain = sec.Sections
+	}
+	names := sortedKeys(domain)
+	for _, name := range names {
+		doc.foldKeys(domain[name], domainRuleKeys)
+	}
 
 	conf := &DKIMSigningConf{
 		UseDomain:             assignments["use_domain"],
@@ -81,70 +461,160 @@ func ParseDKIMSigningConf(r io.Reader) (*DKIMSigningConf, error) {
 		Selector:              assignments["selector"],
 		PathMap:               assignments["path_map"],
 		SelectorMap:           assignments["selector_map"],
+		SignNetworks:          assignments["sign_networks"],
+		KeyPrefix:             assignments["key_prefix"],
+		SelectorPrefix:        assignments["selector_prefix"],
+		SignCondition:         assignments["sign_condition"],
 		Domain:                make(map[string]DomainRule, len(domain)),
+		Keys:                  root.Keys(),
+		Root:                  root,
+		Arrays:                root.Arrays,
+		Sections:              root.Sections,
+		Includes:              doc.includes,
+		Warnings:              doc.warnings,
 	}
 
-	for key, rule := range domain {
-		conf.Domain[key] = DomainRule{
-			Selector: rule["selector"],
-			Path:     rule["path"],
+	for _, key := range names {
+		rule := domain[key]
+		dr := DomainRule{
+			Selector:      rule.Values["selector"],
+			Path:          rule.Values["path"],
+			RawKey:        rawKey(rule.Values["key"]),
+			SigningDomain: rule.Values["domain"],
+		}
+		for _, name := range rule.Arrays["selectors"] {
+			dr.Selectors = append(dr.Selectors, DomainSelector{Selector: name})
 		}
+		for i, entry := range rule.Blocks["selectors"] {
+			sel := DomainSelector{Selector: entry.Values["selector"], Path: entry.Values["path"], RawKey: rawKey(entry.Values["key"])}
+			if sel.Selector == "" {
+				err := withCode(CodeInvalidValue, fmt.Errorf("parse %s.selectors: entry %d has no selector", key, i+1))
+				if err := doc.report(rule.errorAt("selectors", err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			dr.Selectors = append(dr.Selectors, sel)
+		}
+		conf.Domain[key] = dr
 	}
 
-	setBool := func(dst **bool, key string) error {
-		val, ok := assignments[key]
+	bools := []struct {
+		dst **bool
+		key string
+	}{
+		{&conf.Enabled, "enabled"},
+		{&conf.AllowUsernameMismatch, "allow_username_mismatch"},
+		{&conf.SignAuthenticated, "sign_authenticated"},
+		{&conf.SignLocal, "sign_local"},
+		{&conf.SignInbound, "sign_inbound"},
+		{&conf.AllowHdrFromMismatch, "allow_hdrfrom_mismatch"},
+		{&conf.AllowHdrFromMismatchLocal, "allow_hdrfrom_mismatch_local"},
+		{&conf.AllowHdrFromMultiple, "allow_hdrfrom_multiple"},
+		{&conf.AllowEnvfromEmpty, "allow_envfrom_empty"},
+		{&conf.AuthOnly, "auth_only"},
+		{&conf.UseESLD, "use_esld"},
+		{&conf.TryFallback, "try_fallback"},
+		{&conf.UseRedis, "use_redis"},
+	}
+	for _, b := range bools {
+		val, ok := assignments[b.key]
 		if !ok {
-			return nil
+			continue
 		}
 		parsed, err := parseBool(val)
 		if err != nil {
-			return fmt.Errorf("parse %s: %w", key, err)
+			if err := doc.report(root.errorAt(b.key, fmt.Errorf("parse %s: %w", b.key, err))); err != nil {
+				return nil, err
+			}
+			continue
 		}
-		*dst = &parsed
-		return nil
-	}
-
-	if err := setBool(&conf.Enabled, "enabled"); err != nil {
-		return nil, err
-	}
-	if err := setBool(&conf.AllowUsernameMismatch, "allow_username_mismatch"); err != nil {
-		return nil, err
-	}
-	if err := setBool(&conf.SignAuthenticated, "sign_authenticated"); err != nil {
-		return nil, err
+		*b.dst = &parsed
 	}
-	if err := setBool(&conf.SignLocal, "sign_local"); err != nil {
+	if err := doc.signNetworks(conf, root); err != nil {
 		return nil, err
 	}
-	if err := setBool(&conf.SignInbound, "sign_inbound"); err != nil {
+	if err := doc.checkKeys(root, dkimSigningConfKeys, ""); err != nil {
 		return nil, err
 	}
-	if err := setBool(&conf.AllowHdrFromMismatch, "allow_hdrfrom_mismatch"); err != nil {
-		return nil, err
-	}
-	if err := setBool(&conf.UseESLD, "use_esld"); err != nil {
-		return nil, err
+	for _, name := range names {
+		if err := doc.checkKeys(domain[name], domainRuleKeys, name); err != nil {
+			return nil, err
+		}
 	}
-	if err := setBool(&conf.TryFallback, "try_fallback"); err != nil {
-		return nil, err
+	doc.checkValues(root, "")
+	for _, name := range names {
+		doc.checkValues(domain[name], name)
 	}
-
+	conf.Warnings = doc.warnings
 	return conf, nil
 }
 
+// rawKey trims the indentation of a multi-line inline key, such as a PEM
+// blob in a heredoc, and drops its blank lines.
+func rawKey(v string) string {
+	var lines []string
+	for _, line := range strings.Split(v, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // ParseDKIMSelectorsMap parses a maps.d/dkim_selectors.map file.
-func ParseDKIMSelectorsMap(r io.Reader) (map[string]string, error) {
-	return parseKeyValueMap(r)
+func ParseDKIMSelectorsMap(r io.Reader, opts ...Option) (map[string]string, error) {
+	return parseKeyValueMap(r, newParseOptions(opts))
 }
 
 // ParseDKIMPathsMap parses a maps.d/dkim_paths.map file.
-func ParseDKIMPathsMap(r io.Reader) (map[string]string, error) {
-	return parseKeyValueMap(r)
+func ParseDKIMPathsMap(r io.Reader, opts ...Option) (map[string]string, error) {
+	return parseKeyValueMap(r, newParseOptions(opts))
 }
 
 // ParseSignedDomainsMap parses a maps.d/signed_domains.map file.
-func ParseSignedDomainsMap(r io.Reader) (map[string]string, error) {
-	return parseKeyValueMap(r)
+func ParseSignedDomainsMap(r io.Reader, opts ...Option) (map[string]string, error) {
+	return parseKeyValueMap(r, newParseOptions(opts))
+}
+
+// ParseWhitelistedSignersMap parses the map of trusted signing domains
+// referenced by whitelisted_signers_map in dkim.conf, one domain per line.
+// Columns after the domain are ignored.
+func ParseWhitelistedSignersMap(r io.Reader, opts ...Option) ([]string, error) {
+	o := newParseOptions(opts)
+	var read int64
+	scanner := bufio.NewScanner(newTextReader(newLimitReader(r, &read, o.limits.InputSize), o, o.filename))
+	var out []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		out = append(out, strings.Fields(line)[0])
+		if err := o.checkEntries(len(out)); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Maps groups the maps.d files referenced from dkim_signing.conf. Each field
+// holds the result of the matching Parse*Map function and may be nil.
+type Maps struct {
+	Selectors     map[string]string
+	Paths         map[string]string
+	SignedDomains map[string]string
+	// SignNetworks holds the networks of a sign_networks map file.
+	SignNetworks Networks
+	// Annotations holds the metadata of map entries by key, as returned
+	// by MapAnnotations.
+	Annotations map[string]map[string]string
 }
 
 type tokenType int
@@ -156,46 +626,172 @@ const (
 	tokenLBrace
 	tokenRBrace
 	tokenEqual
+	tokenColon
 	tokenSemicolon
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenLParen
+	tokenRParen
+	tokenDirective
 )
 
+var tokenNames = map[tokenType]string{
+	tokenEOF:       "end of file",
+	tokenIdent:     "identifier",
+	tokenString:    "string",
+	tokenLBrace:    "'{'",
+	tokenRBrace:    "'}'",
+	tokenEqual:     "'='",
+	tokenColon:     "':'",
+	tokenSemicolon: "';'",
+	tokenLBracket:  "'['",
+	tokenRBracket:  "']'",
+	tokenComma:     "','",
+	tokenLParen:    "'('",
+	tokenRParen:    "')'",
+	tokenDirective: "directive",
+}
+
+func (t tokenType) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
 type token struct {
 	typ tokenType
 	val string
+	pos position
+
+	// comments are the comments between the previous token and this one.
+	comments []string
+}
+
+// position is a location in an input; line and col are 1-based and col
+// counts runes.
+type position struct {
+	file string
+	line int
+	col  int
 }
 
 type lexer struct {
-	r   *bufio.Reader
-	buf []rune
+	r    *bufio.Reader
+	buf  []rune
 	peek *token
+	last token
+	opts *parseOptions
+
+	// err is a read error other than io.EOF. Parsing cannot continue past
+	// it, even in recovery mode.
+	err error
+
+	// cur is the position of the next rune, prev that of the last rune
+	// read and tok the start of the last token returned by next.
+	cur  position
+	prev position
+	tok  position
+
+	// text collects the runes of the comment being skipped while capturing
+	// is set; comments holds the comments seen since the last token.
+	capturing bool
+	text      []rune
+	comments  []string
 }
 
-func newLexer(r io.Reader) *lexer {
-	return &lexer{r: bufio.NewReader(r)}
+func newLexer(r io.Reader, opts *parseOptions, file string) *lexer {
+	start := position{file: file, line: 1, col: 1}
+	return &lexer{r: bufio.NewReader(newTextReader(r, opts, file)), opts: opts, cur: start, prev: start, tok: start}
+}
+
+// readRune reads the next rune and advances the current position.
+func (l *lexer) readRune() (rune, int, error) {
+	r, size, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return r, size, err
+	}
+	if l.capturing {
+		l.text = append(l.text, r)
+	}
+	l.prev = l.cur
+	if r == '\n' {
+		l.cur.line++
+		l.cur.col = 1
+	} else {
+		l.cur.col++
+	}
+	return r, size, nil
+}
+
+// unreadRune steps back over the last rune read by readRune.
+func (l *lexer) unreadRune() error {
+	if err := l.r.UnreadRune(); err != nil {
+		return err
+	}
+	l.cur = l.prev
+	return nil
 }
 
 func (l *lexer) next() (token, error) {
 	if l.peek != nil {
 		tok := *l.peek
 		l.peek = nil
+		l.tok = tok.pos
+		l.last = tok
 		return tok, nil
 	}
+	tok, err := l.lex()
+	tok.pos = l.tok
+	tok.comments, l.comments = l.comments, nil
+	l.last = tok
+	if err == nil {
+		l.opts.traceToken(tok)
+	}
+	if max := l.opts.limits.StringLength; err == nil && max > 0 && len(tok.val) > max {
+		return tok, fmt.Errorf("%w: %v longer than %d bytes", ErrLimitExceeded, tok.typ, max)
+	}
+	return tok, err
+}
+
+func (l *lexer) lex() (token, error) {
 	for {
-		r, _, err := l.r.ReadRune()
+		r, _, err := l.readRune()
 		if err == io.EOF {
+			l.tok = l.cur
 			return token{typ: tokenEOF}, nil
 		}
 		if err != nil {
 			return token{}, err
 		}
+		l.tok = l.prev
 
 		if r == '#' {
-			if err := l.skipLine(); err != nil {
+			l.startComment(r)
+			err := l.skipLine()
+			l.endComment(true)
+			if err != nil {
 				return token{}, err
 			}
 			continue
 		}
 
+		if r == '/' {
+			l.startComment(r)
+			skipped, err := l.skipComment()
+			l.endComment(skipped)
+			if err != nil {
+				return token{}, err
+			}
+			if skipped {
+				continue
+			}
+		}
+
 		if unicode.IsSpace(r) {
 			continue
 		}
@@ -207,14 +803,47 @@ func (l *lexer) next() (token, error) {
 			return token{typ: tokenRBrace}, nil
 		case '=':
 			return token{typ: tokenEqual}, nil
+		case ':':
+			return token{typ: tokenColon}, nil
 		case ';':
 			return token{typ: tokenSemicolon}, nil
+		case '[':
+			return token{typ: tokenLBracket}, nil
+		case ']':
+			return token{typ: tokenRBracket}, nil
+		case ',':
+			return token{typ: tokenComma}, nil
+		case '(':
+			return token{typ: tokenLParen}, nil
+		case ')':
+			return token{typ: tokenRParen}, nil
+		case '.':
+			l.buf = l.buf[:0]
+			if err := l.readIdent(); err != nil {
+				return token{}, err
+			}
+			if len(l.buf) == 0 {
+				return token{}, withCode(CodeSyntax, fmt.Errorf("unexpected character: %q", r))
+			}
+			return token{typ: tokenDirective, val: string(l.buf)}, nil
 		case '"':
 			str, err := l.readString()
 			if err != nil {
 				return token{}, err
 			}
 			return token{typ: tokenString, val: str}, nil
+		case '<':
+			str, err := l.readHeredoc()
+			if err != nil {
+				return token{}, err
+			}
+			return token{typ: tokenString, val: str}, nil
+		case '\'':
+			str, err := l.readSingleQuoted()
+			if err != nil {
+				return token{}, err
+			}
+			return token{typ: tokenString, val: str}, nil
 		default:
 			if isIdentStart(r) {
 				l.buf = l.buf[:0]
@@ -224,7 +853,7 @@ func (l *lexer) next() (token, error) {
 				}
 				return token{typ: tokenIdent, val: string(l.buf)}, nil
 			}
-			return token{}, fmt.Errorf("unexpected character: %q", r)
+			return token{}, withCode(CodeSyntax, fmt.Errorf("unexpected character: %q", r))
 		}
 	}
 }
@@ -233,29 +862,21 @@ func (l *lexer) unread(tok token) {
 	l.peek = &tok
 }
 
-func parseKeyValueMap(r io.Reader) (map[string]string, error) {
-	scanner := bufio.NewScanner(r)
-	out := make(map[string]string)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			return nil, fmt.Errorf("invalid map line: %q", line)
-		}
-		out[fields[0]] = fields[1]
-	}
-	if err := scanner.Err(); err != nil {
+func parseKeyValueMap(r io.Reader, opts *parseOptions) (map[string]string, error) {
+	entries, err := parseMapEntries(r, opts)
+	if err != nil {
 		return nil, err
 	}
+	out := make(map[string]string, len(entries))
+	for _, e := range entries {
+		out[e.Key] = e.Value
+	}
 	return out, nil
 }
 
 func (l *lexer) readIdent() error {
 	for {
-		r, _, err := l.r.ReadRune()
+		r, _, err := l.readRune()
 		if err == io.EOF {
 			return nil
 		}
@@ -266,7 +887,7 @@ func (l *lexer) readIdent() error {
 			l.buf = append(l.buf, r)
 			continue
 		}
-		if err := l.r.UnreadRune(); err != nil {
+		if err := l.unreadRune(); err != nil {
 			return err
 		}
 		return nil
@@ -276,7 +897,10 @@ func (l *lexer) readIdent() error {
 func (l *lexer) readString() (string, error) {
 	var b strings.Builder
 	for {
-		r, _, err := l.r.ReadRune()
+		r, _, err := l.readRune()
+		if err == io.EOF {
+			return "", withCode(CodeUnterminated, errors.New("unterminated string"))
+		}
 		if err != nil {
 			return "", err
 		}
@@ -284,20 +908,187 @@ func (l *lexer) readString() (string, error) {
 			return b.String(), nil
 		}
 		if r == '\\' {
-			esc, _, err := l.r.ReadRune()
+			if err := l.readEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+}
+
+// readEscape decodes the escape sequence following a backslash in a
+// double-quoted string. Unknown escapes yield the escaped character itself.
+// With raw escapes enabled the sequence is copied verbatim.
+func (l *lexer) readEscape(b *strings.Builder) error {
+	esc, _, err := l.readRune()
+	if err != nil {
+		return err
+	}
+	if l.opts.rawEscapes {
+		b.WriteRune('\\')
+		b.WriteRune(esc)
+		return nil
+	}
+	switch esc {
+	case 'n':
+		b.WriteRune('\n')
+	case 'r':
+		b.WriteRune('\r')
+	case 't':
+		b.WriteRune('\t')
+	case 'b':
+		b.WriteRune('\b')
+	case 'f':
+		b.WriteRune('\f')
+	case 'u':
+		r, err := l.readHex4()
+		if err != nil {
+			return err
+		}
+		if utf16.IsSurrogate(r) {
+			if ahead, _ := l.r.Peek(2); string(ahead) == "\\u" {
+				if _, err := l.r.Discard(2); err != nil {
+					return err
+				}
+				l.cur.col += 2
+				low, err := l.readHex4()
+				if err != nil {
+					return err
+				}
+				r = utf16.DecodeRune(r, low)
+			} else {
+				r = unicode.ReplacementChar
+			}
+		}
+		b.WriteRune(r)
+	default:
+		b.WriteRune(esc)
+	}
+	return nil
+}
+
+func (l *lexer) readHex4() (rune, error) {
+	var digits [4]rune
+	for i := range digits {
+		r, _, err := l.readRune()
+		if err != nil {
+			return 0, err
+		}
+		digits[i] = r
+	}
+	n, err := strconv.ParseUint(string(digits[:]), 16, 16)
+	if err != nil {
+		return 0, withCode(CodeInvalidEscape, fmt.Errorf("invalid unicode escape \\u%s", string(digits[:])))
+	}
+	return rune(n), nil
+}
+
+// readSingleQuoted reads a '...' literal. As in libucl, no escapes are
+// processed apart from \' for an embedded quote.
+func (l *lexer) readSingleQuoted() (string, error) {
+	var b strings.Builder
+	for {
+		r, _, err := l.readRune()
+		if err == io.EOF {
+			return "", withCode(CodeUnterminated, errors.New("unterminated string"))
+		}
+		if err != nil {
+			return "", err
+		}
+		if r == '\'' {
+			return b.String(), nil
+		}
+		if r == '\\' {
+			next, _, err := l.readRune()
 			if err != nil {
 				return "", err
 			}
-			b.WriteRune(esc)
+			if next != '\'' {
+				b.WriteRune(r)
+			}
+			b.WriteRune(next)
 			continue
 		}
 		b.WriteRune(r)
 	}
 }
 
+// readHeredoc reads a `<<TERM` multiline string after the first '<'. The
+// terminator must be upper-case letters followed by a newline, and the body
+// runs until a line starting with the terminator, as in `EOD;`. The newline
+// before the terminator line is not part of the value.
+func (l *lexer) readHeredoc() (string, error) {
+	r, _, err := l.readRune()
+	if err != nil {
+		return "", err
+	}
+	if r != '<' {
+		return "", withCode(CodeSyntax, fmt.Errorf("unexpected character: %q", '<'))
+	}
+	var term strings.Builder
+	for {
+		r, _, err := l.readRune()
+		if err != nil {
+			return "", err
+		}
+		if r >= 'A' && r <= 'Z' {
+			term.WriteRune(r)
+			continue
+		}
+		if r == '\r' {
+			if r, _, err = l.readRune(); err != nil {
+				return "", err
+			}
+		}
+		if r != '\n' || term.Len() == 0 {
+			return "", withCode(CodeSyntax, fmt.Errorf("invalid heredoc terminator %q", term.String()+string(r)))
+		}
+		break
+	}
+
+	end := term.String()
+	var lines []string
+	for {
+		ahead, _ := l.r.Peek(len(end) + 1)
+		if strings.HasPrefix(string(ahead), end) && (len(ahead) == len(end) || !isIdentPart(rune(ahead[len(end)]))) {
+			if _, err := l.r.Discard(len(end)); err != nil {
+				return "", err
+			}
+			l.cur.col += len(end)
+			return strings.Join(lines, "\n"), nil
+		}
+		line, err := l.r.ReadString('\n')
+		if err == io.EOF {
+			return "", withCode(CodeUnterminated, fmt.Errorf("unterminated heredoc %q", end))
+		}
+		if err != nil {
+			return "", err
+		}
+		l.cur.line++
+		l.cur.col = 1
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// startComment begins capturing a comment starting with r.
+func (l *lexer) startComment(r rune) {
+	l.capturing = true
+	l.text = append(l.text[:0], r)
+}
+
+// endComment stops capturing and, if keep is set, records the comment
+// without its line ending.
+func (l *lexer) endComment(keep bool) {
+	l.capturing = false
+	if keep {
+		l.comments = append(l.comments, strings.TrimRight(string(l.text), "\r\n"))
+	}
+}
+
 func (l *lexer) skipLine() error {
 	for {
-		r, _, err := l.r.ReadRune()
+		r, _, err := l.readRune()
 		if err == io.EOF {
 			return nil
 		}
@@ -310,108 +1101,550 @@ func (l *lexer) skipLine() error {
 	}
 }
 
+// skipComment is called after a '/' has been read and skips a `//` line
+// comment or a `/* ... */` block comment, which may be nested. It reports
+// false if the slash does not start a comment.
+func (l *lexer) skipComment() (bool, error) {
+	r, _, err := l.readRune()
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch r {
+	case '/':
+		return true, l.skipLine()
+	case '*':
+		depth := 1
+		var prev rune
+		for depth > 0 {
+			r, _, err := l.readRune()
+			if err == io.EOF {
+				return false, withCode(CodeUnterminated, errors.New("unterminated block comment"))
+			}
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case prev == '/' && r == '*':
+				depth++
+				r = 0
+			case prev == '*' && r == '/':
+				depth--
+				r = 0
+			}
+			prev = r
+		}
+		return true, nil
+	default:
+		return false, l.unreadRune()
+	}
+}
+
 func isIdentStart(r rune) bool {
-	return unicode.IsLetter(r) || r == '_' || r == '$'
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
 }
 
 func isIdentPart(r rune) bool {
 	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == '/' || r == '$'
 }
 
-func parseRspamdConfig(r io.Reader) (map[string]string, map[string]map[string]string, error) {
-	l := newLexer(r)
-	assignments := make(map[string]string)
-	domains := make(map[string]map[string]string)
+// parser holds the state for parsing one file. Included files are parsed by
+// child parsers that share the options and the chain of open includes.
+type parser struct {
+	l        *lexer
+	opts     *parseOptions
+	doc      *document
+	dir      string
+	priority int
+	dup      duplicatePolicy
+	chain    []string
+}
+
+// document is the result of parsing one input together with its includes.
+type document struct {
+	root     *Section
+	includes []Include
+	opts     *parseOptions
+	errs     ParseErrors
+	warnings []Warning
+
+	// read counts the input bytes and depth the open sections, for Limits.
+	read  int64
+	depth int
+
+	// expanded counts the bytes produced by expanding macros and
+	// variables, which share the InputSize budget with the input.
+	expanded int64
+
+	// macros holds the options' macros and the variables defined so far
+	// with `$name = value;`, or nil until the first definition.
+	macros map[string]string
+
+	// sources holds the text of each file read, by name, for WithSnippets.
+	sources map[string]*bytes.Buffer
+}
+
+// expand replaces references to macros and variables defined so far in s.
+// It fails if the result, or all expansions together, exceed the Limits.
+func (d *document) expand(s string) (string, error) {
+	macros := d.macros
+	if macros == nil {
+		macros = d.opts.macros
+	}
+	out, err := expandMacros(s, macros, d.opts.limits.StringLength)
+	if err != nil || out == s {
+		return out, err
+	}
+	d.expanded += int64(len(out))
+	if max := d.opts.limits.InputSize; max > 0 && d.expanded > max {
+		return "", fmt.Errorf("%w: expanded values larger than %d bytes", ErrLimitExceeded, max)
+	}
+	return out, nil
+}
 
+// define sets the variable name for the values that follow.
+func (d *document) define(name, val string) {
+	if d.macros == nil {
+		d.macros = make(map[string]string, len(d.opts.macros)+1)
+		for k, v := range d.opts.macros {
+			d.macros[k] = v
+		}
+	}
+	d.macros[name] = val
+}
+
+// report handles an error found after the input was read. Outside recovery
+// mode err is returned; otherwise it is recorded and nil is returned.
+func (d *document) report(err error) error {
+	if !d.opts.recovery {
+		return d.snapshot(err)
+	}
+	d.addError(err)
+	return nil
+}
+
+// full reports whether the WithMaxErrors limit has been reached.
+func (d *document) full() bool {
+	return d.opts.maxErrors > 0 && len(d.errs) >= d.opts.maxErrors
+}
+
+// addError records an error found in recovery mode. An error repeating the
+// previous one, as reported by each block left open at the end of the input,
+// is dropped, as are errors past the WithMaxErrors limit.
+func (d *document) addError(err error) {
+	if d.full() {
+		return
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		pe = &ParseError{Err: err}
+	}
+	if n := len(d.errs); n > 0 {
+		prev := d.errs[n-1]
+		if prev.File == pe.File && prev.Line == pe.Line && prev.Column == pe.Column && prev.Err.Error() == pe.Err.Error() {
+			return
+		}
+	}
+	d.errs = append(d.errs, pe)
+}
+
+// warn records a warning at pos and passes it to the WithWarningHandler
+// handler, if any.
+func (d *document) warn(pos position, code Code, msg string) {
+	w := Warning{File: pos.file, Line: pos.line, Column: pos.col, Code: code, Message: msg}
+	d.warnings = append(d.warnings, w)
+	d.opts.emit(w)
+}
+
+// err returns the recorded errors, or nil if there were none.
+func (d *document) err() error {
+	if len(d.errs) == 0 {
+		return nil
+	}
+	return d.snapshot(d.errs)
+}
+
+func parseRspamdConfig(r io.Reader, opts *parseOptions) (*document, error) {
+	doc := &document{root: newSection(), opts: opts}
+	p := &parser{
+		l:    doc.newLexer(r, opts.filename),
+		opts: opts,
+		doc:  doc,
+		dir:  opts.includeDir,
+	}
+	if err := p.parseFile(doc.root); err != nil && !errors.Is(err, errTooManyErrors) {
+		return nil, doc.snapshot(err)
+	}
+	return doc, nil
+}
+
+// parseFile parses the whole input of p into sec. Errors without a position
+// are attributed to the last token read.
+func (p *parser) parseFile(sec *Section) error {
+	if err := p.parseSection(sec, tokenEOF); err != nil {
+		if p.opts.snippets {
+			p.l.drain()
+		}
+		return errorAt(p.l.tok, err)
+	}
+	return nil
+}
+
+// parseSection reads assignments, nested blocks and directives into sec
+// until the end token is reached. Keys are separated from their values by
+// `=`, `:` or plain whitespace. Repeated keys are resolved according to the
+// priority and duplicate policy of the file they come from.
+func (p *parser) parseSection(sec *Section, end tokenType) error {
+	l := p.l
 	for {
 		tok, err := l.next()
+		atEOF := err == nil && tok.typ == tokenEOF
+		if err == nil {
+			if tok.typ == end {
+				return nil
+			}
+			err = p.parseEntry(sec, tok)
+		}
+		if err == nil {
+			continue
+		}
+		if !p.opts.recovery || l.err != nil || errors.Is(err, errTooManyErrors) {
+			return err
+		}
+		p.doc.addError(errorAt(l.tok, err))
+		if p.doc.full() {
+			return errTooManyErrors
+		}
+		if atEOF {
+			// A block left open at the end of the input.
+			return nil
+		}
+		p.opts.tracef(l.tok, "recover from %v: skip to the next entry", err)
+		p.skipEntry(end)
+	}
+}
+
+// parseEntry parses the assignment, block or directive starting with tok.
+func (p *parser) parseEntry(sec *Section, tok token) error {
+	l := p.l
+	switch tok.typ {
+	case tokenDirective:
+		return p.parseDirective(sec, tok)
+	case tokenIdent, tokenString:
+		key := tok.val
+		if tok.typ == tokenIdent && strings.HasPrefix(key, "$") {
+			return p.parseVariable(tok)
+		}
+		if _, ok := sec.priority[key]; !ok {
+			if err := p.opts.checkEntries(len(sec.priority) + 1); err != nil {
+				return errorAt(tok.pos, err)
+			}
+		}
+		next, err := l.next()
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		switch tok.typ {
-		case tokenEOF:
-			return assignments, domains, nil
-		case tokenIdent:
-			if tok.val == "domain" {
-				if err := parseDomainBlock(l, domains); err != nil {
-					return nil, nil, err
-				}
-				continue
+		if next.typ == tokenEqual || next.typ == tokenColon {
+			if next, err = l.next(); err != nil {
+				return err
+			}
+		}
+		if next.typ == tokenLBrace {
+			action, err := p.resolveDuplicate(sec, key, kindSection, tok.pos)
+			if err != nil {
+				return errorAt(tok.pos, err)
 			}
-			key := tok.val
-			if err := expect(l, tokenEqual); err != nil {
-				return nil, nil, err
+			if action != actionSkip {
+				sec.note(key, tok)
 			}
-			val, err := parseValue(l)
+			child, ok := sec.Sections[key]
+			if !ok || action != actionMerge {
+				child = newSection()
+			}
+			if max := p.opts.limits.Depth; max > 0 && p.doc.depth >= max {
+				return errorAt(tok.pos, fmt.Errorf("%w: sections nested deeper than %d", ErrLimitExceeded, max))
+			}
+			p.opts.tracef(tok.pos, "enter block %q (%v)", key, action)
+			p.doc.depth++
+			err = p.parseSection(child, tokenRBrace)
+			p.doc.depth--
 			if err != nil {
-				return nil, nil, err
+				return err
+			}
+			p.opts.tracef(l.tok, "leave block %q", key)
+			if action != actionSkip {
+				sec.Sections[key] = child
 			}
-			assignments[key] = val
-			_, _ = tryConsume(l, tokenSemicolon)
+			skipSeparator(l)
+			return nil
+		}
+		if next.typ == tokenLBracket {
+			if ok, err := tryConsume(l, tokenLBrace); err != nil {
+				return err
+			} else if ok {
+				return p.parseBlockArray(sec, key, tok)
+			}
+			items, err := parseArray(l)
+			if err != nil {
+				return err
+			}
+			list := make([]string, len(items))
+			elems := make([]element, len(items))
+			for i, item := range items {
+				if list[i], err = p.doc.expand(item.val); err != nil {
+					return errorAt(item.pos, err)
+				}
+				elems[i] = element{pos: item.pos, quoted: item.typ == tokenString}
+			}
+			action, err := p.resolveDuplicate(sec, key, kindArray, tok.pos)
+			if err != nil {
+				return errorAt(tok.pos, err)
+			}
+			p.opts.tracef(tok.pos, "array %q with %d elements (%v)", key, len(list), action)
+			switch action {
+			case actionSet:
+				sec.note(key, tok)
+				sec.Arrays[key] = list
+				sec.elems[key] = elems
+			case actionMerge:
+				sec.note(key, tok)
+				sec.Arrays[key] = append(sec.Arrays[key], list...)
+				sec.elems[key] = append(sec.elems[key], elems...)
+			case actionCollect:
+				sec.collect(key, tok, list, elems)
+			}
+			if err := p.opts.checkEntries(len(sec.Arrays[key])); err != nil {
+				return errorAt(tok.pos, err)
+			}
+			skipSeparator(l)
+			return nil
+		}
+		l.unread(next)
+		val, err := parseValueToken(l)
+		if err != nil {
+			return err
+		}
+		action, err := p.resolveDuplicate(sec, key, kindScalar, tok.pos)
+		if err != nil {
+			return errorAt(tok.pos, err)
+		}
+		p.opts.tracef(tok.pos, "assign %q = %q (%v)", key, val.val, action)
+		if action == actionSet || action == actionCollect {
+			if val.val, err = p.doc.expand(val.val); err != nil {
+				return errorAt(val.pos, err)
+			}
+		}
+		switch action {
+		case actionSet:
+			sec.Values[key] = val.val
+			sec.quoted[key] = val.typ == tokenString
+			sec.note(key, tok)
+		case actionCollect:
+			sec.collect(key, tok, []string{val.val}, []element{{pos: val.pos, quoted: val.typ == tokenString}})
+		}
+		skipSeparator(l)
+		return nil
+	default:
+		return withCode(CodeSyntax, fmt.Errorf("unexpected token: %v", tok.typ))
+	}
+}
+
+// parseBlockArray parses an array of blocks for key, such as
+// `selectors [ { ... }, { ... } ]`, after its first opening brace.
+func (p *parser) parseBlockArray(sec *Section, key string, tok token) error {
+	l := p.l
+	var blocks []*Section
+	for done := false; !done; {
+		if max := p.opts.limits.Depth; max > 0 && p.doc.depth >= max {
+			return errorAt(tok.pos, fmt.Errorf("%w: sections nested deeper than %d", ErrLimitExceeded, max))
+		}
+		child := newSection()
+		p.doc.depth++
+		err := p.parseSection(child, tokenRBrace)
+		p.doc.depth--
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, child)
+		if err := p.opts.checkEntries(len(blocks)); err != nil {
+			return errorAt(tok.pos, err)
+		}
+		next, err := l.next()
+		if err == nil && next.typ == tokenComma {
+			next, err = l.next()
+		}
+		if err != nil {
+			return err
+		}
+		switch next.typ {
+		case tokenRBracket:
+			done = true
+		case tokenLBrace:
 		default:
-			return nil, nil, fmt.Errorf("unexpected token: %v", tok.typ)
+			return errorAt(next.pos, withCode(CodeSyntax, fmt.Errorf("expected block in array %q, got %v", key, next.typ)))
 		}
 	}
+	action, err := p.resolveDuplicate(sec, key, kindArray, tok.pos)
+	if err != nil {
+		return errorAt(tok.pos, err)
+	}
+	p.opts.tracef(tok.pos, "array %q with %d blocks (%v)", key, len(blocks), action)
+	switch action {
+	case actionSet:
+		sec.note(key, tok)
+		sec.Blocks[key] = blocks
+	case actionMerge, actionCollect:
+		sec.note(key, tok)
+		sec.Blocks[key] = append(sec.Blocks[key], blocks...)
+	}
+	skipSeparator(l)
+	return nil
 }
 
-func parseDomainBlock(l *lexer, domains map[string]map[string]string) error {
-	if err := expect(l, tokenLBrace); err != nil {
+// parseVariable parses a `$name = value;` definition. The value, with
+// earlier variables expanded, replaces $name and ${name} in the values and
+// include paths that follow, in this file and the files it includes.
+func (p *parser) parseVariable(tok token) error {
+	l := p.l
+	name := tok.val[1:]
+	for i := 0; i < len(name); i++ {
+		if !isMacroChar(name[i]) {
+			name = ""
+			break
+		}
+	}
+	if name == "" {
+		return errorAt(tok.pos, withCode(CodeSyntax, fmt.Errorf("invalid variable name %q", tok.val)))
+	}
+	next, err := l.next()
+	if err != nil {
 		return err
 	}
+	if next.typ != tokenEqual && next.typ != tokenColon {
+		return errorAt(next.pos, withCode(CodeSyntax, fmt.Errorf("expected = after variable %s", tok.val)))
+	}
+	if next, err = l.next(); err != nil {
+		return err
+	}
+	if next.typ == tokenLBrace || next.typ == tokenLBracket {
+		return errorAt(next.pos, withCode(CodeSyntax, fmt.Errorf("variable %s must be a single value", tok.val)))
+	}
+	l.unread(next)
+	val, err := parseValueToken(l)
+	if err != nil {
+		return err
+	}
+	if val.val, err = p.doc.expand(val.val); err != nil {
+		return errorAt(val.pos, err)
+	}
+	p.opts.tracef(tok.pos, "define %s = %q", tok.val, val.val)
+	p.doc.define(name, val.val)
+	skipSeparator(l)
+	return nil
+}
+
+// skipEntry discards the rest of an entry after an error in recovery mode.
+// It stops after a separator, before the next key on a later line, or before
+// the brace closing the enclosing block, whichever comes first.
+func (p *parser) skipEntry(end tokenType) {
+	l := p.l
+	if l.peek == nil {
+		switch l.last.typ {
+		case tokenSemicolon, tokenComma:
+			return
+		case tokenRBrace:
+			if end == tokenRBrace {
+				l.unread(l.last)
+			}
+			return
+		}
+	}
+	line := l.tok.line
+	depth := 0
 	for {
 		tok, err := l.next()
 		if err != nil {
-			return err
+			if l.err != nil {
+				return
+			}
+			continue
 		}
 		switch tok.typ {
-		case tokenRBrace:
-			_, _ = tryConsume(l, tokenSemicolon)
-			return nil
-		case tokenIdent, tokenString:
-			domainKey := tok.val
-			if err := expect(l, tokenLBrace); err != nil {
-				return err
+		case tokenEOF:
+			l.unread(tok)
+			return
+		case tokenLBrace, tokenLBracket:
+			depth++
+		case tokenRBrace, tokenRBracket:
+			if depth > 0 {
+				depth--
+			} else if tok.typ == tokenRBrace && end == tokenRBrace {
+				l.unread(tok)
+				return
 			}
-			rule := make(map[string]string)
-			for {
-				t, err := l.next()
-				if err != nil {
-					return err
-				}
-				if t.typ == tokenRBrace {
-					_, _ = tryConsume(l, tokenSemicolon)
-					break
-				}
-				if t.typ != tokenIdent {
-					return fmt.Errorf("unexpected token in domain rule: %v", t.typ)
-				}
-				if err := expect(l, tokenEqual); err != nil {
-					return err
-				}
-				val, err := parseValue(l)
-				if err != nil {
-					return err
-				}
-				rule[t.val] = val
-				_, _ = tryConsume(l, tokenSemicolon)
+		case tokenSemicolon, tokenComma:
+			if depth == 0 {
+				return
+			}
+		case tokenIdent, tokenString, tokenDirective:
+			if depth == 0 && tok.pos.line > line {
+				l.unread(tok)
+				return
 			}
-			domains[domainKey] = rule
-		default:
-			return fmt.Errorf("unexpected token in domain block: %v", tok.typ)
 		}
 	}
 }
 
 func parseValue(l *lexer) (string, error) {
+	tok, err := parseValueToken(l)
+	return tok.val, err
+}
+
+// parseValueToken is parseValue returning the whole token.
+func parseValueToken(l *lexer) (token, error) {
 	tok, err := l.next()
 	if err != nil {
-		return "", err
+		return token{}, err
 	}
 	switch tok.typ {
 	case tokenIdent, tokenString:
-		return tok.val, nil
+		return tok, nil
 	default:
-		return "", fmt.Errorf("unexpected value token: %v", tok.typ)
+		return token{}, withCode(CodeSyntax, fmt.Errorf("unexpected value token: %v", tok.typ))
+	}
+}
+
+// parseArray reads comma separated scalar values up to the closing bracket.
+// A trailing comma before the bracket is allowed.
+func parseArray(l *lexer) ([]token, error) {
+	out := []token{}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.typ == tokenRBracket {
+			return out, nil
+		}
+		l.unread(tok)
+		val, err := parseValueToken(l)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, val)
+		tok, err = l.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.typ {
+		case tokenComma:
+		case tokenRBracket:
+			return out, nil
+		default:
+			return nil, withCode(CodeSyntax, fmt.Errorf("unexpected token in array: %v", tok.typ))
+		}
 	}
 }
 
@@ -421,7 +1654,7 @@ func expect(l *lexer, typ tokenType) error {
 		return err
 	}
 	if tok.typ != typ {
-		return fmt.Errorf("expected token %v, got %v", typ, tok.typ)
+		return withCode(CodeSyntax, fmt.Errorf("expected token %v, got %v", typ, tok.typ))
 	}
 	return nil
 }
@@ -438,14 +1671,22 @@ func tryConsume(l *lexer, typ tokenType) (bool, error) {
 	return false, nil
 }
 
+// skipSeparator consumes the optional `;` or `,` that ends an entry.
+func skipSeparator(l *lexer) {
+	if ok, _ := tryConsume(l, tokenSemicolon); !ok {
+		_, _ = tryConsume(l, tokenComma)
+	}
+}
+
+// parseBool accepts the boolean spellings understood by UCL.
 func parseBool(val string) (bool, error) {
 	switch strings.ToLower(val) {
-	case "true":
+	case "true", "yes", "on", "1":
 		return true, nil
-	case "false":
+	case "false", "no", "off", "0":
 		return false, nil
 	default:
-		return false, fmt.Errorf("invalid boolean %q", val)
+		return false, withCode(CodeInvalidValue, fmt.Errorf("invalid boolean %q", val))
 	}
 }
 
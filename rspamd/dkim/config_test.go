@@ -1,8 +1,16 @@
 package dkim
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -29,6 +37,81 @@ func TestParseDKIMConf(t *testing.T) {
 	require.False(t, *conf2.Enabled)
 }
 
+func TestParseDKIMConfVerifyPolicy(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader("check_pubkey = yes;\nmin_bits = 2048;\n"), WithStrict())
+	require.NoError(t, err)
+	require.True(t, *conf.CheckPubkey)
+	require.Equal(t, 2048, conf.MinBits)
+
+	conf, err = ParseDKIMConf(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Nil(t, conf.CheckPubkey)
+	require.Zero(t, conf.MinBits)
+
+	_, err = ParseDKIMConf(strings.NewReader("min_bits = 2k;\n"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse min_bits: invalid integer "2k"`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+	_, err = ParseDKIMConf(strings.NewReader("check_pubkey = maybe;\n"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse check_pubkey: invalid boolean "maybe"`)
+}
+
+func TestParseDKIMConfTrustedOnlySkipMulti(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader("trusted_only = true;\nskip_multi = off;\n"), WithStrict())
+	require.NoError(t, err)
+	require.True(t, *conf.TrustedOnly)
+	require.False(t, *conf.SkipMulti)
+
+	conf, err = ParseDKIMConf(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Nil(t, conf.TrustedOnly)
+	require.Nil(t, conf.SkipMulti)
+
+	_, err = ParseDKIMConf(strings.NewReader("skip_multi = 2;\n"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse skip_multi: invalid boolean "2"`)
+}
+
+func TestParseDKIMConfTimeJitterMaxSigs(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader("time_jitter = 6h;\nmax_sigs = 5;\n"), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, 6*time.Hour, conf.TimeJitter)
+	require.Equal(t, 5, conf.MaxSigs)
+
+	conf, err = ParseDKIMConf(strings.NewReader("time_jitter = 30;\n"))
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, conf.TimeJitter)
+	require.Zero(t, conf.MaxSigs)
+
+	_, err = ParseDKIMConf(strings.NewReader("max_sigs = 0;\n"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse max_sigs: max_sigs 0 must be positive`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+	_, err = ParseDKIMConf(strings.NewReader("max_sigs = \"-2\";\n"))
+	require.ErrorContains(t, err, "max_sigs -2 must be positive")
+	_, err = ParseDKIMConf(strings.NewReader("time_jitter = \"-1s\";\n"))
+	require.ErrorContains(t, err, "time_jitter -1s is negative")
+}
+
+func TestParseDKIMConfSymbols(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader(`
+symbol = "DKIM_CHECK";
+symbol_allow = "MY_DKIM_OK";
+symbol_reject = "MY_DKIM_BAD";
+`), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, "DKIM_CHECK", conf.Symbol)
+	require.Equal(t, "MY_DKIM_OK", conf.SymbolAllow)
+	require.Empty(t, conf.SymbolNA)
+	require.Equal(t, DKIMSymbols{
+		Allow:    "MY_DKIM_OK",
+		Reject:   "MY_DKIM_BAD",
+		Tempfail: "R_DKIM_TEMPFAIL",
+		NA:       "R_DKIM_NA",
+		Permfail: "R_DKIM_PERMFAIL",
+	}, conf.Symbols())
+
+	var none *DKIMConf
+	require.Equal(t, DefaultDKIMSymbols, none.Symbols())
+}
+
 func TestParseSignHeaders(t *testing.T) {
 	raw := "(o)from:(x)sender:(o)reply-to:(o)subject:(x)date:" +
 		"(o)to:(o)cc:(x)mime-version:(x)content-type:(x)content-transfer-encoding:" +
@@ -92,6 +175,125 @@ func TestParseDKIMSigningConf(t *testing.T) {
 	require.Equal(t, "/etc/rspamd/local.d/maps.d/dkim_selectors.map", conf2.SelectorMap)
 }
 
+func TestParseDKIMSigningConfSenderFlags(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+auth_only = false;
+allow_envfrom_empty = true;
+allow_hdrfrom_mismatch_local = yes;
+allow_hdrfrom_multiple = off;
+`), WithStrict())
+	require.NoError(t, err)
+	require.False(t, *conf.AuthOnly)
+	require.True(t, *conf.AllowEnvfromEmpty)
+	require.True(t, *conf.AllowHdrFromMismatchLocal)
+	require.False(t, *conf.AllowHdrFromMultiple)
+	require.Equal(t, []string{"allow_envfrom_empty", "allow_hdrfrom_mismatch_local"}, Summarize(EffectiveSigningConf{Conf: conf}).Features)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Nil(t, conf.AuthOnly)
+	require.Nil(t, conf.AllowEnvfromEmpty)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("allow_hdrfrom_multiple = 1x;\n"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse allow_hdrfrom_multiple: invalid boolean "1x"`)
+}
+
+func TestParseDKIMSigningConfRedis(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+use_redis = true;
+key_prefix = "DKIM_PRIVKEYS";
+selector_prefix = "DKIM_SELECTORS";
+`), WithStrict())
+	require.NoError(t, err)
+	require.True(t, *conf.UseRedis)
+	require.Equal(t, "DKIM_PRIVKEYS", conf.KeyPrefix)
+	require.Equal(t, "DKIM_SELECTORS", conf.SelectorPrefix)
+	require.Equal(t, []string{"key_prefix", "selector_prefix", "use_redis"}, Summarize(EffectiveSigningConf{Conf: conf}).Features)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`use_redis = sometimes;`))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse use_redis: invalid boolean "sometimes"`)
+}
+
+func TestParseDKIMSigningConfSelectors(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+domain {
+  example.com {
+    path = "/var/lib/rspamd/dkim/$domain.$selector.key";
+    selectors [
+      { selector = "rsa"; path = "/var/lib/rspamd/dkim/rsa.key"; },
+      { selector = "ed25519"; }
+    ]
+  }
+  example.org {
+    selectors = ["s1", "s2"];
+  }
+}
+`), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, []DomainSelector{
+		{Selector: "rsa", Path: "/var/lib/rspamd/dkim/rsa.key"},
+		{Selector: "ed25519"},
+	}, conf.Domain["example.com"].Selectors)
+	require.Equal(t, []DomainSelector{{Selector: "s1"}, {Selector: "s2"}}, conf.Domain["example.org"].Selectors)
+
+	keys := EffectiveSigningConf{Conf: conf}.ResolveAll("example.com")
+	require.Equal(t, []SigningKey{
+		{Domain: "example.com", Selector: "rsa", Path: "/var/lib/rspamd/dkim/rsa.key", Source: "domain"},
+		{Domain: "example.com", Selector: "ed25519", Path: "/var/lib/rspamd/dkim/example.com.ed25519.key", Source: "domain"},
+	}, keys)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`domain { a.com { selectors [ { path = "/k"; } ] } }`))
+	require.EqualError(t, err, `line 1, column 18: [DKIMCONF0004] parse a.com.selectors: entry 1 has no selector`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`domain { a.com { selectors [ { selector = "s"; } "b" ] } }`))
+	require.Error(t, err)
+	require.Equal(t, CodeSyntax, CodeOf(err))
+}
+
+func TestParseDKIMSigningConfRawKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	block := strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	seed := base64.StdEncoding.EncodeToString(key.Seed())
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+domain {
+  a.com {
+    selector = "pem";
+    key = <<EOD
+      `+strings.ReplaceAll(block, "\n", "\n      ")+`
+EOD;
+  }
+  b.com {
+    selector = "raw";
+    key = "`+seed[:20]+`
+      `+seed[20:]+`";
+  }
+}
+`), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, block, conf.Domain["a.com"].RawKey)
+
+	eff := EffectiveSigningConf{Conf: conf}
+	for _, domain := range []string{"a.com", "b.com"} {
+		k, ok := eff.Resolve(domain)
+		require.True(t, ok, domain)
+		require.Empty(t, k.Path)
+		keys, err := eff.Plan(domain, AlgorithmPolicy{Default: []Algorithm{Ed25519SHA256}})
+		require.NoError(t, err, domain)
+		require.Equal(t, Ed25519SHA256, keys[0].Algorithm)
+		signer, err := ParseRawKey(k.RawKey)
+		require.NoError(t, err)
+		require.True(t, key.Equal(signer))
+	}
+
+	_, err = ParseRawKey("not base64!")
+	require.ErrorContains(t, err, "decode raw key")
+}
+
 func TestParseDKIMSelectorsMap(t *testing.T) {
 	f, err := os.Open("../../examples/3/maps.d/dkim_selectors.map")
 	require.NoError(t, err)
@@ -125,3 +327,327 @@ func TestParseSignedDomainsMap(t *testing.T) {
 	require.NotEmpty(t, m)
 	require.Equal(t, "/var/lib/rspamd/dkim/c1.dkim.domain.com.key", m["@go.test.com"])
 }
+
+func TestParseWhitelistedSignersMap(t *testing.T) {
+	list, err := ParseWhitelistedSignersMap(strings.NewReader("# trusted ESPs\nesp.example\n\nmailer.example.net  partner # since 2023\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"esp.example", "mailer.example.net"}, list)
+
+	_, err = ParseWhitelistedSignersMap(strings.NewReader("a.com\nb.com\n"), WithLimits(Limits{MapEntries: 1}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	conf, err := ParseDKIMConf(strings.NewReader(`whitelisted_signers_map = "$LOCAL_CONFDIR/local.d/maps.d/dkim_whitelist.inc";`), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, []string{"/etc/rspamd/local.d/maps.d/dkim_whitelist.inc"}, conf.WhitelistedSignersMap)
+	conf, err = ParseDKIMConf(strings.NewReader(`whitelisted_signers_map = ["a.com", "b.com"];`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.com", "b.com"}, conf.WhitelistedSignersMap)
+}
+
+func TestParseNestedSections(t *testing.T) {
+	input := `
+selector = "s1";
+domain {
+  example.com {
+    selector = "mail";
+    path = "/var/lib/rspamd/dkim/example.com.key";
+    extra {
+      nested = "yes";
+    }
+  }
+}
+sign_headers {
+  from = "(o)";
+}
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "mail", conf.Domain["example.com"].Selector)
+	require.Equal(t, "(o)", conf.Sections["sign_headers"].Values["from"])
+	require.Equal(t, "yes", conf.Sections["domain"].Sections["example.com"].Sections["extra"].Values["nested"])
+
+	dconf, err := ParseDKIMConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Contains(t, dconf.Sections, "sign_headers")
+}
+
+func TestParseArrays(t *testing.T) {
+	input := `
+sign_networks = ["10.0.0.0/8", "192.168.1.0/24",];
+bare [ a, b ];
+domain {
+  example.com {
+    selectors = ["rsa", "ed25519"];
+  }
+}
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.1.0/24"}, conf.Arrays["sign_networks"])
+	require.Equal(t, []string{"a", "b"}, conf.Arrays["bare"])
+	require.Equal(t, []string{"rsa", "ed25519"}, conf.Sections["domain"].Sections["example.com"].Arrays["selectors"])
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`list = ["a" "b"];`))
+	require.Error(t, err)
+}
+
+func TestParseAssignmentStyles(t *testing.T) {
+	input := `
+enabled: true;
+selector "s1";
+path = "/var/lib/rspamd/dkim/$domain.key";
+domain: {
+  example.com {
+    selector: "mail";
+    path "/var/lib/rspamd/dkim/example.com.key";
+  }
+}
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.NotNil(t, conf.Enabled)
+	require.True(t, *conf.Enabled)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/var/lib/rspamd/dkim/$domain.key", conf.Path)
+	require.Equal(t, DomainRule{Selector: "mail", Path: "/var/lib/rspamd/dkim/example.com.key"}, conf.Domain["example.com"])
+}
+
+func TestParseCStyleComments(t *testing.T) {
+	input := `
+// line comment
+selector = "s1"; // trailing comment
+/* block
+   comment /* nested */ still comment
+*/
+path = "/var/lib/rspamd/dkim//*keys*/";
+use_domain = header; /**/
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/var/lib/rspamd/dkim//*keys*/", conf.Path)
+	require.Equal(t, "header", conf.UseDomain)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("/* never closed"))
+	require.Error(t, err)
+}
+
+func TestParseSingleQuotedStrings(t *testing.T) {
+	input := `
+selector = 's1';
+path = 'C:\keys\$domain.key';
+use_domain = 'it\'s';
+domain {
+  'example.com' {
+    selector = 'mail';
+  }
+}
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, `C:\keys\$domain.key`, conf.Path)
+	require.Equal(t, "it's", conf.UseDomain)
+	require.Equal(t, "mail", conf.Domain["example.com"].Selector)
+}
+
+func TestParseQuotedKeys(t *testing.T) {
+	input := `
+"selector" = "s1";
+'path': "/keys/$domain.key";
+"strange key" "value";
+"sign_headers" = ["from", "to"];
+domain {
+  "example.com" { "selector" = "mail"; }
+}
+.include(try = true, "priority" = 2) "/nonexistent.conf"
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/keys/$domain.key", conf.Path)
+	require.Equal(t, []string{"from", "to"}, conf.Arrays["sign_headers"])
+	require.Equal(t, "mail", conf.Domain["example.com"].Selector)
+
+	val, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "value", val.Get("strange key").Raw)
+}
+
+func TestParseHeredoc(t *testing.T) {
+	input := "sign_condition = <<EOD\n" +
+		"return function(task)\n" +
+		"  return false\n" +
+		"end\n" +
+		"EOD;\n" +
+		"selector = \"s1\";\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "return function(task)\n  return false\nend", conf.SignCondition)
+	require.Contains(t, Summarize(EffectiveSigningConf{Conf: conf}).Features, "sign_condition")
+
+	out, err := Marshal(map[string]string{"sign_condition": conf.SignCondition})
+	require.NoError(t, err)
+	again, err := ParseDKIMSigningConf(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.Equal(t, conf.SignCondition, again.SignCondition)
+
+	doc, err := parseRspamdConfig(strings.NewReader(input), newParseOptions(nil))
+	require.NoError(t, err)
+	require.Equal(t, "return function(task)\n  return false\nend", doc.root.Values["sign_condition"])
+
+	_, err = parseRspamdConfig(strings.NewReader("x = <<EOD\nbody\n"), newParseOptions(nil))
+	require.Error(t, err)
+	_, err = parseRspamdConfig(strings.NewReader("x = <<eod\nbody\neod\n"), newParseOptions(nil))
+	require.Error(t, err)
+}
+
+func TestParseEscapes(t *testing.T) {
+	input := `use_domain = "tab\there\nline \"q\" \\ \u00e9 \ud83d\ude00 \/";`
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "tab\there\nline \"q\" \\ é 😀 /", conf.UseDomain)
+
+	raw, err := ParseDKIMSigningConf(strings.NewReader(input), WithRawEscapes())
+	require.NoError(t, err)
+	require.Equal(t, `tab\there\nline \"q\" \\ \u00e9 \ud83d\ude00 \/`, raw.UseDomain)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`use_domain = "\uZZZZ";`))
+	require.Error(t, err)
+}
+
+func TestParseBoolVariants(t *testing.T) {
+	input := `
+enabled = yes;
+sign_local = ON;
+sign_inbound = off;
+use_esld = 0;
+try_fallback = 1;
+allow_username_mismatch = No;
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.True(t, *conf.Enabled)
+	require.True(t, *conf.SignLocal)
+	require.False(t, *conf.SignInbound)
+	require.False(t, *conf.UseESLD)
+	require.True(t, *conf.TryFallback)
+	require.False(t, *conf.AllowUsernameMismatch)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`enabled = maybe;`))
+	require.Error(t, err)
+}
+
+func TestParseCommaSeparators(t *testing.T) {
+	input := `
+selector = "s1", path = "/keys/$domain.key",
+domain {
+  a.com { selector = "a", path = "/keys/a.key", },
+  b.com { selector = "b"; },
+}
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/keys/$domain.key", conf.Path)
+	require.Equal(t, DomainRule{Selector: "a", Path: "/keys/a.key"}, conf.Domain["a.com"])
+	require.Equal(t, "b", conf.Domain["b.com"].Selector)
+}
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	input := "selector = \"s1\";\nselector = \"s2\";\nsign_headers = [\"from\"];\nsign_headers = [\"to\"];\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s2", conf.Selector)
+	require.Equal(t, []string{"to"}, conf.Arrays["sign_headers"])
+	require.Equal(t, []Warning{
+		{Line: 2, Column: 1, Code: CodeDuplicateKey, Message: `duplicate key "selector", keeping the last value`},
+		{Line: 4, Column: 1, Code: CodeDuplicateKey, Message: `duplicate key "sign_headers", keeping the last value`},
+		{Line: 4, Column: 1, Code: CodeIgnoredKey, Message: `unknown key "sign_headers" ignored`},
+	}, conf.Warnings)
+	require.Equal(t, `line 2, column 1: duplicate key "selector", keeping the last value`, conf.Warnings[0].String())
+
+	var seen []Warning
+	conf, err = ParseDKIMSigningConf(strings.NewReader(input), WithDuplicateKeys(DuplicateKeepFirst), WithWarningHandler(func(w Warning) {
+		seen = append(seen, w)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, []string{"from"}, conf.Arrays["sign_headers"])
+	require.Equal(t, conf.Warnings, seen)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithDuplicateKeys(DuplicateFail))
+	requireParseError(t, err, "", 2, 1)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(input+"selector = s3;\n"), WithDuplicateKeys(DuplicateCollect))
+	require.NoError(t, err)
+	require.Empty(t, conf.Selector)
+	require.Equal(t, []string{"s1", "s2", "s3"}, conf.Arrays["selector"])
+	require.Equal(t, []string{"from", "to"}, conf.Arrays["sign_headers"])
+	require.Len(t, conf.Warnings, 4)
+}
+
+func TestDuplicateKeyPolicyIncludePriority(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"override.conf": `selector = "override";`,
+	})
+	input := "selector = \"s1\";\n.include(priority=5) \"" + dir + "/override.conf\"\n"
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithDuplicateKeys(DuplicateFail))
+	require.NoError(t, err)
+	require.Equal(t, "override", conf.Selector)
+	require.Empty(t, conf.Warnings)
+}
+
+func TestParseWrappedModuleSections(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`dkim_signing {
+  selector = "s1";
+  domain {
+    a.com { path = "/a.key"; }
+  }
+}
+`), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/a.key", conf.Domain["a.com"].Path)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader("selector = \"s1\";\ndkim_signing { selector = \"s2\"; }\n"))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+
+	module, err := ParseDKIMConf(strings.NewReader(`dkim { sign_headers = "from:to"; }`))
+	require.NoError(t, err)
+	require.Equal(t, "from:to", module.SignHeaders)
+}
+
+func TestKeyOrder(t *testing.T) {
+	input := `
+selector = "s1";
+path = "/keys/$domain.key";
+domain {
+  z.com { path = "/z.key"; selector = "z"; }
+  a.com { selector = "a"; }
+  m.com { selector = "m"; }
+}
+enabled = true;
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []string{"selector", "path", "domain", "enabled"}, conf.Keys)
+	require.Equal(t, []string{"z.com", "a.com", "m.com"}, conf.DomainNames())
+	require.Equal(t, []string{"path", "selector"}, conf.Sections["domain"].Sections["z.com"].Keys())
+
+	conf.Domain["b.com"] = DomainRule{Selector: "b"}
+	require.Equal(t, []string{"z.com", "a.com", "m.com", "b.com"}, conf.DomainNames())
+
+	eff := MigrateToDomainRules(EffectiveSigningConf{Conf: conf, Maps: &Maps{Selectors: map[string]string{"c.com": "c"}}})
+	require.Equal(t, []string{"z.com", "a.com", "m.com", "b.com", "c.com"}, eff.Conf.DomainNames())
+	require.Equal(t, []string{"z.com", "a.com", "m.com", "b.com", "c.com"}, eff.Conf.Sections["domain"].Keys())
+
+	dkimConf, err := ParseDKIMConf(strings.NewReader("sign_headers = \"from\";\nenabled = true;\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"sign_headers", "enabled"}, dkimConf.Keys)
+}
@@ -0,0 +1,75 @@
+package dkim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubdomainCoverage tells whether mail from a subdomain gets signed and
+// with which key. Lookup is the domain rspamd looks up: the organizational
+// domain when use_esld is on, which is the default, or Domain itself.
+// Signed mail uses Key; otherwise the subdomain needs an explicit domain
+// rule or map entry (with use_esld off) to be signed.
+type SubdomainCoverage struct {
+	Domain string
+	Lookup string
+	Signed bool
+	Key    SigningKey
+	Reason string
+}
+
+// CheckSubdomains reports for each of subdomains of parent whether mail
+// with that From domain is signed, answering questions such as "will mail
+// from news.example.com get signed?". Subdomains may be given as full names
+// or as labels relative to parent, as in "news". The organizational domain
+// is approximated as described for CheckDelegation.
+func CheckSubdomains(eff EffectiveSigningConf, parent string, subdomains []string) []SubdomainCoverage {
+	conf := eff.Conf
+	if conf == nil {
+		conf = &DKIMSigningConf{}
+	}
+	useESLD := conf.UseESLD == nil || *conf.UseESLD
+	parent = domainKey(parent)
+
+	out := make([]SubdomainCoverage, 0, len(subdomains))
+	for _, sub := range subdomains {
+		sub = domainKey(sub)
+		if sub != parent && !strings.HasSuffix(sub, "."+parent) {
+			sub += "." + parent
+		}
+		c := SubdomainCoverage{Domain: sub, Lookup: sub}
+		if useESLD {
+			c.Lookup = orgDomain(sub)
+		}
+		c.Key, c.Signed = eff.Resolve(c.Lookup)
+		c.Reason = c.reason(conf, useESLD)
+		out = append(out, c)
+	}
+	return out
+}
+
+func (c SubdomainCoverage) reason(conf *DKIMSigningConf, useESLD bool) string {
+	var why string
+	switch {
+	case !c.Signed && conf.TryFallback != nil && !*conf.TryFallback:
+		why = fmt.Sprintf("not signed: no domain rule or map entry for %s and try_fallback is off", c.Lookup)
+	case !c.Signed:
+		why = fmt.Sprintf("not signed: no selector and path for %s", c.Lookup)
+	case c.Key.Source == "domain":
+		why = fmt.Sprintf("signed as d=%s by a domain rule", c.Key.Domain)
+	case c.Key.Source == "map":
+		why = fmt.Sprintf("signed as d=%s by the selector or path map", c.Key.Domain)
+	default:
+		why = fmt.Sprintf("signed as d=%s with the global selector and path (try_fallback)", c.Key.Domain)
+	}
+	if useESLD && c.Lookup != c.Domain {
+		why += fmt.Sprintf("; use_esld looks up %s", c.Lookup)
+		for key := range conf.Domain {
+			if domainKey(key) == c.Domain {
+				why += fmt.Sprintf(", so the %s rule is not used", key)
+				break
+			}
+		}
+	}
+	return why
+}
@@ -0,0 +1,50 @@
+package dkim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSubdomains(t *testing.T) {
+	no := false
+	conf := &DKIMSigningConf{
+		Domain: map[string]DomainRule{
+			"example.com":      {Selector: "s1", Path: "/keys/example.com.key"},
+			"news.example.com": {Selector: "news", Path: "/keys/news.key"},
+		},
+	}
+	eff := EffectiveSigningConf{Conf: conf}
+
+	got := CheckSubdomains(eff, "example.com", []string{"news", "shop.example.com."})
+	require.Len(t, got, 2)
+	require.Equal(t, "news.example.com", got[0].Domain)
+	require.Equal(t, "example.com", got[0].Lookup)
+	require.True(t, got[0].Signed)
+	require.Equal(t, "s1", got[0].Key.Selector)
+	require.Equal(t, "signed as d=example.com by a domain rule; use_esld looks up example.com, so the news.example.com rule is not used", got[0].Reason)
+	require.Equal(t, "shop.example.com", got[1].Domain)
+	require.True(t, got[1].Signed)
+
+	conf.UseESLD = &no
+	got = CheckSubdomains(eff, "example.com", []string{"news", "shop"})
+	require.Equal(t, "news", got[0].Key.Selector)
+	require.Equal(t, "signed as d=news.example.com by a domain rule", got[0].Reason)
+	require.False(t, got[1].Signed)
+	require.Equal(t, "not signed: no selector and path for shop.example.com", got[1].Reason)
+
+	conf.Selector, conf.Path = "dkim", "/keys/$domain.key"
+	got = CheckSubdomains(eff, "example.com", []string{"shop"})
+	require.True(t, got[0].Signed)
+	require.Equal(t, "/keys/shop.example.com.key", got[0].Key.Path)
+	require.Equal(t, "signed as d=shop.example.com with the global selector and path (try_fallback)", got[0].Reason)
+
+	conf.TryFallback = &no
+	got = CheckSubdomains(eff, "example.com", []string{"shop"})
+	require.False(t, got[0].Signed)
+	require.Equal(t, "not signed: no domain rule or map entry for shop.example.com and try_fallback is off", got[0].Reason)
+
+	got = CheckSubdomains(EffectiveSigningConf{Conf: &DKIMSigningConf{}, Maps: &Maps{Selectors: map[string]string{"example.co.uk": "uk"}, Paths: map[string]string{"example.co.uk": "/uk.key"}}}, "example.co.uk", []string{"mail"})
+	require.Equal(t, "example.co.uk", got[0].Lookup)
+	require.Equal(t, "signed as d=example.co.uk by the selector or path map; use_esld looks up example.co.uk", got[0].Reason)
+}
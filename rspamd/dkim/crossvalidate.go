@@ -0,0 +1,109 @@
+package dkim
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	msgModuleDisabled     = "dkim_signing is enabled but the dkim module is disabled, so no mail is signed"
+	msgSigningHeaders     = "sign_headers in dkim_signing.conf is ignored; mail is signed with the sign_headers of dkim.conf"
+	msgFromNotSigned      = "sign_headers does not include from, which every DKIM signature must cover"
+	msgWhitelistedSigning = "%s is a whitelisted signer and signed by this host, so mail signed by this host passes the dkim module unchecked"
+)
+
+// ModuleConflict is a problem found by checking dkim.conf and
+// dkim_signing.conf together. Option names the option at fault; Domain is
+// empty unless the problem concerns one domain.
+type ModuleConflict struct {
+	Domain  string
+	Option  string
+	Code    Code
+	Message string
+
+	format string
+}
+
+func moduleConflict(domain, option string, code Code, format string) ModuleConflict {
+	c := ModuleConflict{Domain: domain, Option: option, Code: code, format: format}
+	c.Message = c.message(English)
+	return c
+}
+
+func (c ModuleConflict) message(l Locale) string {
+	if c.Domain != "" {
+		return l.Sprintf(c.format, c.Domain)
+	}
+	return l.Sprintf(c.format)
+}
+
+// Finding converts c for GroupByOwner.
+func (c ModuleConflict) Finding() Finding { return c.LocalizedFinding(English) }
+
+// LocalizedFinding is like Finding with the check and message in locale l.
+func (c ModuleConflict) LocalizedFinding(l Locale) Finding {
+	return Finding{Domain: c.Domain, Check: l.Sprintf("modules"), Code: c.Code, Message: c.message(l)}
+}
+
+// CheckModules validates the dkim module config against the signing config
+// it runs with. It reports signing enabled while the dkim module, which does
+// the signing, is disabled; sign_headers set in dkim_signing.conf, where it
+// has no effect, or a dkim.conf sign_headers without From; and signing
+// domains that are also whitelisted signers. whitelisted lists the domains
+// of whitelisted_signers_map when it refers to a map file, as read by
+// ParseWhitelistedSignersMap; entries written inline in dkim.conf are added
+// to it. module may be nil to assume rspamd's
+// defaults.
+func CheckModules(module *DKIMConf, eff EffectiveSigningConf, whitelisted []string) []ModuleConflict {
+	if module == nil {
+		module = &DKIMConf{}
+	}
+	var out []ModuleConflict
+	signing := eff.Conf != nil && (eff.Conf.Enabled == nil || *eff.Conf.Enabled)
+	if signing && module.Enabled != nil && !*module.Enabled {
+		out = append(out, moduleConflict("", "enabled", CodeModuleDisabled, msgModuleDisabled))
+	}
+
+	if eff.Conf != nil {
+		for _, key := range eff.Conf.Keys {
+			if key == "sign_headers" {
+				out = append(out, moduleConflict("", "sign_headers", CodeSignHeaders, msgSigningHeaders))
+				break
+			}
+		}
+	}
+	if len(missingSignHeaders(module, []string{"from"})) > 0 {
+		out = append(out, moduleConflict("", "sign_headers", CodeSignHeaders, msgFromNotSigned))
+	}
+
+	listed := make(map[string]bool)
+	for _, domain := range append(append([]string(nil), whitelisted...), module.WhitelistedSignersMap...) {
+		if strings.HasPrefix(domain, "/") || strings.Contains(domain, "://") {
+			continue
+		}
+		listed[domainKey(domain)] = true
+	}
+	var overlap []string
+	for domain := range signingDomains(eff) {
+		if listed[domain] {
+			overlap = append(overlap, domain)
+		}
+	}
+	sort.Strings(overlap)
+	for _, domain := range overlap {
+		out = append(out, moduleConflict(domain, "whitelisted_signers_map", CodeWhitelistedSigner, msgWhitelistedSigning))
+	}
+	return out
+}
+
+// signingDomains returns the d= domains eff signs the configured domains
+// with, in domainKey form.
+func signingDomains(eff EffectiveSigningConf) map[string]bool {
+	out := make(map[string]bool)
+	for domain := range configuredDomains(eff) {
+		if key, ok := eff.Resolve(domain); ok {
+			out[domainKey(key.Domain)] = true
+		}
+	}
+	return out
+}
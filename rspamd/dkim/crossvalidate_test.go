@@ -0,0 +1,42 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckModules(t *testing.T) {
+	module, err := ParseDKIMConf(strings.NewReader(`
+enabled = false;
+sign_headers = "to:subject";
+whitelisted_signers_map = ["Example.com", "/etc/rspamd/signers.map"];
+`))
+	require.NoError(t, err)
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+selector = "dkim";
+path = "/keys/$domain.key";
+sign_headers = "from:to";
+domain {
+  example.com { selector = "s1"; }
+  shop.example { domain = "esp.example"; }
+  other.org { selector = "s2"; }
+}
+`), WithLenient())
+	require.NoError(t, err)
+	eff := EffectiveSigningConf{Conf: conf}
+
+	got := CheckModules(module, eff, []string{"esp.example."})
+	require.Len(t, got, 5)
+	require.Equal(t, []Code{CodeModuleDisabled, CodeSignHeaders, CodeSignHeaders, CodeWhitelistedSigner, CodeWhitelistedSigner},
+		[]Code{got[0].Code, got[1].Code, got[2].Code, got[3].Code, got[4].Code})
+	require.Equal(t, "sign_headers does not include from, which every DKIM signature must cover", got[2].Message)
+	require.Equal(t, "esp.example", got[3].Domain)
+	require.Equal(t, Finding{Domain: "example.com", Check: "modules", Code: CodeWhitelistedSigner,
+		Message: "example.com is a whitelisted signer and signed by this host, so mail signed by this host passes the dkim module unchecked"}, got[4].Finding())
+	require.Equal(t, "Module", got[0].LocalizedFinding(German).Check)
+
+	// rspamd's defaults raise no conflicts.
+	require.Empty(t, CheckModules(nil, EffectiveSigningConf{Conf: &DKIMSigningConf{Selector: "dkim"}}, nil))
+}
@@ -0,0 +1,113 @@
+package dkim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DelegationWarning describes a configuration that can sign mail with a d=
+// domain other than the From header domain. Domain is the From domain of
+// the rule, or empty for module-wide options. Aligned reports whether the
+// signature still passes DMARC relaxed alignment.
+type DelegationWarning struct {
+	Domain        string
+	SigningDomain string
+	Option        string
+	Aligned       bool
+	Message       string
+}
+
+// Delegation messages, shared with DelegationWarning.LocalizedFinding.
+const (
+	msgDelegatedRelaxed   = "mail from %s is signed as d=%s, which is aligned only under relaxed DMARC alignment"
+	msgDelegatedUnaligned = "mail from %s is signed as d=%s, which does not align with the From domain; DMARC passes only through SPF"
+	msgHdrFromMismatch    = "allow_hdrfrom_mismatch signs mail whose From domain differs from the envelope domain, which may not align with DMARC"
+)
+
+// CheckDelegation reports domain rules whose SigningDomain differs from the
+// rule's own domain, and the use_domain and allow_hdrfrom_mismatch settings
+// that let the signing domain be taken from somewhere other than the From
+// header. Unaligned signatures do not satisfy DMARC, so such mail relies on
+// SPF alignment to pass.
+func CheckDelegation(eff EffectiveSigningConf) []DelegationWarning {
+	conf := eff.Conf
+	if conf == nil {
+		return nil
+	}
+
+	var out []DelegationWarning
+	for domain, rule := range conf.Domain {
+		if rule.SigningDomain == "" || domain == "*" {
+			continue
+		}
+		from := domainKey(domain)
+		d := strings.ToLower(strings.TrimSuffix(rule.SigningDomain, "."))
+		if d == from {
+			continue
+		}
+		w := DelegationWarning{Domain: domain, SigningDomain: d, Option: "domain", Aligned: relaxedAligned(from, d)}
+		w.Message = English.Sprintf(w.messageFormat(), from, d)
+		out = append(out, w)
+	}
+
+	for _, opt := range []struct{ name, val string }{
+		{"use_domain", conf.UseDomain},
+		{"use_domain_sign_local", conf.UseDomainSignLocal},
+		{"use_domain_sign_networks", conf.UseDomainSignNetworks},
+	} {
+		switch strings.ToLower(opt.val) {
+		case "", "header":
+		default:
+			out = append(out, DelegationWarning{
+				Option:  opt.name,
+				Message: fmt.Sprintf("%s = %q signs with the %s domain, which may not align with the From domain", opt.name, opt.val, opt.val),
+			})
+		}
+	}
+	if conf.AllowHdrFromMismatch != nil && *conf.AllowHdrFromMismatch {
+		out = append(out, DelegationWarning{
+			Option:  "allow_hdrfrom_mismatch",
+			Message: msgHdrFromMismatch,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Domain != out[j].Domain {
+			return out[i].Domain < out[j].Domain
+		}
+		return out[i].Option < out[j].Option
+	})
+	return out
+}
+
+// relaxedAligned reports whether a and b share an organizational domain.
+// Without the Public Suffix List this is approximated as the last two
+// labels, or three when the second-level label is a common registry
+// label under a country code, as in example.co.uk.
+func relaxedAligned(a, b string) bool {
+	return orgDomain(a) == orgDomain(b)
+}
+
+func orgDomain(domain string) string {
+	labels := strings.Split(domain, ".")
+	n := 2
+	if len(labels) >= 3 && len(labels[len(labels)-1]) == 2 {
+		switch labels[len(labels)-2] {
+		case "co", "com", "net", "org", "ac", "gov", "edu", "ne", "or":
+			n = 3
+		}
+	}
+	if len(labels) <= n {
+		return domain
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+// messageFormat returns the format of a domain rule warning's Message.
+func (w DelegationWarning) messageFormat() string {
+	if w.Aligned {
+		return msgDelegatedRelaxed
+	}
+	return msgDelegatedUnaligned
+}
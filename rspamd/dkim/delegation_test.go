@@ -0,0 +1,48 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDelegation(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+use_domain = "envelope";
+allow_hdrfrom_mismatch = true;
+domain {
+  a.com { selector = "esp"; path = "/keys/$domain.key"; domain = "esp.net"; }
+  b.com { selector = "s1"; domain = "mail.b.com"; }
+  shop.example.co.uk { selector = "s1"; domain = "news.example.co.uk"; }
+  c.com { selector = "s1"; domain = "C.com."; }
+}
+`))
+	require.NoError(t, err)
+	eff := EffectiveSigningConf{Conf: conf}
+
+	key, ok := eff.Resolve("a.com")
+	require.True(t, ok)
+	require.Equal(t, SigningKey{Domain: "esp.net", Selector: "esp", Path: "/keys/esp.net.key", Source: "domain"}, key)
+
+	warnings := CheckDelegation(eff)
+	require.Len(t, warnings, 5)
+	require.Equal(t, "allow_hdrfrom_mismatch", warnings[0].Option)
+	require.Equal(t, "use_domain", warnings[1].Option)
+	require.Equal(t, DelegationWarning{
+		Domain:        "a.com",
+		SigningDomain: "esp.net",
+		Option:        "domain",
+		Message:       "mail from a.com is signed as d=esp.net, which does not align with the From domain; DMARC passes only through SPF",
+	}, warnings[2])
+	require.True(t, warnings[3].Aligned)
+	require.Equal(t, "shop.example.co.uk", warnings[4].Domain)
+	require.True(t, warnings[4].Aligned)
+}
+
+func TestOrgDomain(t *testing.T) {
+	require.Equal(t, "example.com", orgDomain("mail.example.com"))
+	require.Equal(t, "example.co.uk", orgDomain("a.b.example.co.uk"))
+	require.Equal(t, "com", orgDomain("com"))
+	require.False(t, relaxedAligned("a.example.co.uk", "b.other.co.uk"))
+}
@@ -0,0 +1,163 @@
+package dkim
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Diagnostic is a parse error, warning or finding in one form, for JSON and
+// SARIF output. Domain is set for findings, File and the position for
+// problems in a file.
+type Diagnostic struct {
+	Code     Code     `json:"code,omitempty"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Domain   string   `json:"domain,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// ErrorDiagnostics returns a diagnostic for each error in err, which may be
+// a ParseErrors from recovery mode. It returns nil if err is nil.
+func ErrorDiagnostics(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+	var list ParseErrors
+	var pe *ParseError
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &pe):
+		list = ParseErrors{pe}
+	default:
+		return []Diagnostic{newDiagnostic(CodeOf(err), SeverityError, Diagnostic{Message: err.Error()})}
+	}
+	out := make([]Diagnostic, len(list))
+	for i, e := range list {
+		out[i] = newDiagnostic(e.Code(), SeverityError, Diagnostic{File: e.File, Line: e.Line, Column: e.Column, Message: e.Err.Error()})
+	}
+	return out
+}
+
+// Diagnostic converts w.
+func (w Warning) Diagnostic() Diagnostic {
+	return newDiagnostic(w.Code, SeverityWarning, Diagnostic{File: w.File, Line: w.Line, Column: w.Column, Message: w.Message})
+}
+
+// Diagnostic converts f.
+func (f Finding) Diagnostic() Diagnostic {
+	return newDiagnostic(f.Code, SeverityWarning, Diagnostic{Domain: f.Domain, Message: f.Message})
+}
+
+// newDiagnostic sets the code of d and its severity from the registry, or
+// to fallback for unknown codes.
+func newDiagnostic(code Code, fallback Severity, d Diagnostic) Diagnostic {
+	d.Code, d.Severity = code, fallback
+	if info, ok := LookupCode(code); ok {
+		d.Severity = info.Severity
+	}
+	return d
+}
+
+// WriteSARIF writes diags as a SARIF 2.1.0 log, with a rule for each code
+// used.
+func WriteSARIF(w io.Writer, diags []Diagnostic) error {
+	rules := []sarifRule{}
+	seen := make(map[Code]bool)
+	results := make([]sarifResult, 0, len(diags))
+	for _, d := range diags {
+		if d.Code != "" && !seen[d.Code] {
+			seen[d.Code] = true
+			r := sarifRule{ID: d.Code}
+			if info, ok := LookupCode(d.Code); ok {
+				r.ShortDescription.Text = info.Title
+				r.DefaultConfiguration.Level = info.Severity
+			}
+			rules = append(rules, r)
+		}
+		res := sarifResult{RuleID: d.Code, Level: d.Severity, Message: sarifText{d.Message}}
+		var loc sarifLocation
+		if d.File != "" {
+			loc.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: d.File}}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+		}
+		if d.Domain != "" {
+			loc.LogicalLocations = []sarifLogicalLocation{{Name: d.Domain, Kind: "resource"}}
+		}
+		if loc.PhysicalLocation != nil || loc.LogicalLocations != nil {
+			res.Locations = []sarifLocation{loc}
+		}
+		results = append(results, res)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Results: results}},
+	}
+	log.Runs[0].Tool.Driver = sarifDriver{Name: "dkim.conf", InformationURI: "https://github.com/littlebugger/dkim.conf", Rules: rules}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// The subset of the SARIF 2.1.0 format written by WriteSARIF.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool struct {
+			Driver sarifDriver `json:"driver"`
+		} `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   Code      `json:"id"`
+		ShortDescription     sarifText `json:"shortDescription"`
+		DefaultConfiguration struct {
+			Level Severity `json:"level"`
+		} `json:"defaultConfiguration"`
+	}
+	sarifResult struct {
+		RuleID    Code            `json:"ruleId,omitempty"`
+		Level     Severity        `json:"level"`
+		Message   sarifText       `json:"message"`
+		Locations []sarifLocation `json:"locations,omitempty"`
+	}
+	sarifText struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+		LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           *sarifRegion  `json:"region,omitempty"`
+	}
+	sarifArtifact struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	sarifLogicalLocation struct {
+		Name string `json:"name"`
+		Kind string `json:"kind"`
+	}
+)
@@ -0,0 +1,67 @@
+package dkim
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader("selector = \"s1\";\nselector = \"s2\";\nselectr = 1;\n"),
+		WithFilename("dkim_signing.conf"), WithStrict(), WithRecovery())
+	require.Error(t, err)
+
+	diags := ErrorDiagnostics(err)
+	require.Equal(t, []Diagnostic{
+		{Code: CodeUnknownKey, Severity: SeverityError, File: "dkim_signing.conf", Line: 3, Column: 1, Message: `unknown key "selectr"`},
+	}, diags)
+	require.Equal(t, Diagnostic{Code: CodeDuplicateKey, Severity: SeverityWarning, File: "dkim_signing.conf", Line: 2, Column: 1,
+		Message: `duplicate key "selector", keeping the last value`}, conf.Warnings[0].Diagnostic())
+	require.Nil(t, ErrorDiagnostics(nil))
+
+	finding := Rotation{Domain: "a.example", Selector: "s1"}.Finding()
+	require.Equal(t, Diagnostic{Code: CodeRotationDue, Severity: SeverityNote, Domain: "a.example", Message: finding.Message}, finding.Diagnostic())
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARIF(&buf, append(diags, conf.Warnings[0].Diagnostic(), finding.Diagnostic())))
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID               string
+						ShortDescription struct{ Text string }
+					}
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Locations []struct {
+					PhysicalLocation *struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn int }
+					}
+					LogicalLocations []struct{ Name string }
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	run := log.Runs[0]
+	require.Len(t, run.Tool.Driver.Rules, 3)
+	require.Equal(t, string(CodeDuplicateKey), run.Tool.Driver.Rules[0].ID)
+	require.Equal(t, "Repeated key", run.Tool.Driver.Rules[0].ShortDescription.Text)
+	require.Len(t, run.Results, 3)
+	require.Equal(t, string(CodeUnknownKey), run.Results[0].RuleID)
+	require.Equal(t, "error", run.Results[0].Level)
+	require.Equal(t, "dkim_signing.conf", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	require.Equal(t, 3, run.Results[0].Locations[0].PhysicalLocation.Region.StartLine)
+	require.Equal(t, "note", run.Results[2].Level)
+	require.Equal(t, "a.example", run.Results[2].Locations[0].LogicalLocations[0].Name)
+}
@@ -0,0 +1,105 @@
+package dkim
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Policy decides what happens to bytes that are not valid UTF-8.
+type InvalidUTF8Policy int
+
+const (
+	// InvalidUTF8Pass leaves invalid bytes as they are, as earlier versions
+	// did: map values keep them and configuration values read them as
+	// U+FFFD.
+	InvalidUTF8Pass InvalidUTF8Policy = iota
+	// InvalidUTF8Fail makes invalid UTF-8 a parse error.
+	InvalidUTF8Fail
+	// InvalidUTF8Replace replaces each invalid byte with U+FFFD.
+	InvalidUTF8Replace
+	// InvalidUTF8Latin1 reads each invalid byte as a Latin-1 character, for
+	// files with comments or values written in a Latin-1 editor.
+	InvalidUTF8Latin1
+)
+
+// WithInvalidUTF8 sets the policy for input that is not valid UTF-8. The
+// default is InvalidUTF8Pass.
+func WithInvalidUTF8(policy InvalidUTF8Policy) Option {
+	return func(o *parseOptions) {
+		o.invalidUTF8 = policy
+	}
+}
+
+var byteOrderMark = []byte{0xef, 0xbb, 0xbf}
+
+// textReader passes UTF-8 text through with a leading byte order mark
+// removed, CRLF line endings turned into LF and invalid bytes handled
+// according to policy. It counts lines and columns like the lexer so that
+// encoding errors carry the position of the invalid byte.
+type textReader struct {
+	r       *bufio.Reader
+	policy  InvalidUTF8Policy
+	pos     position
+	started bool
+}
+
+func newTextReader(r io.Reader, opts *parseOptions, file string) io.Reader {
+	return &textReader{r: bufio.NewReader(r), policy: opts.invalidUTF8, pos: position{file: file, line: 1, col: 1}}
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	if !t.started {
+		t.started = true
+		if b, _ := t.r.Peek(len(byteOrderMark)); bytes.Equal(b, byteOrderMark) {
+			t.r.Discard(len(byteOrderMark))
+		}
+	}
+	if len(p) < utf8.UTFMax {
+		return 0, io.ErrShortBuffer
+	}
+	n := 0
+	for n+utf8.UTFMax <= len(p) {
+		r, size, err := t.r.ReadRune()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		if r == '\r' {
+			if next, _ := t.r.Peek(1); len(next) == 1 && next[0] == '\n' {
+				continue
+			}
+		}
+		if r == utf8.RuneError && size == 1 {
+			switch t.policy {
+			case InvalidUTF8Pass:
+				t.r.UnreadRune()
+				p[n], _ = t.r.ReadByte()
+				n++
+				t.pos.col++
+				continue
+			case InvalidUTF8Fail:
+				t.r.UnreadRune()
+				b, _ := t.r.ReadByte()
+				err := withCode(CodeInvalidEncoding, fmt.Errorf("invalid UTF-8 byte 0x%02x", b))
+				return n, &ParseError{File: t.pos.file, Line: t.pos.line, Column: t.pos.col, Err: err}
+			case InvalidUTF8Latin1:
+				t.r.UnreadRune()
+				b, _ := t.r.ReadByte()
+				r = rune(b)
+			}
+		}
+		if r == '\n' {
+			t.pos.line++
+			t.pos.col = 1
+		} else {
+			t.pos.col++
+		}
+		n += utf8.EncodeRune(p[n:], r)
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package dkim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteOrderMarkAndCRLF(t *testing.T) {
+	input := "\ufeffselector = \"s1\";\r\n# comment\r\npath = <<EOD\r\n/a.key\r\nEOD;\r\ndomain {\r\n  a.com { selector = \"a\"; }\r\n}\r\n"
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/a.key", conf.Path)
+	require.Equal(t, "a", conf.Domain["a.com"].Selector)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("\ufeffselector = @;\r\n"))
+	requireParseError(t, err, "", 1, 12)
+
+	m, err := ParseDKIMSelectorsMap(strings.NewReader("\ufeffexample.com s1\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"example.com": "s1"}, m)
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	input := "# caf\xe9\nselector = \"s\xe91\";\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "s\ufffd1", conf.Selector)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithFilename("dkim_signing.conf"), WithInvalidUTF8(InvalidUTF8Fail))
+	pe := requireParseError(t, err, "dkim_signing.conf", 1, 6)
+	require.EqualError(t, pe.Err, "invalid UTF-8 byte 0xe9")
+	require.Equal(t, CodeInvalidEncoding, CodeOf(err))
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(input), WithInvalidUTF8(InvalidUTF8Replace))
+	require.NoError(t, err)
+	require.Equal(t, "s�1", conf.Selector)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(input), WithInvalidUTF8(InvalidUTF8Latin1))
+	require.NoError(t, err)
+	require.Equal(t, "sé1", conf.Selector)
+
+	m, err := ParseDKIMSelectorsMap(strings.NewReader("a.com s1\nb.com s\xff\n"))
+	require.NoError(t, err)
+	require.Equal(t, "s\xff", m["b.com"])
+
+	_, err = ParseDKIMSelectorsMap(strings.NewReader("a.com s1\nb.com s\xff\n"), WithInvalidUTF8(InvalidUTF8Fail))
+	require.Equal(t, CodeInvalidEncoding, CodeOf(err))
+	var perr *ParseError
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, 2, perr.Line)
+}
@@ -0,0 +1,94 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseError is an error in a configuration file together with the position
+// it refers to. File is the name set with WithFilename for the top-level
+// input, or the path of an included file. Line and Column are 1-based and
+// Column counts characters.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+	// Snippet is the input around the error, set with WithSnippets.
+	Snippet string
+}
+
+// Error formats e as "file:line:col: [CODE] message", or with "line L,
+// column C" when there is no file name. The code is left out for errors
+// without one.
+func (e *ParseError) Error() string {
+	msg := e.Err.Error()
+	if code := e.Code(); code != "" {
+		msg = fmt.Sprintf("[%s] %s", code, msg)
+	}
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the diagnostic code of the error, see CodeOf.
+func (e *ParseError) Code() Code {
+	return CodeOf(e.Err)
+}
+
+// Warning is a problem that did not stop parsing, such as a repeated key.
+type Warning struct {
+	File    string
+	Line    int
+	Column  int
+	Code    Code
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.File != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", w.File, w.Line, w.Column, w.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", w.Line, w.Column, w.Message)
+}
+
+// errTooManyErrors stops parsing once the WithMaxErrors limit is reached.
+var errTooManyErrors = errors.New("too many errors")
+
+// ParseErrors is returned in recovery mode when the input had one or more
+// errors. The errors are in the order they were found.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// errorAt attaches pos to err unless err already carries a position.
+func errorAt(pos position, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &ParseError{File: pos.file, Line: pos.line, Column: pos.col, Err: err}
+}
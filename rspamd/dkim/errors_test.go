@@ -0,0 +1,113 @@
+package dkim
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requireParseError(t *testing.T, err error, file string, line, col int) *ParseError {
+	t.Helper()
+	var pe *ParseError
+	require.True(t, errors.As(err, &pe), "expected ParseError, got %v", err)
+	require.Equal(t, file, pe.File)
+	require.Equal(t, line, pe.Line, err.Error())
+	require.Equal(t, col, pe.Column, err.Error())
+	return pe
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	_, err := ParseDKIMSigningConf(strings.NewReader("selector = \"s1\";\n\n  path = @;\n"))
+	requireParseError(t, err, "", 3, 10)
+	require.Equal(t, "line 3, column 10: [DKIMCONF0001] unexpected character: '@'", err.Error())
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("domain {\n  a.com {\n    selector = ;\n"), WithFilename("dkim_signing.conf"))
+	pe := requireParseError(t, err, "dkim_signing.conf", 3, 16)
+	require.Equal(t, "dkim_signing.conf:3:16: [DKIMCONF0001] unexpected value token: ';'", pe.Error())
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("enabled = true;\nsign_local = perhaps;\n"))
+	requireParseError(t, err, "", 2, 1)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("use_domain = \"header\";\nselector = \"s1\n"))
+	requireParseError(t, err, "", 2, 12)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("x = <<EOD\nline\nEOD\ny = @"))
+	requireParseError(t, err, "", 4, 5)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("domain {\n  a.com { selector = \"s1\"; }\n"))
+	require.ErrorContains(t, err, "end of file")
+}
+
+func TestParseErrorInInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": "selector = \"s1\";\nuse_esld = sometimes;\n",
+		"local.d/broken.conf":       "# comment\n  selector }\n",
+	})
+
+	_, err := ParseDKIMSigningConf(strings.NewReader(`.include "local.d/broken.conf"`), WithIncludeDir(dir))
+	requireParseError(t, err, filepath.Join(dir, "local.d/broken.conf"), 2, 12)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`.include "local.d/dkim_signing.conf"`), WithIncludeDir(dir))
+	requireParseError(t, err, filepath.Join(dir, "local.d/dkim_signing.conf"), 2, 1)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("\n  .include \"missing.conf\""), WithIncludeDir(dir), WithFilename("main.conf"))
+	requireParseError(t, err, "main.conf", 2, 3)
+}
+
+func TestRecoveryCollectsAllErrors(t *testing.T) {
+	input := strings.Join([]string{
+		`selector = "s1";`,
+		`path = @;`,
+		`sign_local = perhaps;`,
+		`domain {`,
+		`  a.com { selector = ; path = "/a.key"; }`,
+		`  b.com { selector = "b"; }`,
+		`}`,
+		`use_domain = "header"`,
+		`enabled = true;`,
+	}, "\n")
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithRecovery())
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs), "expected ParseErrors, got %v", err)
+	require.Len(t, errs, 3)
+	requireParseError(t, errs[0], "", 2, 8)
+	requireParseError(t, errs[1], "", 5, 22)
+	requireParseError(t, errs[2], "", 3, 1)
+
+	require.NotNil(t, conf)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "header", conf.UseDomain)
+	require.Nil(t, conf.SignLocal)
+	require.NotNil(t, conf.Enabled)
+	require.Equal(t, DomainRule{Path: "/a.key"}, conf.Domain["a.com"])
+	require.Equal(t, DomainRule{Selector: "b"}, conf.Domain["b.com"])
+}
+
+func TestRecoveryUnclosedBlock(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader("domain {\n  a.com {\n    selector = \"a\";\n"), WithRecovery())
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, "line 4, column 1: [DKIMCONF0001] unexpected token: end of file", errs[0].Error())
+	require.Equal(t, "a", conf.Domain["a.com"].Selector)
+}
+
+func TestRecoveryStrayBraceAndUnterminatedString(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader("}\nenabled = yes;\nsign_headers = \"from:to\n"), WithRecovery())
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	require.Equal(t, "line 1, column 1: [DKIMCONF0001] unexpected token: '}'", errs[0].Error())
+	require.Equal(t, "line 3, column 16: [DKIMCONF0002] unterminated string", errs[1].Error())
+	require.True(t, *conf.Enabled)
+}
+
+func TestRecoveryWithoutErrors(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`selector = "s1";`), WithRecovery())
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+}
@@ -0,0 +1,108 @@
+package dkim
+
+// Extract returns a copy of conf and maps reduced to the given domains. Global
+// settings and the sign_networks map are kept as-is, while domain rules and
+// map entries for any other domain are dropped. The wildcard "*" domain rule is kept since it applies to
+// every domain. Map keys are matched case-insensitively and with an optional
+// leading "@", as used by signed_domains.map.
+func Extract(conf *DKIMSigningConf, maps *Maps, domains []string) (*DKIMSigningConf, *Maps) {
+	want := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		want[domainKey(d)] = true
+	}
+	keep := func(key string) bool {
+		return key == "*" || want[domainKey(key)]
+	}
+
+	var outConf *DKIMSigningConf
+	if conf != nil {
+		c := *conf
+		c.Domain = make(map[string]DomainRule)
+		for key, rule := range conf.Domain {
+			if keep(key) {
+				c.Domain[key] = rule
+			}
+		}
+		if conf.Sections != nil {
+			c.Sections = make(map[string]*Section, len(conf.Sections))
+			for name, sec := range conf.Sections {
+				c.Sections[name] = sec
+			}
+			if sec, ok := conf.Sections["domain"]; ok {
+				c.Sections["domain"] = filterSections(sec, keep)
+			}
+		}
+		if conf.Root != nil {
+			root := *conf.Root
+			root.Sections = c.Sections
+			c.Root = &root
+		}
+		outConf = &c
+	}
+
+	var outMaps *Maps
+	if maps != nil {
+		outMaps = &Maps{
+			Selectors:     filterMap(maps.Selectors, keep),
+			Paths:         filterMap(maps.Paths, keep),
+			SignedDomains: filterMap(maps.SignedDomains, keep),
+			SignNetworks:  maps.SignNetworks,
+			Annotations:   filterMap(maps.Annotations, keep),
+		}
+	}
+
+	return outConf, outMaps
+}
+
+// filterSections returns a copy of sec without the keys keep rejects,
+// keeping the order, comments and positions of the other keys.
+func filterSections(sec *Section, keep func(string) bool) *Section {
+	out := newSection()
+	for _, key := range sec.Keys() {
+		if !keep(key) {
+			continue
+		}
+		if child, ok := sec.Sections[key]; ok {
+			out.Sections[key] = child
+		}
+		out.order = append(out.order, key)
+		if v, ok := sec.Values[key]; ok {
+			out.Values[key] = v
+		}
+		if v, ok := sec.Arrays[key]; ok {
+			out.Arrays[key] = v
+		}
+		if v, ok := sec.Blocks[key]; ok {
+			out.Blocks[key] = v
+		}
+		if v, ok := sec.Comments[key]; ok {
+			out.Comments[key] = v
+		}
+		if v, ok := sec.priority[key]; ok {
+			out.priority[key] = v
+		}
+		if v, ok := sec.pos[key]; ok {
+			out.pos[key] = v
+		}
+		if v, ok := sec.quoted[key]; ok {
+			out.quoted[key] = v
+		}
+		if v, ok := sec.elems[key]; ok {
+			out.elems[key] = v
+		}
+	}
+	return out
+}
+
+func filterMap[V any](m map[string]V, keep func(string) bool) map[string]V {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]V)
+	for key, val := range m {
+		if keep(key) {
+			out[key] = val
+		}
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package dkim
+
+import (
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	f, err := os.Open("../../examples/2/dkim_signing.conf")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	conf, err := ParseDKIMSigningConf(f)
+	require.NoError(t, err)
+	conf.Domain["other.com"] = DomainRule{Selector: "x"}
+
+	maps := &Maps{
+		Selectors: map[string]string{
+			"go.test.com":   "c1",
+			"team.com":      "c1",
+			"Test-Team.com": "c1",
+		},
+		SignedDomains: map[string]string{
+			"@go.test.com":   "/var/lib/rspamd/dkim/go.key",
+			"@pro.test.club": "/var/lib/rspamd/dkim/pro.key",
+		},
+	}
+
+	sub, subMaps := Extract(conf, maps, []string{"go.test.com", "test-team.com"})
+	require.Equal(t, conf.Selector, sub.Selector)
+	require.Contains(t, sub.Domain, "*")
+	require.NotContains(t, sub.Domain, "other.com")
+	require.Contains(t, conf.Domain, "other.com")
+	require.Equal(t, map[string]string{"go.test.com": "c1", "Test-Team.com": "c1"}, subMaps.Selectors)
+	require.Equal(t, map[string]string{"@go.test.com": "/var/lib/rspamd/dkim/go.key"}, subMaps.SignedDomains)
+	require.Nil(t, subMaps.Paths)
+
+	maps.SignNetworks = Networks{{Prefix: netip.MustParsePrefix("10.0.0.0/8")}}
+	_, subMaps = Extract(conf, maps, []string{"go.test.com"})
+	require.Equal(t, maps.SignNetworks, subMaps.SignNetworks)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(`
+domain {
+  # kept
+  z.com { selectors [ { selector = "rsa"; }, { selector = "ed"; } ] }
+  a.com { selector = "a"; }
+  m.com { selector = "m"; }
+}
+`))
+	require.NoError(t, err)
+	sub, _ = Extract(conf, nil, []string{"m.com", "z.com"})
+	require.Equal(t, []string{"z.com", "m.com"}, sub.DomainNames())
+	domain := sub.Sections["domain"]
+	require.Equal(t, []string{"z.com", "m.com"}, domain.Keys())
+	require.Equal(t, []string{"# kept"}, domain.Comments["z.com"])
+	require.Len(t, domain.Sections["z.com"].Blocks["selectors"], 2)
+	require.Equal(t, position{line: 6, col: 3}, domain.pos["m.com"])
+}
+
+func TestExtractDropsOtherDomains(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+selector = "dkim";
+domain {
+  keep.org { selector = "k"; }
+  other.org { selector = "o"; key = "SECRET-OTHER-KEY"; }
+  third.org { selectors [ { selector = "t"; key = "SECRET-THIRD-KEY"; } ] }
+}
+`))
+	require.NoError(t, err)
+
+	sub, _ := Extract(conf, nil, []string{"keep.org"})
+	require.Equal(t, []string{"keep.org"}, sub.DomainNames())
+	require.Same(t, sub.Sections["domain"], sub.Root.Sections["domain"])
+
+	var walk func(sec *Section)
+	walk = func(sec *Section) {
+		for _, key := range sec.Keys() {
+			require.NotContains(t, key, "other.org")
+			require.NotContains(t, key, "third.org")
+		}
+		for _, v := range sec.Values {
+			require.NotContains(t, v, "SECRET")
+		}
+		for _, child := range sec.Sections {
+			walk(child)
+		}
+		for _, blocks := range sec.Blocks {
+			for _, child := range blocks {
+				walk(child)
+			}
+		}
+	}
+	walk(sub.Root)
+	for _, sec := range sub.Sections {
+		walk(sec)
+	}
+
+	// The input is left untouched.
+	require.Contains(t, conf.Root.Sections["domain"].Sections, "other.org")
+}
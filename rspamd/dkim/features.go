@@ -0,0 +1,54 @@
+package dkim
+
+import "errors"
+
+// ErrFeatureDisabled is returned by the functions of an optional subsystem
+// that was left out of the build.
+var ErrFeatureDisabled = errors.New("feature not included in this build")
+
+// Optional subsystems. Each is included unless the build tag named in its
+// Feature is set, so consumers that only parse configuration can leave out
+// the packages they pull in. Without the archive feature OpenArchive and
+// ReadArchive return ErrFeatureDisabled; without http-sink there is no
+// HTTPSink, since keeping the type would keep net/http.
+const (
+	FeatureArchive  = "archive"
+	FeatureHTTPSink = "http-sink"
+)
+
+// Feature reports whether an optional subsystem is part of this build. Tag
+// is the build tag that leaves it out.
+type Feature struct {
+	Name    string
+	Tag     string
+	Enabled bool
+}
+
+var features = []Feature{
+	{Name: FeatureArchive, Tag: "dkimconf_noarchive"},
+	{Name: FeatureHTTPSink, Tag: "dkimconf_nohttp"},
+}
+
+func enableFeature(name string) {
+	for i := range features {
+		if features[i].Name == name {
+			features[i].Enabled = true
+		}
+	}
+}
+
+// Features returns the optional subsystems in name order and whether this
+// build includes them.
+func Features() []Feature {
+	return append([]Feature(nil), features...)
+}
+
+// HasFeature reports whether this build includes the named subsystem.
+func HasFeature(name string) bool {
+	for _, f := range features {
+		if f.Name == name {
+			return f.Enabled
+		}
+	}
+	return false
+}
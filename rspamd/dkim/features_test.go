@@ -0,0 +1,15 @@
+package dkim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatures(t *testing.T) {
+	for _, f := range Features() {
+		require.NotEmpty(t, f.Tag, f.Name)
+		require.Equal(t, f.Enabled, HasFeature(f.Name), f.Name)
+	}
+	require.False(t, HasFeature("unknown"))
+}
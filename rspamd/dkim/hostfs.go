@@ -0,0 +1,14 @@
+//go:build !js
+
+package dkim
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// hostOpen and hostGlob read included files from the host filesystem.
+func hostOpen(name string) (io.ReadCloser, error) { return os.Open(name) }
+
+func hostGlob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
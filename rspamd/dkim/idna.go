@@ -0,0 +1,138 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrIPLiteral is returned by ASCIIDomain for address literals such as
+// [192.0.2.1], which cannot be a DKIM signing domain.
+var ErrIPLiteral = errors.New("IP literal is not a domain")
+
+// ASCIIDomain returns domain in the form used for lookups and the d= tag:
+// lower case, without a trailing dot and with internationalized labels, as
+// in addresses sent with SMTPUTF8, converted to their xn-- ASCII form.
+// Labels are only lower-cased before conversion; full IDNA mapping is not
+// applied. Address literals and bare IP addresses are refused with
+// ErrIPLiteral, and names with empty labels or characters other than
+// letters, digits, hyphens and underscores are refused as well.
+func ASCIIDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if strings.HasPrefix(domain, "[") && strings.HasSuffix(domain, "]") {
+		return "", fmt.Errorf("%w: %s", ErrIPLiteral, domain)
+	}
+	if _, err := netip.ParseAddr(domain); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrIPLiteral, domain)
+	}
+	if domain == "" {
+		return "", errors.New("empty domain")
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("empty label in domain %q", domain)
+		}
+		ascii := true
+		for _, r := range label {
+			if r >= utf8.RuneSelf {
+				ascii = false
+			} else if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return "", fmt.Errorf("invalid character %q in domain %q", r, domain)
+			}
+		}
+		if !ascii {
+			label = "xn--" + punycode(label)
+		}
+		if len(label) > 63 {
+			return "", fmt.Errorf("label %q of domain %q is longer than 63 bytes", label, domain)
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// Punycode parameters from RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes label as in RFC 3492, without the xn-- prefix.
+func punycode(label string) string {
+	runes := []rune(label)
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for h := basic; h < len(runes); {
+		m := int(utf8.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := min(max(k-bias, punyTMin), punyTMax)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
@@ -0,0 +1,58 @@
+package dkim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestASCIIDomain(t *testing.T) {
+	for in, want := range map[string]string{
+		"Example.COM.":        "example.com",
+		"münchen.de":          "xn--mnchen-3ya.de",
+		"MÜNCHEN.de":          "xn--mnchen-3ya.de",
+		"bücher.example":      "xn--bcher-kva.example",
+		"пример.испытание":    "xn--e1afmkfd.xn--80akhbyknj4f",
+		"例え.テスト":              "xn--r8jz45g.xn--zckzah",
+		"xn--mnchen-3ya.de":   "xn--mnchen-3ya.de",
+		"_domainkey.a-b.test": "_domainkey.a-b.test",
+	} {
+		got, err := ASCIIDomain(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"[192.0.2.1]", "[IPv6:2001:db8::1]", "192.0.2.1", "2001:db8::1"} {
+		_, err := ASCIIDomain(in)
+		require.ErrorIs(t, err, ErrIPLiteral, in)
+	}
+	for _, in := range []string{"", "a..b", "user@example.com", "exa mple.com"} {
+		_, err := ASCIIDomain(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestResolveInternationalDomains(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Selector: "s1",
+			Path:     "/keys/$domain.key",
+			Domain: map[string]DomainRule{
+				"münchen.de":         {Selector: "muc"},
+				"xn--bcher-kva.test": {Selector: "books"},
+			},
+		},
+	}
+	key, ok := eff.Resolve("MÜNCHEN.de")
+	require.True(t, ok)
+	require.Equal(t, SigningKey{Domain: "xn--mnchen-3ya.de", Selector: "muc", Path: "/keys/xn--mnchen-3ya.de.key", Source: "domain"}, key)
+
+	key, ok = eff.Resolve("bücher.test")
+	require.True(t, ok)
+	require.Equal(t, "books", key.Selector)
+
+	_, ok = eff.Resolve("[192.0.2.1]")
+	require.False(t, ok)
+	_, ok = eff.Resolve("192.0.2.1")
+	require.False(t, ok)
+}
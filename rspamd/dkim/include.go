@@ -0,0 +1,311 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth bounds how deeply .include directives may nest.
+const maxIncludeDepth = 16
+
+// duplicatePolicy is the libucl `duplicate` include parameter.
+type duplicatePolicy int
+
+const (
+	// duplicateDefault is used without a duplicate parameter. It is like
+	// duplicateAppend, but repeats at equal priority follow the
+	// WithDuplicateKeys policy.
+	duplicateDefault duplicatePolicy = iota
+	// duplicateAppend lets a higher priority replace a lower one. On equal
+	// priority sections are merged and other values are collected into an
+	// array, as libucl does.
+	duplicateAppend
+	// duplicateMerge merges sections and arrays regardless of priority.
+	// Scalars follow the priorities, and are collected into an array on
+	// equal priority.
+	duplicateMerge
+	// duplicateRewrite always replaces the existing value.
+	duplicateRewrite
+	// duplicateError fails on any repeated key.
+	duplicateError
+)
+
+func parseDuplicatePolicy(val string) (duplicatePolicy, error) {
+	switch strings.ToLower(val) {
+	case "append":
+		return duplicateAppend, nil
+	case "merge":
+		return duplicateMerge, nil
+	case "rewrite", "replace":
+		return duplicateRewrite, nil
+	case "error":
+		return duplicateError, nil
+	default:
+		return 0, withCode(CodeIncludeParams, fmt.Errorf("invalid duplicate policy %q", val))
+	}
+}
+
+type valueKind int
+
+const (
+	kindScalar valueKind = iota
+	kindArray
+	kindSection
+)
+
+type mergeAction int
+
+const (
+	// actionSet stores the new value, replacing any existing one.
+	actionSet mergeAction = iota
+	// actionMerge combines the new value with the existing one.
+	actionMerge
+	// actionSkip keeps the existing value and drops the new one.
+	actionSkip
+	// actionCollect turns the existing value into an array and appends the
+	// new one.
+	actionCollect
+)
+
+// resolveDuplicate decides how a value for key is stored in sec given the
+// priority and duplicate policy of the file currently being parsed, so each
+// included file is merged with the strategy it was included with. Repeats
+// at the same priority in files without a duplicate parameter follow the
+// WithDuplicateKeys policy and are reported as warnings at pos.
+func (p *parser) resolveDuplicate(sec *Section, key string, kind valueKind, pos position) (mergeAction, error) {
+	if sec.priority == nil {
+		sec.priority = make(map[string]int)
+	}
+	old, exists := sec.priority[key]
+	if !exists {
+		sec.priority[key] = p.priority
+		sec.order = append(sec.order, key)
+		return actionSet, nil
+	}
+
+	switch p.dup {
+	case duplicateError:
+		return actionSkip, withCode(CodeDuplicateKey, fmt.Errorf("duplicate key %q", key))
+	case duplicateRewrite:
+		sec.priority[key] = p.priority
+		return actionSet, nil
+	case duplicateMerge:
+		if kind != kindScalar {
+			if p.priority > old {
+				sec.priority[key] = p.priority
+			}
+			return actionMerge, nil
+		}
+	}
+
+	switch {
+	case p.priority < old:
+		return actionSkip, nil
+	case p.priority > old:
+		sec.priority[key] = p.priority
+		return actionSet, nil
+	case kind == kindSection:
+		return actionMerge, nil
+	case p.dup == duplicateAppend || p.dup == duplicateMerge:
+		if _, ok := sec.Sections[key]; ok {
+			return actionSet, nil
+		}
+		return actionCollect, nil
+	}
+
+	switch p.opts.duplicateKeys {
+	case DuplicateFail:
+		return actionSkip, withCode(CodeDuplicateKey, fmt.Errorf("duplicate key %q", key))
+	case DuplicateKeepFirst:
+		p.doc.warn(pos, CodeDuplicateKey, fmt.Sprintf("duplicate key %q, keeping the first value", key))
+		return actionSkip, nil
+	case DuplicateCollect:
+		p.doc.warn(pos, CodeDuplicateKey, fmt.Sprintf("duplicate key %q, collecting values into an array", key))
+		return actionCollect, nil
+	default:
+		p.doc.warn(pos, CodeDuplicateKey, fmt.Sprintf("duplicate key %q, keeping the last value", key))
+		return actionSet, nil
+	}
+}
+
+// Include records the outcome of one file referenced by an .include or
+// .try_include directive. Resolved is false for optional includes whose file
+// was missing and therefore skipped.
+type Include struct {
+	Path     string
+	Resolved bool
+}
+
+// includeParams are the libucl `.include(...)` parameters understood here.
+type includeParams struct {
+	try      bool
+	glob     bool
+	priority int
+	dup      duplicatePolicy
+	prefix   string
+}
+
+// parseDirective handles a `.name` directive found inside sec.
+func (p *parser) parseDirective(sec *Section, directive token) error {
+	name := directive.val
+	switch name {
+	case "include", "try_include":
+	default:
+		return errorAt(directive.pos, withCode(CodeUnknownDirective, fmt.Errorf("unknown directive .%s", name)))
+	}
+
+	params, err := parseIncludeParams(p.l)
+	if err != nil {
+		return err
+	}
+	if name == "try_include" {
+		params.try = true
+	}
+	path, err := parseValue(p.l)
+	if err != nil {
+		return err
+	}
+	p.opts.tracef(directive.pos, ".%s %q (try=%t glob=%t priority=%d prefix=%q)", name, path, params.try, params.glob, params.priority, params.prefix)
+	skipSeparator(p.l)
+	return errorAt(directive.pos, p.include(sec, path, params, directive.pos))
+}
+
+// parseIncludeParams reads an optional `(key=value, ...)` parameter list.
+func parseIncludeParams(l *lexer) (includeParams, error) {
+	var params includeParams
+	if ok, err := tryConsume(l, tokenLParen); err != nil || !ok {
+		return params, err
+	}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return params, err
+		}
+		switch tok.typ {
+		case tokenRParen:
+			return params, nil
+		case tokenComma, tokenSemicolon:
+			continue
+		case tokenIdent, tokenString:
+		default:
+			return params, withCode(CodeIncludeParams, fmt.Errorf("unexpected token in include parameters: %v", tok.typ))
+		}
+		if err := expect(l, tokenEqual); err != nil {
+			return params, err
+		}
+		val, err := parseValue(l)
+		if err != nil {
+			return params, err
+		}
+		switch tok.val {
+		case "try":
+			params.try, err = parseBool(val)
+		case "glob":
+			params.glob, err = parseBool(val)
+		case "priority":
+			params.priority, err = strconv.Atoi(val)
+			if err == nil && (params.priority < 0 || params.priority > 15) {
+				err = withCode(CodeIncludeParams, fmt.Errorf("priority %d out of range 0-15", params.priority))
+			}
+		case "duplicate":
+			params.dup, err = parseDuplicatePolicy(val)
+		case "prefix":
+			params.prefix = val
+		}
+		if err != nil {
+			return params, withCode(CodeIncludeParams, fmt.Errorf("parse include %s: %w", tok.val, err))
+		}
+	}
+}
+
+// include parses the file (or glob matches) at path into sec. Relative
+// paths are resolved against the directory of the including file. pos is
+// the position of the directive, for WithTrace.
+func (p *parser) include(sec *Section, path string, params includeParams, pos position) error {
+	path, err := p.doc.expand(path)
+	if err != nil {
+		return errorAt(pos, err)
+	}
+	if !filepath.IsAbs(path) && p.dir != "" {
+		path = filepath.Join(p.dir, path)
+	}
+
+	files := []string{path}
+	if params.glob {
+		matches, err := p.opts.glob(RootedPath(p.opts.rootPrefix, path))
+		if err != nil {
+			return withCode(CodeIncludeUnreadable, fmt.Errorf("include %q: %w", path, err))
+		}
+		if filepath.IsAbs(path) {
+			for i, m := range matches {
+				matches[i] = unrootedPath(p.opts.rootPrefix, m)
+			}
+		}
+		if len(matches) == 0 {
+			if !params.try {
+				return withCode(CodeIncludeUnreadable, fmt.Errorf("include %q: no files match", path))
+			}
+			p.doc.includes = append(p.doc.includes, Include{Path: path})
+		}
+		sort.Strings(matches)
+		files = matches
+	}
+
+	target := sec
+	if params.prefix != "" {
+		child, ok := sec.Sections[params.prefix]
+		if !ok {
+			child = newSection()
+			sec.Sections[params.prefix] = child
+		}
+		target = child
+	}
+
+	for _, file := range files {
+		if err := p.includeFile(target, file, params, pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) includeFile(sec *Section, file string, params includeParams, pos position) error {
+	clean := filepath.Clean(file)
+	for _, open := range p.chain {
+		if open == clean {
+			return withCode(CodeIncludeCycle, fmt.Errorf("include %q: include cycle", file))
+		}
+	}
+	if len(p.chain) >= maxIncludeDepth {
+		return withCode(CodeIncludeCycle, fmt.Errorf("include %q: includes nested deeper than %d", file, maxIncludeDepth))
+	}
+
+	f, err := p.opts.open(RootedPath(p.opts.rootPrefix, clean))
+	if err != nil {
+		if params.try && errors.Is(err, fs.ErrNotExist) {
+			p.doc.includes = append(p.doc.includes, Include{Path: clean})
+			p.opts.tracef(pos, "skip missing %q", clean)
+			return nil
+		}
+		return withCode(CodeIncludeUnreadable, fmt.Errorf("include %q: %w", file, err))
+	}
+	defer f.Close()
+	p.doc.includes = append(p.doc.includes, Include{Path: clean, Resolved: true})
+	p.opts.tracef(pos, "open %q", clean)
+
+	child := &parser{
+		l:        p.doc.newLexer(f, clean),
+		opts:     p.opts,
+		doc:      p.doc,
+		dir:      filepath.Dir(clean),
+		priority: params.priority,
+		dup:      params.dup,
+		chain:    append(append([]string(nil), p.chain...), clean),
+	}
+	return child.parseFile(sec)
+}
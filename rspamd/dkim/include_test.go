@@ -0,0 +1,213 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestParseInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": `
+selector = "local";
+domain {
+  example.com { selector = "local"; }
+}
+`,
+		"override.d/dkim_signing.conf": `selector = "override";`,
+		"parts/a.inc":                  `use_domain = "header";`,
+		"parts/b.inc":                  `use_domain = "envelope";`,
+	})
+
+	input := `
+.include(priority=10) "override.d/dkim_signing.conf"
+selector = "default";
+domain {
+  other.com { selector = "default"; }
+}
+.include(try=true,priority=1,duplicate=merge) "local.d/dkim_signing.conf"
+.include(try=true) "missing.conf"
+.include(glob=true) "parts/*.inc"
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithIncludeDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, "override", conf.Selector)
+	require.Equal(t, "envelope", conf.UseDomain)
+	require.Equal(t, "local", conf.Domain["example.com"].Selector)
+	require.Equal(t, "default", conf.Domain["other.com"].Selector)
+}
+
+func TestParseIncludePrefixAndNesting(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.conf":       `.include(prefix="dkim_signing") "sub/inner.conf"`,
+		"sub/inner.conf":  `.include "leaf.conf"`,
+		"sub/leaf.conf":   `selector = "leaf";`,
+		"cycle/a.conf":    `.include "b.conf"`,
+		"cycle/b.conf":    `.include "a.conf"`,
+		"dup/error.conf":  `selector = "x";`,
+		"dup/target.conf": `selector = "y";`,
+	})
+
+	doc, err := parseRspamdConfig(strings.NewReader(`.include "main.conf"`), newParseOptions([]Option{WithIncludeDir(dir)}))
+	require.NoError(t, err)
+	require.Equal(t, "leaf", doc.root.Sections["dkim_signing"].Values["selector"])
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`.include "cycle/a.conf"`), WithIncludeDir(dir))
+	require.ErrorContains(t, err, "include cycle")
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`.include "missing.conf"`), WithIncludeDir(dir))
+	require.Error(t, err)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`
+.include "dup/target.conf"
+.include(duplicate=error) "dup/error.conf"
+`), WithIncludeDir(dir))
+	require.ErrorContains(t, err, "duplicate key")
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`.unknown "x"`))
+	require.Error(t, err)
+}
+
+func TestParseIncludeDuplicateStrategies(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"extra.conf": `selector = "extra"; sign_networks = ["10.0.0.0/8"]; domain { b.com { selector = "b"; } }`,
+	})
+	base := `selector = "base"; sign_networks = ["127.0.0.1"]; domain { a.com { selector = "a"; } }` + "\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(base+`.include(duplicate=append) "extra.conf"`), WithIncludeDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, []string{"base", "extra"}, conf.Arrays["selector"])
+	require.Equal(t, []string{"127.0.0.1", "10.0.0.0/8"}, conf.Arrays["sign_networks"])
+	require.Len(t, conf.Domain, 2)
+	require.Empty(t, conf.Warnings)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(base+`.include(duplicate=merge,priority=1) "extra.conf"`), WithIncludeDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, "extra", conf.Selector)
+	require.Equal(t, []string{"127.0.0.1", "10.0.0.0/8"}, conf.Arrays["sign_networks"])
+	require.Len(t, conf.Domain, 2)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(base+`.include(duplicate=rewrite) "extra.conf"`), WithIncludeDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, "extra", conf.Selector)
+	require.Equal(t, []string{"10.0.0.0/8"}, conf.Arrays["sign_networks"])
+	require.Equal(t, []string{"b.com"}, conf.DomainNames())
+
+	// Without a duplicate parameter the WithDuplicateKeys policy applies.
+	conf, err = ParseDKIMSigningConf(strings.NewReader(base+`.include "extra.conf"`), WithIncludeDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, "extra", conf.Selector)
+	require.Len(t, conf.Warnings, 2)
+}
+
+func TestParseTryInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": `selector = "local";`,
+	})
+
+	input := `
+.try_include "local.d/dkim_signing.conf"
+.try_include "override.d/dkim_signing.conf"
+.include(try=true,glob=true) "extra.d/*.conf"
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithIncludeDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, "local", conf.Selector)
+	require.Equal(t, []Include{
+		{Path: filepath.Join(dir, "local.d/dkim_signing.conf"), Resolved: true},
+		{Path: filepath.Join(dir, "override.d/dkim_signing.conf")},
+		{Path: filepath.Join(dir, "extra.d/*.conf")},
+	}, conf.Includes)
+}
+
+func TestParseMacros(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": `selector_map = "${LOCAL_CONFDIR}/local.d/maps.d/dkim_selectors.map";`,
+	})
+
+	input := `
+.include(try=true,priority=5,duplicate=merge) "$LOCAL_CONFDIR/local.d/dkim_signing.conf"
+path = "$DBDIR/dkim/$domain.$selector.key";
+path_map = "$UNKNOWN/paths.map";
+sign_networks = ["$NETWORK"];
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithMacros(map[string]string{
+		"LOCAL_CONFDIR": dir,
+		"NETWORK":       "10.0.0.0/8",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "/var/lib/rspamd/dkim/$domain.$selector.key", conf.Path)
+	require.Equal(t, dir+"/local.d/maps.d/dkim_selectors.map", conf.SelectorMap)
+	require.Equal(t, "$UNKNOWN/paths.map", conf.PathMap)
+	require.Equal(t, []string{"10.0.0.0/8"}, conf.Arrays["sign_networks"])
+}
+
+func TestParseVariables(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"keys.conf": `path = "$keydir/$domain.$selector.key";`,
+	})
+
+	input := `
+$keydir = "$DBDIR/dkim";
+$myselector = "s1";
+selector = "${myselector}";
+.include "$incdir/keys.conf"
+domain {
+  $alt = "${myselector}-alt";
+  example.com { selector = "$alt"; }
+}
+signing_table = ["$alt", "$later"];
+$later = "x";
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithMacros(map[string]string{"incdir": dir}))
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.Equal(t, "/var/lib/rspamd/dkim/$domain.$selector.key", conf.Path)
+	require.Equal(t, "s1-alt", conf.Domain["example.com"].Selector)
+	require.Equal(t, []string{"s1-alt", "$later"}, conf.Arrays["signing_table"])
+	require.NotContains(t, conf.Keys, "$keydir")
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(`$list = ["a"];`))
+	require.EqualError(t, err, "line 1, column 9: [DKIMCONF0001] variable $list must be a single value")
+	_, err = ParseDKIMSigningConf(strings.NewReader(`$a.b = "x";`))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0001] invalid variable name "$a.b"`)
+}
+
+func TestParseRootPrefix(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"etc/rspamd/local.d/dkim_signing.conf": `.include "parts/extra.conf"`,
+		"etc/rspamd/local.d/parts/extra.conf":  `selector = "chroot";`,
+		"etc/rspamd/override.d/a.conf":         `use_domain = "header";`,
+	})
+
+	input := `
+.include "$LOCAL_CONFDIR/local.d/dkim_signing.conf"
+.include(glob=true) "$LOCAL_CONFDIR/override.d/*.conf"
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithRootPrefix(root))
+	require.NoError(t, err)
+	require.Equal(t, "chroot", conf.Selector)
+	require.Equal(t, "header", conf.UseDomain)
+	require.Equal(t, []Include{
+		{Path: "/etc/rspamd/local.d/dkim_signing.conf", Resolved: true},
+		{Path: "/etc/rspamd/local.d/parts/extra.conf", Resolved: true},
+		{Path: "/etc/rspamd/override.d/a.conf", Resolved: true},
+	}, conf.Includes)
+
+	require.Equal(t, filepath.Join(root, "/var/lib/rspamd/dkim/a.key"), RootedPath(root, "/var/lib/rspamd/dkim/a.key"))
+	require.Equal(t, "keys/a.key", RootedPath(root, "keys/a.key"))
+}
@@ -0,0 +1,108 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// InventoryRow describes the signing setup of one domain. Key fields are
+// empty for domains Resolve finds no key for; KeyType is "unreadable" when
+// the key file cannot be read.
+type InventoryRow struct {
+	Domain        string
+	SigningDomain string
+	Selector      string
+	KeyType       string
+	KeyBits       int
+	KeyPath       string
+	KeySource     string
+	DNSStatus     string
+	LastRotated   time.Time
+	Findings      int
+}
+
+// InventoryOptions adds optional columns to an inventory.
+type InventoryOptions struct {
+	// Store supplies LastRotated, as tracked by TrackSelectors.
+	Store SelectorStore
+	// Findings are counted per domain, such as the findings of a lint run.
+	Findings []Finding
+	// DNSStatus, if set, describes the published key of a domain, for
+	// example "ok" or "missing" after a DNS lookup.
+	DNSStatus func(key SigningKey) string
+}
+
+// Inventory returns a row for each domain with a domain rule or map entry in
+// eff, in domain order.
+func Inventory(eff EffectiveSigningConf, opts InventoryOptions) ([]InventoryRow, error) {
+	findings := make(map[string]int)
+	for _, f := range opts.Findings {
+		if f.Domain != "" {
+			findings[domainKey(f.Domain)]++
+		}
+	}
+	var rows []InventoryRow
+	for _, domain := range sortedKeys(configuredDomains(eff)) {
+		row := InventoryRow{Domain: domain, Findings: findings[domain]}
+		key, ok := eff.Resolve(domain)
+		if ok {
+			row.SigningDomain = key.Domain
+			row.Selector = key.Selector
+			row.KeyPath = key.Path
+			row.KeySource = key.Source
+			row.KeyType = "unreadable"
+			if signer, err := eff.signer(key); err == nil {
+				switch k := signer.(type) {
+				case *rsa.PrivateKey:
+					row.KeyType, row.KeyBits = "rsa", k.N.BitLen()
+				case ed25519.PrivateKey:
+					row.KeyType, row.KeyBits = "ed25519", 256
+				}
+			}
+			if opts.DNSStatus != nil {
+				row.DNSStatus = opts.DNSStatus(key)
+			}
+			if opts.Store != nil && key.Selector != "" {
+				rec, found, err := opts.Store.Get(domain, key.Selector)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					row.LastRotated = rec.LastRotated
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// inventoryHeader names the columns written by WriteInventoryCSV.
+var inventoryHeader = []string{"domain", "signing_domain", "selector", "key_type", "key_bits", "key_path", "key_source", "dns_status", "last_rotated", "findings"}
+
+// WriteInventoryCSV writes rows as CSV with a header line. Unknown key bits
+// and rotation times are left empty; times are in RFC 3339.
+func WriteInventoryCSV(w io.Writer, rows []InventoryRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(inventoryHeader); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		bits, rotated := "", ""
+		if r.KeyBits > 0 {
+			bits = strconv.Itoa(r.KeyBits)
+		}
+		if !r.LastRotated.IsZero() {
+			rotated = r.LastRotated.UTC().Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{r.Domain, r.SigningDomain, r.Selector, r.KeyType, bits, r.KeyPath, r.KeySource, r.DNSStatus, rotated, strconv.Itoa(r.Findings)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,53 @@
+package dkim
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, filepath.Join(dir, "a.key"))
+	no := false
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			TryFallback: &no,
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "s1", Path: filepath.Join(dir, "a.key")},
+				"b.com": {Selector: "esp", Path: "/missing.key", SigningDomain: "esp.net"},
+			},
+		},
+		Maps: &Maps{Selectors: map[string]string{"c.com": "s2"}},
+	}
+	rotated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := &MemorySelectorStore{}
+	require.NoError(t, store.Put(SelectorRecord{Domain: "a.com", Selector: "s1", LastRotated: rotated}))
+
+	rows, err := Inventory(eff, InventoryOptions{
+		Store:     store,
+		Findings:  []Finding{{Domain: "A.com."}, {Domain: "a.com"}, {Domain: "b.com"}, {}},
+		DNSStatus: func(key SigningKey) string { return "ok " + key.Selector },
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, InventoryRow{
+		Domain: "a.com", SigningDomain: "a.com", Selector: "s1", KeyType: "ed25519", KeyBits: 256,
+		KeyPath: filepath.Join(dir, "a.key"), KeySource: rows[0].KeySource, DNSStatus: "ok s1", LastRotated: rotated, Findings: 2,
+	}, rows[0])
+	require.Equal(t, "unreadable", rows[1].KeyType)
+	require.Equal(t, "esp.net", rows[1].SigningDomain)
+	require.Equal(t, InventoryRow{Domain: "c.com"}, rows[2])
+
+	var b strings.Builder
+	require.NoError(t, WriteInventoryCSV(&b, rows))
+	lines := strings.Split(b.String(), "\n")
+	require.Equal(t, "domain,signing_domain,selector,key_type,key_bits,key_path,key_source,dns_status,last_rotated,findings", lines[0])
+	require.Equal(t, "b.com,esp.net,esp,unreadable,,/missing.key,"+rows[1].KeySource+",ok esp,,1", lines[2])
+	require.Equal(t, "c.com,,,,,,,,,0", lines[3])
+	require.Contains(t, lines[1], ",ed25519,256,")
+	require.Contains(t, lines[1], ",2024-01-02T03:04:05Z,2")
+}
@@ -0,0 +1,119 @@
+package dkim
+
+import (
+	"container/list"
+	"crypto"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeyCache keeps parsed private keys by path so that repeated lookups do not
+// read and parse the PEM file again. A cached key is used only while the
+// modification time and size of its file are unchanged. Once the cache holds
+// its size limit of keys, the least recently used one is dropped. A KeyCache
+// is safe for concurrent use.
+type KeyCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type keyCacheEntry struct {
+	path    string
+	modTime time.Time
+	size    int64
+	key     crypto.Signer
+}
+
+// NewKeyCache returns a cache holding at most size keys. A size of zero or
+// less means no limit.
+func NewKeyCache(size int) *KeyCache {
+	return &KeyCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// ReadPrivateKey is like the package-level ReadPrivateKey but returns the
+// cached key if the file at path has not changed. Errors are not cached.
+func (c *KeyCache) ReadPrivateKey(path string) (crypto.Signer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		c.remove(path)
+		return nil, err
+	}
+	c.mu.Lock()
+	if elem, ok := c.entries[path]; ok {
+		e := elem.Value.(*keyCacheEntry)
+		if e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return e.key, nil
+		}
+	}
+	c.mu.Unlock()
+
+	key, err := ReadPrivateKey(path)
+	if err != nil {
+		c.remove(path)
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &keyCacheEntry{path: path, modTime: info.ModTime(), size: info.Size(), key: key}
+	if elem, ok := c.entries[path]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return key, nil
+	}
+	c.entries[path] = c.lru.PushFront(e)
+	if c.size > 0 && c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*keyCacheEntry).path)
+	}
+	return key, nil
+}
+
+// Len returns the number of cached keys.
+func (c *KeyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *KeyCache) remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[path]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, path)
+	}
+}
+
+// signer returns the private key of key: its RawKey if set, otherwise the
+// file at its Path, read like readKey does.
+func (e EffectiveSigningConf) signer(key SigningKey) (crypto.Signer, error) {
+	if key.RawKey != "" {
+		return ParseRawKey(key.RawKey)
+	}
+	return e.readKey(key.Path)
+}
+
+// readKey reads the key file at path, a path as resolved from the
+// configuration, below RootPrefix and through Keys and Sandbox if set.
+func (e EffectiveSigningConf) readKey(path string) (crypto.Signer, error) {
+	path = RootedPath(e.RootPrefix, path)
+	if e.Sandbox != nil {
+		if err := e.Sandbox.Check(path); err != nil {
+			return nil, err
+		}
+	}
+	if e.Keys != nil {
+		return e.Keys.ReadPrivateKey(path)
+	}
+	return ReadPrivateKey(path)
+}
@@ -0,0 +1,68 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeEd25519Key(t *testing.T, path string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+}
+
+func TestKeyCache(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.key"), filepath.Join(dir, "b.key")
+	writeEd25519Key(t, a)
+	writeEd25519Key(t, b)
+
+	cache := NewKeyCache(1)
+	first, err := cache.ReadPrivateKey(a)
+	require.NoError(t, err)
+	again, err := cache.ReadPrivateKey(a)
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+	require.Equal(t, 1, cache.Len())
+
+	// A rewritten file is read again once its mtime changes.
+	writeEd25519Key(t, a)
+	require.NoError(t, os.Chtimes(a, time.Now(), time.Now().Add(time.Hour)))
+	changed, err := cache.ReadPrivateKey(a)
+	require.NoError(t, err)
+	require.NotEqual(t, first, changed)
+
+	_, err = cache.ReadPrivateKey(b)
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.Len())
+
+	require.NoError(t, os.Remove(b))
+	_, err = cache.ReadPrivateKey(b)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestPlanUsesKeyCache(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, filepath.Join(dir, "s1.key"))
+	eff := EffectiveSigningConf{
+		Conf:       &DKIMSigningConf{Selector: "s1", Path: "/s1.key"},
+		RootPrefix: dir,
+		Keys:       NewKeyCache(0),
+	}
+	keys, err := eff.Plan("example.com", AlgorithmPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, Ed25519SHA256, keys[0].Algorithm)
+	require.Equal(t, 1, eff.Keys.Len())
+}
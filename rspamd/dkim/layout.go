@@ -0,0 +1,163 @@
+package dkim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// KeyLayout arranges key files below a key directory. Template is the layout
+// written as a dkim_signing path relative to the directory, or empty if the
+// layout cannot be expressed with $domain and $selector.
+type KeyLayout struct {
+	Name     string
+	Template string
+	path     func(domain, selector string) string
+}
+
+// Path returns the key file for domain and selector below dir.
+func (l KeyLayout) Path(dir, domain, selector string) string {
+	return filepath.Join(dir, l.path(domainKey(domain), selector))
+}
+
+// FlatLayout keeps every key in dir as domain.selector.key.
+var FlatLayout = KeyLayout{
+	Name:     "flat",
+	Template: "$domain.$selector.key",
+	path: func(domain, selector string) string {
+		return domain + "." + selector + ".key"
+	},
+}
+
+// DomainDirLayout keeps the keys of each domain in a directory of its own
+// as domain/selector.key.
+var DomainDirLayout = KeyLayout{
+	Name:     "per-domain",
+	Template: "$domain/$selector.key",
+	path: func(domain, selector string) string {
+		return filepath.Join(domain, selector+".key")
+	},
+}
+
+// HashedLayout spreads keys over levels of directories named after two hex
+// digits of the SHA-256 of the domain, as ab/cd/domain.selector.key, so no
+// directory holds more than 256 entries above the last level. Use it where
+// directory size limits or quotas make FlatLayout impractical.
+func HashedLayout(levels int) KeyLayout {
+	return KeyLayout{
+		Name: fmt.Sprintf("hashed-%d", levels),
+		path: func(domain, selector string) string {
+			sum := sha256.Sum256([]byte(domain))
+			hash := hex.EncodeToString(sum[:])
+			parts := make([]string, 0, levels+1)
+			for i := 0; i < levels && 2*i+2 <= len(hash); i++ {
+				parts = append(parts, hash[2*i:2*i+2])
+			}
+			return filepath.Join(append(parts, domain+"."+selector+".key")...)
+		},
+	}
+}
+
+// KeyMove is a key file to move for a layout change.
+type KeyMove struct {
+	Domain   string
+	Selector string
+	From     string
+	To       string
+}
+
+// RelayoutKeys moves the keys of eff stored in dir under layout from to
+// layout to. It rewrites the global path if it is the from template and to
+// has one, and otherwise points domain rules or path map entries at the new
+// files. It returns the updated configuration, the files to move, and the
+// domains it left alone because their key is not in the from layout or the
+// configuration cannot address the new file without changing other keys.
+func RelayoutKeys(eff EffectiveSigningConf, dir string, from, to KeyLayout) (EffectiveSigningConf, []KeyMove, []string) {
+	out, conf, maps := eff.migrationCopy()
+	if from.Template != "" && to.Template != "" && conf.Path == filepath.Join(dir, from.Template) {
+		conf.Path = filepath.Join(dir, to.Template)
+	}
+
+	var moves []KeyMove
+	var skipped []string
+	for _, domain := range sortedKeys(configuredDomains(eff)) {
+		key, ok := eff.resolve(domain)
+		if !ok || key.Path != from.Path(dir, key.Domain, key.Selector) {
+			skipped = append(skipped, domain)
+			continue
+		}
+		move := KeyMove{Domain: key.Domain, Selector: key.Selector, From: key.Path, To: to.Path(dir, key.Domain, key.Selector)}
+		if got, ok := out.resolve(domain); ok && got.Selector == key.Selector && got.Path == move.To {
+			moves = append(moves, move)
+			continue
+		}
+
+		rules, paths := conf.Domain, maps.Paths
+		name, rule, hasRule := domain, DomainRule{}, false
+		for k, r := range conf.Domain {
+			if k != "*" && domainKey(k) == domain {
+				name, rule, hasRule = k, r, true
+			}
+		}
+		switch {
+		case hasRule && rule.Path != "":
+			conf.Domain = copyRules(conf.Domain)
+			rule.Path = move.To
+			conf.Domain[name] = rule
+		case maps.Paths != nil || conf.PathMap != "":
+			maps.Paths = setMapEntry(maps.Paths, domain, move.To)
+		default:
+			conf.Domain = copyRules(conf.Domain)
+			rule.Path = move.To
+			conf.Domain[name] = rule
+		}
+		if got, ok := out.resolve(domain); !ok || got.Selector != key.Selector || got.Path != move.To {
+			conf.Domain, maps.Paths = rules, paths
+			skipped = append(skipped, domain)
+			continue
+		}
+		moves = append(moves, move)
+	}
+	out.finishMigration()
+	return out, moves, skipped
+}
+
+func copyRules(rules map[string]DomainRule) map[string]DomainRule {
+	out := make(map[string]DomainRule, len(rules)+1)
+	for k, r := range rules {
+		out[k] = r
+	}
+	return out
+}
+
+// MoveKeys renames the key files of moves below root, see RootedPath, and
+// creates directories as needed with mode 0700 so private keys stay
+// readable only by their owner. It refuses to overwrite existing files and
+// stops at the first failure, returning the number of moves done.
+func MoveKeys(root string, moves []KeyMove) (int, error) {
+	if err := checkWritable("move keys"); err != nil {
+		return 0, err
+	}
+	for i, m := range moves {
+		from, to := RootedPath(root, m.From), RootedPath(root, m.To)
+		if from == to {
+			continue
+		}
+		if _, err := os.Lstat(to); err == nil {
+			return i, fmt.Errorf("move %s: %s already exists", m.From, m.To)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return i, err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0o700); err != nil {
+			return i, err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return i, err
+		}
+	}
+	return len(moves), nil
+}
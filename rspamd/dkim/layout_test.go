@@ -0,0 +1,92 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyLayouts(t *testing.T) {
+	require.Equal(t, "/keys/example.com.s1.key", FlatLayout.Path("/keys", "Example.COM.", "s1"))
+	require.Equal(t, "/keys/example.com/s1.key", DomainDirLayout.Path("/keys", "example.com", "s1"))
+	// sha256("example.com") starts with a379a6f6.
+	require.Equal(t, "/keys/a3/79/example.com.s1.key", HashedLayout(2).Path("/keys", "example.com", "s1"))
+	require.Equal(t, "hashed-2", HashedLayout(2).Name)
+}
+
+func TestRelayoutKeys(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Selector: "dkim",
+			Path:     "/keys/$domain.$selector.key",
+			Domain: map[string]DomainRule{
+				"b.com": {Selector: "s2", Path: "/keys/b.com.s2.key"},
+				"c.com": {Path: "/elsewhere/c.key"},
+			},
+		},
+		Maps: &Maps{Selectors: map[string]string{"a.com": "s1"}},
+	}
+
+	out, moves, skipped := RelayoutKeys(eff, "/keys", FlatLayout, DomainDirLayout)
+	require.Equal(t, "/keys/$domain/$selector.key", out.Conf.Path)
+	require.Equal(t, "/keys/b.com/s2.key", out.Conf.Domain["b.com"].Path)
+	require.Equal(t, "/elsewhere/c.key", out.Conf.Domain["c.com"].Path)
+	require.Equal(t, []KeyMove{
+		{Domain: "a.com", Selector: "s1", From: "/keys/a.com.s1.key", To: "/keys/a.com/s1.key"},
+		{Domain: "b.com", Selector: "s2", From: "/keys/b.com.s2.key", To: "/keys/b.com/s2.key"},
+	}, moves)
+	require.Equal(t, []string{"c.com"}, skipped)
+	require.Equal(t, "/keys/$domain.$selector.key", eff.Conf.Path)
+
+	// The hashed layout has no template, so each domain gets its own path.
+	hashed := HashedLayout(1)
+	out, moves, skipped = RelayoutKeys(eff, "/keys", FlatLayout, hashed)
+	require.Equal(t, "/keys/$domain.$selector.key", out.Conf.Path)
+	require.Len(t, moves, 2)
+	require.Equal(t, []string{"c.com"}, skipped)
+	for _, m := range moves {
+		key, ok := out.Resolve(m.Domain)
+		require.True(t, ok)
+		require.Equal(t, hashed.Path("/keys", m.Domain, m.Selector), key.Path)
+		require.Equal(t, m.Selector, key.Selector)
+	}
+
+	// A path map takes the new paths when one is in use, and a new rule
+	// would take a.com out of the * rule.
+	eff.Conf.Domain["*"] = DomainRule{Selector: "wild"}
+	_, moves, skipped = RelayoutKeys(eff, "/keys", FlatLayout, hashed)
+	require.Equal(t, []string{"a.com", "c.com"}, skipped)
+	require.Len(t, moves, 1)
+
+	eff.Conf.PathMap = "/etc/rspamd/maps.d/paths.map"
+	out, moves, skipped = RelayoutKeys(eff, "/keys", FlatLayout, hashed)
+	require.Equal(t, []string{"c.com"}, skipped)
+	require.Len(t, moves, 2)
+	require.Equal(t, map[string]string{"a.com": hashed.Path("/keys", "a.com", "wild")}, out.Maps.Paths)
+}
+
+func TestMoveKeys(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "keys"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keys/a.com.s1.key"), []byte("a"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keys/b.com.s1.key"), []byte("b"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "keys/b.com"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keys/b.com/s1.key"), []byte("old"), 0o600))
+
+	moves := []KeyMove{
+		{Domain: "a.com", From: "/keys/a.com.s1.key", To: "/keys/a.com/s1.key"},
+		{Domain: "b.com", From: "/keys/b.com.s1.key", To: "/keys/b.com/s1.key"},
+	}
+	n, err := MoveKeys(root, moves)
+	require.EqualError(t, err, "move /keys/b.com.s1.key: /keys/b.com/s1.key already exists")
+	require.Equal(t, 1, n)
+
+	data, err := os.ReadFile(filepath.Join(root, "keys/a.com/s1.key"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+	info, err := os.Stat(filepath.Join(root, "keys/a.com"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+}
@@ -0,0 +1,166 @@
+package dkim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SelectorRecord is the tracked lifetime of a selector of a domain.
+// LastRotated is when its key was last replaced; TrackSelectors sets it to
+// FirstSeen, and tools that regenerate a key under the same selector update
+// it with Put.
+type SelectorRecord struct {
+	Domain      string    `json:"domain"`
+	Selector    string    `json:"selector"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastRotated time.Time `json:"last_rotated"`
+}
+
+// SelectorStore persists SelectorRecords, keyed by domain and selector.
+type SelectorStore interface {
+	Get(domain, selector string) (SelectorRecord, bool, error)
+	Put(rec SelectorRecord) error
+}
+
+// MemorySelectorStore is a SelectorStore kept in memory. It is safe for
+// concurrent use.
+type MemorySelectorStore struct {
+	mu      sync.Mutex
+	records map[[2]string]SelectorRecord
+}
+
+// Get returns the record of selector for domain.
+func (m *MemorySelectorStore) Get(domain, selector string) (SelectorRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[[2]string{domain, selector}]
+	return rec, ok, nil
+}
+
+// Put stores rec, replacing any record of its domain and selector.
+func (m *MemorySelectorStore) Put(rec SelectorRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.records == nil {
+		m.records = make(map[[2]string]SelectorRecord)
+	}
+	m.records[[2]string{rec.Domain, rec.Selector}] = rec
+	return nil
+}
+
+// Records returns the stored records sorted by domain and selector.
+func (m *MemorySelectorStore) Records() []SelectorRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]SelectorRecord, 0, len(m.records))
+	for _, rec := range m.records {
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Domain != out[j].Domain {
+			return out[i].Domain < out[j].Domain
+		}
+		return out[i].Selector < out[j].Selector
+	})
+	return out
+}
+
+// FileSelectorStore is a SelectorStore kept in a JSON file, which is
+// rewritten atomically on every Put.
+type FileSelectorStore struct {
+	path string
+	mem  MemorySelectorStore
+}
+
+// OpenFileSelectorStore reads the store at path. A missing file is an empty
+// store.
+func OpenFileSelectorStore(path string) (*FileSelectorStore, error) {
+	s := &FileSelectorStore{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var recs []SelectorRecord
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	for _, rec := range recs {
+		_ = s.mem.Put(rec)
+	}
+	return s, nil
+}
+
+// Get returns the record of selector for domain.
+func (s *FileSelectorStore) Get(domain, selector string) (SelectorRecord, bool, error) {
+	return s.mem.Get(domain, selector)
+}
+
+// Put stores rec and writes the file.
+func (s *FileSelectorStore) Put(rec SelectorRecord) error {
+	_ = s.mem.Put(rec)
+	data, err := json.MarshalIndent(s.mem.Records(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return DirSink(filepath.Dir(s.path)).WriteFile(filepath.Base(s.path), append(data, '\n'), 0o644)
+}
+
+// TrackSelectors records the selector Resolve picks for each configured
+// domain of eff. A selector not yet in store is recorded as first seen and
+// rotated at now; known selectors are left as they are.
+func TrackSelectors(eff EffectiveSigningConf, store SelectorStore, now time.Time) error {
+	for _, domain := range sortedKeys(configuredDomains(eff)) {
+		key, ok := eff.Resolve(domain)
+		if !ok || key.Selector == "" {
+			continue
+		}
+		_, found, err := store.Get(domain, key.Selector)
+		if err != nil {
+			return err
+		}
+		if !found {
+			if err := store.Put(SelectorRecord{Domain: domain, Selector: key.Selector, FirstSeen: now, LastRotated: now}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckKeyAge lists the selectors eff currently signs with that store
+// tracks, oldest rotation first, due for rotation maxAge after LastRotated.
+// Overdue is set for keys older than maxAge at now. Selectors store does not
+// know are skipped; run TrackSelectors first.
+func CheckKeyAge(eff EffectiveSigningConf, store SelectorStore, maxAge time.Duration, now time.Time) ([]Rotation, error) {
+	var out []Rotation
+	for _, domain := range sortedKeys(configuredDomains(eff)) {
+		key, ok := eff.Resolve(domain)
+		if !ok || key.Selector == "" {
+			continue
+		}
+		rec, found, err := store.Get(domain, key.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		due := rec.LastRotated.Add(maxAge)
+		r := Rotation{Domain: domain, Selector: key.Selector, RotateAfter: due, Overdue: now.After(due)}
+		if eff.Maps != nil {
+			r.Owner = eff.Maps.Annotations[domain][AnnotationOwner]
+		}
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].RotateAfter.Before(out[j].RotateAfter) })
+	return out, nil
+}
@@ -0,0 +1,56 @@
+package dkim
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorLifetime(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{Selector: "s1", Path: "/keys/$domain.key", Domain: map[string]DomainRule{"b.com": {Selector: "b1"}}},
+		Maps: &Maps{Selectors: map[string]string{"a.com": "a1"}, Annotations: map[string]map[string]string{"a.com": {AnnotationOwner: "@mail"}}},
+	}
+	path := filepath.Join(t.TempDir(), "state", "selectors.json")
+	store, err := OpenFileSelectorStore(path)
+	require.NoError(t, err)
+	require.NoError(t, TrackSelectors(eff, store, day(1)))
+
+	// b.com rotates to a new selector; a.com keeps its first one.
+	eff.Conf.Domain["b.com"] = DomainRule{Selector: "b2"}
+	store, err = OpenFileSelectorStore(path)
+	require.NoError(t, err)
+	require.NoError(t, TrackSelectors(eff, store, day(20)))
+	rec, ok, err := store.Get("b.com", "b1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, day(1), rec.FirstSeen.UTC())
+
+	got, err := CheckKeyAge(eff, store, 14*24*time.Hour, day(25))
+	require.NoError(t, err)
+	require.Equal(t, []Rotation{
+		{Domain: "a.com", Selector: "a1", Owner: "@mail", RotateAfter: day(15), Overdue: true},
+		{Domain: "b.com", Selector: "b2", RotateAfter: time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)},
+	}, utcRotations(got))
+
+	// A key regenerated under the same selector resets its age.
+	require.NoError(t, store.Put(SelectorRecord{Domain: "a.com", Selector: "a1", FirstSeen: day(1), LastRotated: day(24)}))
+	got, err = CheckKeyAge(eff, &MemorySelectorStore{}, 14*24*time.Hour, day(25))
+	require.NoError(t, err)
+	require.Empty(t, got)
+	store, err = OpenFileSelectorStore(path)
+	require.NoError(t, err)
+	got, err = CheckKeyAge(eff, store, 14*24*time.Hour, day(25))
+	require.NoError(t, err)
+	require.False(t, got[0].Overdue)
+}
+
+func utcRotations(rs []Rotation) []Rotation {
+	for i := range rs {
+		rs[i].RotateAfter = rs[i].RotateAfter.UTC()
+	}
+	return rs
+}
@@ -0,0 +1,80 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the resources spent parsing untrusted input. A zero field
+// means no limit.
+type Limits struct {
+	// InputSize is the total number of bytes read, included files counted.
+	InputSize int64
+	// StringLength is the length in bytes of a single key or value.
+	StringLength int
+	// Depth is how deeply sections may nest.
+	Depth int
+	// MapEntries is the number of keys in one section, elements in one
+	// array or entries in one map file.
+	MapEntries int
+}
+
+// UntrustedLimits are limits suitable for configurations uploaded by third
+// parties.
+var UntrustedLimits = Limits{
+	InputSize:    4 << 20,
+	StringLength: 64 << 10,
+	Depth:        32,
+	MapEntries:   100000,
+}
+
+// ErrLimitExceeded is wrapped by errors reporting input over a Limits bound.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// WithLimits bounds the input accepted by the parser.
+func WithLimits(limits Limits) Option {
+	return func(o *parseOptions) {
+		o.limits = limits
+	}
+}
+
+// limitReader fails once more than max bytes have been read through any of
+// the readers sharing the read counter.
+type limitReader struct {
+	r    io.Reader
+	read *int64
+	max  int64
+}
+
+func newLimitReader(r io.Reader, read *int64, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &limitReader{r: r, read: read, max: max}
+}
+
+func (l *limitReader) Read(b []byte) (int, error) {
+	if *l.read >= l.max {
+		// Probe for more data so input of exactly max bytes is accepted.
+		var probe [1]byte
+		if n, err := l.r.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: input larger than %d bytes", ErrLimitExceeded, l.max)
+	}
+	if rest := l.max - *l.read; int64(len(b)) > rest {
+		b = b[:rest]
+	}
+	n, err := l.r.Read(b)
+	*l.read += int64(n)
+	return n, err
+}
+
+// checkEntries fails if n exceeds the MapEntries limit.
+func (o *parseOptions) checkEntries(n int) error {
+	if o.limits.MapEntries > 0 && n > o.limits.MapEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrLimitExceeded, o.limits.MapEntries)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	input := `selector = "s1";` + "\n"
+
+	_, err := ParseDKIMSigningConf(strings.NewReader(input), WithLimits(Limits{InputSize: int64(len(input))}))
+	require.NoError(t, err)
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithLimits(Limits{InputSize: int64(len(input) - 1)}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("path = \""+strings.Repeat("x", 100)+"\";\n"), WithLimits(Limits{StringLength: 64}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	requireParseError(t, err, "", 1, 8)
+
+	deep := strings.Repeat("a {\n", 5) + strings.Repeat("}\n", 5)
+	_, err = ParseDKIMSigningConf(strings.NewReader(deep), WithLimits(Limits{Depth: 5}))
+	require.NoError(t, err)
+	_, err = ParseDKIMSigningConf(strings.NewReader(deep), WithLimits(Limits{Depth: 4}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	requireParseError(t, err, "", 5, 1)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("a = 1;\nb = 2;\na = 3;\n"), WithLimits(Limits{MapEntries: 2}))
+	require.NoError(t, err)
+	_, err = ParseDKIMSigningConf(strings.NewReader("a = 1;\nb = 2;\nc = 3;\n"), WithLimits(Limits{MapEntries: 2}))
+	requireParseError(t, err, "", 3, 1)
+	_, err = ParseDKIMSigningConf(strings.NewReader("a = [1, 2, 3];\n"), WithLimits(Limits{MapEntries: 2}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = ParseDKIMSelectorsMap(strings.NewReader("a.com s1\nb.com s2\nc.com s3\n"), WithLimits(Limits{MapEntries: 2}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestLimitsCountIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"inc.conf": strings.Repeat("# padding\n", 20),
+	})
+	input := `.include "` + filepath.Join(dir, "inc.conf") + `"` + "\n"
+
+	_, err := ParseDKIMSigningConf(strings.NewReader(input), WithLimits(Limits{InputSize: 150}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithLimits(Limits{InputSize: 150}), WithRecovery())
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestLimitsExpandedVariables(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("$v0 = \"" + strings.Repeat("x", 1024) + "\";\n")
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&b, "$v%d = \"$v%d$v%d\";\n", i, i-1, i-1)
+	}
+	b.WriteString("path = \"$v20\";\n")
+	require.Less(t, b.Len(), 2048)
+
+	_, err := ParseDKIMSigningConf(strings.NewReader(b.String()), WithLimits(UntrustedLimits))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	requireParseError(t, err, "", 8, 7)
+
+	// Each value stays short, but together they exceed InputSize.
+	b.Reset()
+	b.WriteString("$v = \"" + strings.Repeat("x", 1000) + "\";\n")
+	b.WriteString("list = [" + strings.Repeat("\"$v$v\", ", 200) + "];\n")
+	_, err = ParseDKIMSigningConf(strings.NewReader(b.String()), WithLimits(Limits{InputSize: 100000, StringLength: 4096}))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("$v = \"xx\";\npath = \"$v/$v\";\n"), WithLimits(UntrustedLimits))
+	require.NoError(t, err)
+}
@@ -0,0 +1,113 @@
+package dkim
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// LoadDKIMSigningConf reads the dkim_signing.conf at name in fsys together
+// with its includes and the selector_map, path_map and sign_networks map
+// files it references. Absolute include and map paths are looked up
+// relative to the root of fsys, relative ones against the directory of the
+// including file. Maps that are not local files, such as http:// URLs, are
+// left unloaded and reported in the configuration's Warnings.
+func LoadDKIMSigningConf(fsys fs.FS, name string, opts ...Option) (EffectiveSigningConf, error) {
+	f, err := fsys.Open(fsPath(name))
+	if err != nil {
+		return EffectiveSigningConf{}, err
+	}
+	defer f.Close()
+
+	dir := "/" + path.Dir(fsPath(name))
+	opts = append([]Option{WithFilename(name), WithIncludeDir(dir)}, opts...)
+	opts = append(opts, WithFS(fsys))
+	conf, err := ParseDKIMSigningConf(f, opts...)
+	if err != nil {
+		return EffectiveSigningConf{}, err
+	}
+	eff := EffectiveSigningConf{Conf: conf}
+	if _, err := eff.loadMaps(fsys, name, dir, opts); err != nil {
+		return EffectiveSigningConf{}, err
+	}
+	return eff, nil
+}
+
+// loadMaps sets e.Maps from the selector_map, path_map and sign_networks
+// map files referenced by e.Conf, which was read from name. Relative map paths are resolved
+// against dir. It returns the map files read, with Role set to the option
+// that references them.
+func (e *EffectiveSigningConf) loadMaps(fsys fs.FS, name, dir string, opts []Option) ([]SourceFile, error) {
+	conf := e.Conf
+	e.Maps = &Maps{}
+	var loaded []SourceFile
+	var entries [][]MapEntry
+	for _, m := range []struct {
+		key, ref string
+		dst      *map[string]string
+	}{
+		{"selector_map", conf.SelectorMap, &e.Maps.Selectors},
+		{"path_map", conf.PathMap, &e.Maps.Paths},
+	} {
+		if m.ref == "" {
+			continue
+		}
+		file, ok := e.localMap(name, dir, m.key, m.ref, opts)
+		if !ok {
+			continue
+		}
+		list, err := loadMapEntries(fsys, file, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.key, err)
+		}
+		*m.dst = make(map[string]string, len(list))
+		for _, e := range list {
+			(*m.dst)[e.Key] = e.Value
+		}
+		entries = append(entries, list)
+		loaded = append(loaded, SourceFile{Role: m.key, Path: file})
+	}
+	e.Maps.Annotations = MapAnnotations(entries...)
+
+	if ref := conf.SignNetworksMap; ref != "" {
+		if file, ok := e.localMap(name, dir, "sign_networks", ref, opts); ok {
+			f, err := fsys.Open(fsPath(file))
+			if err != nil {
+				return nil, fmt.Errorf("sign_networks: %w", err)
+			}
+			defer f.Close()
+			if e.Maps.SignNetworks, err = ParseNetworksMap(f, append(opts, WithFilename(file))...); err != nil {
+				return nil, fmt.Errorf("sign_networks: %w", err)
+			}
+			loaded = append(loaded, SourceFile{Role: "sign_networks", Path: file})
+		}
+	}
+	return loaded, nil
+}
+
+// localMap returns the path of the map file ref given for option key,
+// resolved against dir. Maps that are not local files are reported as a
+// warning of e.Conf, read from name, and to the handlers set in opts.
+func (e *EffectiveSigningConf) localMap(name, dir, key, ref string, opts []Option) (string, bool) {
+	file := strings.TrimPrefix(ref, "file://")
+	if strings.Contains(file, "://") {
+		w := Warning{File: name, Code: CodeMapNotLoaded, Message: fmt.Sprintf("%s %q is not a local file and was not loaded", key, ref)}
+		e.Conf.Warnings = append(e.Conf.Warnings, w)
+		newParseOptions(opts).emit(w)
+		return "", false
+	}
+	if !path.IsAbs(file) {
+		file = path.Join(dir, file)
+	}
+	return file, true
+}
+
+func loadMapEntries(fsys fs.FS, name string, opts []Option) ([]MapEntry, error) {
+	f, err := fsys.Open(fsPath(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMapEntries(f, newParseOptions(opts))
+}
@@ -0,0 +1,58 @@
+package dkim
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDKIMSigningConf(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/rspamd/local.d/dkim_signing.conf": {Data: []byte(`
+selector = "s1";
+selector_map = "/etc/rspamd/local.d/maps.d/dkim_selectors.map";
+path_map = "file://maps.d/dkim_paths.map";
+.include(try=true) "$LOCAL_CONFDIR/local.d/dkim_domains.inc"
+`)},
+		"etc/rspamd/local.d/dkim_domains.inc":          {Data: []byte(`domain { a.com { selector = "a"; } }`)},
+		"etc/rspamd/local.d/maps.d/dkim_selectors.map": {Data: []byte("b.com s2 # owner=team-b\n")},
+		"etc/rspamd/local.d/maps.d/dkim_paths.map":     {Data: []byte("b.com /keys/b.com.key\n")},
+	}
+
+	eff, err := LoadDKIMSigningConf(fsys, "etc/rspamd/local.d/dkim_signing.conf")
+	require.NoError(t, err)
+	require.Equal(t, "a", eff.Conf.Domain["a.com"].Selector)
+	require.Equal(t, map[string]string{"b.com": "s2"}, eff.Maps.Selectors)
+	require.Equal(t, map[string]string{"b.com": "/keys/b.com.key"}, eff.Maps.Paths)
+	require.Equal(t, map[string]map[string]string{"b.com": {"owner": "team-b"}}, eff.Maps.Annotations)
+	require.Empty(t, eff.Conf.Warnings)
+
+	key, ok := eff.Resolve("b.com")
+	require.True(t, ok)
+	require.Equal(t, "s2", key.Selector)
+	require.Equal(t, "/keys/b.com.key", key.Path)
+}
+
+func TestLoadDKIMSigningConfErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dkim_signing.conf": {Data: []byte(`selector_map = "https://maps.example/selectors.map"; path_map = "/missing.map";`)},
+		"broken.conf":       {Data: []byte(`selector = "s1`)},
+	}
+
+	_, err := LoadDKIMSigningConf(fsys, "dkim_signing.conf")
+	require.ErrorContains(t, err, "path_map: open missing.map")
+
+	fsys["dkim_signing.conf"] = &fstest.MapFile{Data: []byte(`selector_map = "https://maps.example/selectors.map";`)}
+	eff, err := LoadDKIMSigningConf(fsys, "dkim_signing.conf")
+	require.NoError(t, err)
+	require.Nil(t, eff.Maps.Selectors)
+	require.Equal(t, []Warning{{File: "dkim_signing.conf", Code: CodeMapNotLoaded, Message: `selector_map "https://maps.example/selectors.map" is not a local file and was not loaded`}}, eff.Conf.Warnings)
+
+	_, err = LoadDKIMSigningConf(fsys, "broken.conf")
+	require.EqualError(t, err, "broken.conf:1:12: [DKIMCONF0002] unterminated string")
+
+	_, err = LoadDKIMSigningConf(fsys, "nope.conf")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,69 @@
+package dkim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMacros returns the builtin rspamd macros with the values used by a
+// default installation. The returned map may be modified freely.
+func DefaultMacros() map[string]string {
+	return map[string]string{
+		"CONFDIR":       "/etc/rspamd",
+		"LOCAL_CONFDIR": "/etc/rspamd",
+		"DBDIR":         "/var/lib/rspamd",
+		"RUNDIR":        "/var/run/rspamd",
+		"LOGDIR":        "/var/log/rspamd",
+		"SHAREDIR":      "/usr/share/rspamd",
+		"PLUGINSDIR":    "/usr/share/rspamd/plugins",
+		"RULESDIR":      "/usr/share/rspamd/rules",
+		"LUALIBDIR":     "/usr/share/rspamd/lib",
+		"WWWDIR":        "/usr/share/rspamd/www",
+		"PREFIX":        "/usr",
+	}
+}
+
+// expandMacros replaces $NAME and ${NAME} references to known macros in s.
+// Unknown names, such as the $domain and $selector placeholders rspamd fills
+// in at signing time, are left untouched. A positive max bounds the length
+// of the result.
+func expandMacros(s string, macros map[string]string, max int) (string, error) {
+	if len(macros) == 0 || !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '$' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		name, end := "", i+1
+		if end < len(s) && s[end] == '{' {
+			if close := strings.IndexByte(s[end:], '}'); close > 0 {
+				name = s[end+1 : end+close]
+				end += close + 1
+			}
+		} else {
+			for end < len(s) && isMacroChar(s[end]) {
+				end++
+			}
+			name = s[i+1 : end]
+		}
+		if val, ok := macros[name]; ok && name != "" {
+			if max > 0 && b.Len()+len(val)+len(s)-end > max {
+				return "", fmt.Errorf("%w: expanded value longer than %d bytes", ErrLimitExceeded, max)
+			}
+			b.WriteString(val)
+			i = end
+			continue
+		}
+		b.WriteByte('$')
+		i++
+	}
+	return b.String(), nil
+}
+
+func isMacroChar(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
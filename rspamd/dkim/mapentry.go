@@ -0,0 +1,146 @@
+package dkim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MapEntry is one line of a map file such as dkim_selectors.map. Annotations
+// holds the key=value pairs of a trailing comment, as in
+//
+//	example.com s2024a # rotate-after=2025-06-01 owner=team-x
+//
+// Words in the comment without an = are ignored.
+type MapEntry struct {
+	Key         string
+	Value       string
+	Line        int
+	Annotations map[string]string
+}
+
+// Annotations understood by PlanRotations.
+const (
+	AnnotationRotateAfter = "rotate-after"
+	AnnotationOwner       = "owner"
+)
+
+// ParseMapEntries parses a map file keeping line numbers and annotations.
+func ParseMapEntries(r io.Reader, opts ...Option) ([]MapEntry, error) {
+	return parseMapEntries(r, newParseOptions(opts))
+}
+
+func parseMapEntries(r io.Reader, opts *parseOptions) ([]MapEntry, error) {
+	var read int64
+	scanner := bufio.NewScanner(newTextReader(newLimitReader(r, &read, opts.limits.InputSize), opts, opts.filename))
+	var out []MapEntry
+	keys := make(map[string]bool)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var comment string
+		if i := strings.Index(line, " #"); i >= 0 {
+			line, comment = strings.TrimSpace(line[:i]), line[i+2:]
+		} else if i := strings.Index(line, "\t#"); i >= 0 {
+			line, comment = strings.TrimSpace(line[:i]), line[i+2:]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, withCode(CodeInvalidMapLine, fmt.Errorf("invalid map line: %q", line))
+		}
+		e := MapEntry{Key: fields[0], Value: fields[1], Line: lineNo}
+		for _, word := range strings.Fields(comment) {
+			if k, v, ok := strings.Cut(word, "="); ok && k != "" {
+				if e.Annotations == nil {
+					e.Annotations = make(map[string]string)
+				}
+				e.Annotations[k] = v
+			}
+		}
+		out = append(out, e)
+		keys[e.Key] = true
+		if err := opts.checkEntries(len(keys)); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MapAnnotations collects the annotations of entries by normalized key, for
+// Maps.Annotations. Later entries add to and override earlier ones, so the
+// annotations of several map files can be passed together.
+func MapAnnotations(entries ...[]MapEntry) map[string]map[string]string {
+	out := make(map[string]map[string]string)
+	for _, list := range entries {
+		for _, e := range list {
+			if len(e.Annotations) == 0 {
+				continue
+			}
+			key := domainKey(e.Key)
+			if out[key] == nil {
+				out[key] = make(map[string]string)
+			}
+			for k, v := range e.Annotations {
+				out[key][k] = v
+			}
+		}
+	}
+	return out
+}
+
+// Rotation is a key due for rotation according to the rotate-after
+// annotation of its domain.
+type Rotation struct {
+	Domain      string
+	Selector    string
+	Owner       string
+	RotateAfter time.Time
+	Overdue     bool
+}
+
+// PlanRotations lists the domains of eff.Maps.Annotations that have a
+// rotate-after date (YYYY-MM-DD), oldest first, with the selector Resolve
+// currently picks. Overdue is set for dates before now.
+func PlanRotations(eff EffectiveSigningConf, now time.Time) ([]Rotation, error) {
+	if eff.Maps == nil {
+		return nil, nil
+	}
+	var out []Rotation
+	for domain, ann := range eff.Maps.Annotations {
+		date, ok := ann[AnnotationRotateAfter]
+		if !ok {
+			continue
+		}
+		after, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, withCode(CodeInvalidAnnotation, fmt.Errorf("domain %q: invalid %s %q", domain, AnnotationRotateAfter, date))
+		}
+		r := Rotation{
+			Domain:      domainKey(domain),
+			Owner:       ann[AnnotationOwner],
+			RotateAfter: after,
+			Overdue:     now.After(after),
+		}
+		if key, ok := eff.Resolve(r.Domain); ok {
+			r.Selector = key.Selector
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].RotateAfter.Equal(out[j].RotateAfter) {
+			return out[i].RotateAfter.Before(out[j].RotateAfter)
+		}
+		return out[i].Domain < out[j].Domain
+	})
+	return out, nil
+}
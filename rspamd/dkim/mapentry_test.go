@@ -0,0 +1,60 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMapEntries(t *testing.T) {
+	input := "# selectors\nexample.com s2024a # rotate-after=2025-06-01 owner=team-x\n\nother.org\ts1\t# legacy\nthird.net s3\n"
+	entries, err := ParseMapEntries(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []MapEntry{
+		{Key: "example.com", Value: "s2024a", Line: 2, Annotations: map[string]string{"rotate-after": "2025-06-01", "owner": "team-x"}},
+		{Key: "other.org", Value: "s1", Line: 4},
+		{Key: "third.net", Value: "s3", Line: 5},
+	}, entries)
+
+	m, err := ParseDKIMSelectorsMap(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"example.com": "s2024a", "other.org": "s1", "third.net": "s3"}, m)
+}
+
+func TestPlanRotations(t *testing.T) {
+	selectors, err := ParseMapEntries(strings.NewReader("a.com s1 # rotate-after=2025-06-01 owner=team-x\nb.com s2 # rotate-after=2024-01-15\nc.com s3\n"))
+	require.NoError(t, err)
+	paths, err := ParseMapEntries(strings.NewReader("@B.com /keys/b.key # owner=team-y\n"))
+	require.NoError(t, err)
+
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{Path: "/keys/$domain.key"},
+		Maps: &Maps{
+			Selectors:   map[string]string{"a.com": "s1", "b.com": "s2", "c.com": "s3"},
+			Annotations: MapAnnotations(selectors, paths),
+		},
+	}
+	plan, err := PlanRotations(eff, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, []Rotation{
+		{Domain: "b.com", Selector: "s2", Owner: "team-y", RotateAfter: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Overdue: true},
+		{Domain: "a.com", Selector: "s1", Owner: "team-x", RotateAfter: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}, plan)
+
+	_, maps := Extract(eff.Conf, eff.Maps, []string{"a.com"})
+	require.Equal(t, []string{"a.com"}, keysOf(maps.Annotations))
+
+	eff.Maps.Annotations["c.com"] = map[string]string{"rotate-after": "soon"}
+	_, err = PlanRotations(eff, time.Now())
+	require.EqualError(t, err, `domain "c.com": invalid rotate-after "soon"`)
+}
+
+func keysOf[V any](m map[string]V) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
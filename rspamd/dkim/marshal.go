@@ -0,0 +1,320 @@
+package dkim
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+)
+
+// Marshal writes v, a struct, a map with string keys or an object
+// *ucl.Value, as rspamd UCL text that Unmarshal reads back.
+//
+// Struct fields are written under their `ucl:"key"` tag, or else under the
+// field name in snake_case, in declaration order; map keys are sorted.
+// Fields tagged `ucl:"-"` are skipped, and `ucl:"key,omitempty"` skips zero
+// values. Nil pointers, slices, maps and interfaces are always skipped, so a
+// *bool field is only written when set. Nested structs and maps become
+// sections, slices become arrays and a time.Duration is written with a
+// unit, such as 10s or 1h. Numbers the lexer cannot read bare, such as -1
+// or 1e+06, are quoted.
+func Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && rv.Type() != valueType {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("marshal: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	e := &encoder{}
+	switch {
+	case rv.Type() == valueType && rv.Interface().(*ucl.Value).Kind == ucl.Object,
+		rv.Kind() == reflect.Struct,
+		rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		if err := e.members(rv, 0); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("marshal: cannot write %s at the top level", rv.Type())
+	}
+	return []byte(e.b.String()), nil
+}
+
+type encoder struct {
+	b strings.Builder
+}
+
+type member struct {
+	key      string
+	val      reflect.Value
+	comments []string
+}
+
+// members writes the keys of an object value at the given depth.
+func (e *encoder) members(rv reflect.Value, depth int) error {
+	list, err := objectMembers(rv)
+	if err != nil {
+		return err
+	}
+	for _, m := range list {
+		for _, c := range m.comments {
+			e.indent(depth)
+			e.b.WriteString(c)
+			e.b.WriteByte('\n')
+		}
+		e.indent(depth)
+		e.b.WriteString(formatBare(m.key))
+		if isObject(m.val) {
+			e.b.WriteString(" {\n")
+			if err := e.members(m.val, depth+1); err != nil {
+				return err
+			}
+			e.indent(depth)
+			e.b.WriteString("}\n")
+			continue
+		}
+		e.b.WriteString(" = ")
+		if err := e.value(m.val); err != nil {
+			return fmt.Errorf("%s: %w", m.key, err)
+		}
+		e.b.WriteString(";\n")
+	}
+	return nil
+}
+
+// objectMembers lists the entries of a struct, map or object *ucl.Value,
+// dropping nil values.
+func objectMembers(rv reflect.Value) ([]member, error) {
+	var out []member
+	switch {
+	case rv.Type() == valueType:
+		val := rv.Interface().(*ucl.Value)
+		for _, key := range val.Keys {
+			out = append(out, member{key: key, val: reflect.ValueOf(val.Fields[key]), comments: val.Fields[key].Comments})
+		}
+	case rv.Kind() == reflect.Map:
+		keys := make([]string, 0, rv.Len())
+		vals := make(map[string]reflect.Value, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			key := iter.Key().String()
+			keys = append(keys, key)
+			vals[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !isNil(vals[key]) {
+				out = append(out, member{key: key, val: vals[key]})
+			}
+		}
+	case rv.Kind() == reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, hasTag := field.Tag.Lookup("ucl")
+			name, opt, _ := strings.Cut(tag, ",")
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct {
+				embedded, err := objectMembers(rv.Field(i))
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, embedded...)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			val := rv.Field(i)
+			if isNil(val) || (opt == "omitempty" && val.IsZero()) {
+				continue
+			}
+			if name == "" {
+				name = snakeCase(field.Name)
+			}
+			out = append(out, member{key: name, val: val})
+		}
+	default:
+		return nil, fmt.Errorf("cannot write %s as a section", rv.Type())
+	}
+	return out, nil
+}
+
+// value writes a scalar or array.
+func (e *encoder) value(rv reflect.Value) error {
+	for rv.Kind() == reflect.Interface || (rv.Kind() == reflect.Pointer && rv.Type() != valueType) {
+		rv = rv.Elem()
+	}
+	if rv.Type() == valueType {
+		return e.uclValue(rv.Interface().(*ucl.Value))
+	}
+	switch {
+	case rv.Type() == durationType:
+		e.b.WriteString(formatBare(formatDuration(time.Duration(rv.Int()))))
+	case rv.Kind() == reflect.String:
+		e.b.WriteString(quoteString(rv.String()))
+	case rv.Kind() == reflect.Bool:
+		e.b.WriteString(strconv.FormatBool(rv.Bool()))
+	case rv.CanInt():
+		e.b.WriteString(formatBare(strconv.FormatInt(rv.Int(), 10)))
+	case rv.CanUint():
+		e.b.WriteString(strconv.FormatUint(rv.Uint(), 10))
+	case rv.CanFloat():
+		e.b.WriteString(formatBare(strconv.FormatFloat(rv.Float(), 'g', -1, 64)))
+	case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+		e.b.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				e.b.WriteString(", ")
+			}
+			elem := rv.Index(i)
+			if isNil(elem) {
+				e.b.WriteString("null")
+				continue
+			}
+			if isObject(elem) {
+				if elem.Kind() == reflect.Interface {
+					elem = elem.Elem()
+				}
+				return fmt.Errorf("cannot write %s inside an array", elem.Type())
+			}
+			if err := e.value(elem); err != nil {
+				return err
+			}
+		}
+		e.b.WriteByte(']')
+	default:
+		return fmt.Errorf("cannot write %s", rv.Type())
+	}
+	return nil
+}
+
+func (e *encoder) uclValue(val *ucl.Value) error {
+	switch val.Kind {
+	case ucl.Object:
+		return fmt.Errorf("cannot write an object inside an array")
+	case ucl.Array:
+		return e.value(reflect.ValueOf(val.Elems))
+	case ucl.String:
+		e.b.WriteString(quoteString(val.Raw))
+	case ucl.Null:
+		e.b.WriteString("null")
+	default:
+		e.b.WriteString(formatBare(val.Raw))
+	}
+	return nil
+}
+
+func (e *encoder) indent(depth int) {
+	e.b.WriteString(strings.Repeat("  ", depth))
+}
+
+// isObject reports whether rv is written as a section.
+func isObject(rv reflect.Value) bool {
+	for rv.Kind() == reflect.Interface || (rv.Kind() == reflect.Pointer && rv.Type() != valueType) {
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Type() == valueType:
+		return rv.Interface().(*ucl.Value).Kind == ucl.Object
+	case rv.Kind() == reflect.Struct:
+		return true
+	case rv.Kind() == reflect.Map:
+		return rv.Type().Key().Kind() == reflect.String
+	}
+	return false
+}
+
+func isNil(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// formatBare writes s bare if the lexer reads it back as one identifier,
+// and quoted otherwise, such as a key with spaces or a number like -1 or
+// 1e+06.
+func formatBare(s string) string {
+	for i, r := range s {
+		if r == '$' || !isIdentPart(r) || (i == 0 && !isIdentStart(r)) {
+			return quoteString(s)
+		}
+	}
+	if s == "" {
+		return `""`
+	}
+	return s
+}
+
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// formatDuration writes d in the largest unit that divides it exactly.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+	for _, u := range []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"min", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+	} {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.suffix
+		}
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// snakeCase converts a Go field name such as UseESLD to use_esld.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package dkim
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+	"github.com/stretchr/testify/require"
+)
+
+type marshalRule struct {
+	Selector string `ucl:"selector"`
+	Path     string `ucl:"path,omitempty"`
+}
+
+type marshalConf struct {
+	Enabled     *bool                  `ucl:"enabled"`
+	SignLocal   *bool                  `ucl:"sign_local"`
+	Selector    string                 `ucl:"selector"`
+	UseESLD     bool                   // written as use_esld
+	MaxSigs     int                    `ucl:"max_sigs,omitempty"`
+	Timeout     time.Duration          `ucl:"timeout"`
+	SignHeaders []string               `ucl:"sign_headers"`
+	Domain      map[string]marshalRule `ucl:"domain"`
+	Note        string                 `ucl:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	yes := true
+	in := marshalConf{
+		Enabled:     &yes,
+		Selector:    "s\"1\n",
+		UseESLD:     true,
+		Timeout:     90 * time.Second,
+		SignHeaders: []string{"from", "to"},
+		Domain: map[string]marshalRule{
+			"b.com": {Selector: "b", Path: "/keys/$domain.key"},
+			"*":     {Selector: "wild"},
+		},
+		Note: "not written",
+	}
+	out, err := Marshal(&in)
+	require.NoError(t, err)
+	require.Equal(t, `enabled = true;
+selector = "s\"1\n";
+use_esld = true;
+timeout = 90s;
+sign_headers = ["from", "to"];
+domain {
+  "*" {
+    selector = "wild";
+  }
+  b.com {
+    selector = "b";
+    path = "/keys/$domain.key";
+  }
+}
+`, string(out))
+
+	var back marshalConf
+	require.NoError(t, Unmarshal(strings.NewReader(string(out)), &back))
+	in.Note = ""
+	require.Equal(t, in, back)
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(string(out)))
+	require.NoError(t, err)
+	require.Equal(t, "wild", conf.Domain["*"].Selector)
+	require.True(t, *conf.UseESLD)
+}
+
+func TestMarshalValue(t *testing.T) {
+	input := "# the selector\nselector = \"s1\";\nlimits { size = 10k; list = [1, \"a\", 2s]; }\n"
+	val, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	out, err := Marshal(val)
+	require.NoError(t, err)
+	require.Equal(t, "# the selector\nselector = \"s1\";\nlimits {\n  size = 10k;\n  list = [1, \"a\", 2s];\n}\n", string(out))
+
+	_, err = Marshal("text")
+	require.EqualError(t, err, "marshal: cannot write string at the top level")
+	_, err = Marshal(map[string]any{"x": []any{map[string]any{}}})
+	require.EqualError(t, err, "x: cannot write map[string]interface {} inside an array")
+}
+
+func TestMarshalNumbersRoundTrip(t *testing.T) {
+	type numbers struct {
+		Int      int           `ucl:"int"`
+		Uint     uint          `ucl:"uint"`
+		Float    float64       `ucl:"float"`
+		Duration time.Duration `ucl:"duration"`
+	}
+	for _, in := range []numbers{
+		{Int: -1, Float: -0.5, Duration: -10 * time.Second},
+		{Int: 42, Uint: 7, Float: 1e6, Duration: 1500 * time.Microsecond},
+		{Float: 1e-7, Duration: -3 * time.Millisecond},
+	} {
+		out, err := Marshal(&in)
+		require.NoError(t, err)
+		var back numbers
+		require.NoError(t, Unmarshal(strings.NewReader(string(out)), &back), string(out))
+		require.Equal(t, in, back)
+	}
+
+	out, err := Marshal(&numbers{Int: -1, Float: 1e6})
+	require.NoError(t, err)
+	require.Equal(t, "int = \"-1\";\nuint = 0;\nfloat = \"1e+06\";\nduration = 0s;\n", string(out))
+
+	val := &ucl.Value{Kind: ucl.Object, Keys: []string{"n"}, Fields: map[string]*ucl.Value{"n": {Kind: ucl.Int, Raw: "-5"}}}
+	out, err = Marshal(val)
+	require.NoError(t, err)
+	back, err := Parse(strings.NewReader(string(out)))
+	require.NoError(t, err)
+	require.Equal(t, "-5", back.Fields["n"].Raw)
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"Selector":         "selector",
+		"UseESLD":          "use_esld",
+		"SignHeaders":      "sign_headers",
+		"HTTPServer":       "http_server",
+		"MaxSigs2":         "max_sigs2",
+		"AllowHdrFromX509": "allow_hdr_from_x509",
+	} {
+		require.Equal(t, want, snakeCase(in), in)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := "selector = \"s1\";\ndomain {\n  example.com {\n    path = \"/keys/$domain.key\";\n  }\n}\n"
+	val, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	data, err := json.Marshal(val)
+	require.NoError(t, err)
+	require.Equal(t, `{"selector":"s1","domain":{"example.com":{"path":"/keys/$domain.key"}}}`, string(data))
+
+	var back ucl.Value
+	require.NoError(t, json.Unmarshal(data, &back))
+	out, err := Marshal(&back)
+	require.NoError(t, err)
+	require.Equal(t, input, string(out))
+}
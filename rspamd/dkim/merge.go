@@ -0,0 +1,317 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// EffectiveSigningConf is everything that decides how one host signs mail:
+// its dkim_signing.conf together with the maps the configuration references.
+type EffectiveSigningConf struct {
+	Conf *DKIMSigningConf
+	Maps *Maps
+
+	// Override, if set, is applied to every key chosen by Resolve. It
+	// stands in for sign_condition logic and is supplied by the caller,
+	// never read from the configuration.
+	Override ResolveHook
+
+	// RootPrefix relocates absolute key paths when key files are read, see
+	// RootedPath. Resolved paths themselves are left unprefixed.
+	RootPrefix string
+
+	// Keys, if set, caches the private keys read by the checks of this
+	// package, such as Plan and CheckCompliance. Plan reads a key per call,
+	// so a cache is worthwhile when it is called for every message.
+	Keys *KeyCache
+
+	// Sandbox, if set, is checked before every key file is read.
+	Sandbox *KeySandbox
+}
+
+// MergeStrategy decides which host wins when MergeHosts finds a conflict.
+type MergeStrategy int
+
+const (
+	// MergePreferA keeps the value from the first host on conflict.
+	MergePreferA MergeStrategy = iota
+	// MergePreferB keeps the value from the second host on conflict.
+	MergePreferB
+	// MergeFail returns ErrMergeConflict if any conflict is found.
+	MergeFail
+)
+
+// ErrMergeConflict is returned by MergeHosts with the MergeFail strategy.
+var ErrMergeConflict = errors.New("conflicting domain configuration")
+
+// MergeConflict describes a domain that both hosts configure differently.
+// Field is the option of a domain rule ("selector", "path", "key",
+// "selectors" or "domain"), or the name of the map ("selector_map",
+// "path_map", "signed_domains_map", "sign_networks_map") the entries came
+// from. For sign_networks_map, Domain holds the network.
+type MergeConflict struct {
+	Domain string
+	Field  string
+	A      string
+	B      string
+}
+
+// MergeHosts unions the domain rules and map entries of two hosts. Global
+// settings are taken from the preferred host (A unless strategy is
+// MergePreferB). Every domain configured differently on both hosts is
+// reported as a conflict instead of being resolved silently.
+func MergeHosts(a, b EffectiveSigningConf, strategy MergeStrategy) (EffectiveSigningConf, []MergeConflict, error) {
+	var conflicts []MergeConflict
+	var out EffectiveSigningConf
+	preferB := strategy == MergePreferB
+
+	if a.Conf != nil || b.Conf != nil {
+		aConf, bConf := a.Conf, b.Conf
+		if aConf == nil {
+			aConf = &DKIMSigningConf{}
+		}
+		if bConf == nil {
+			bConf = &DKIMSigningConf{}
+		}
+		primary, secondary := aConf, bConf
+		if (preferB && b.Conf != nil) || a.Conf == nil {
+			primary, secondary = bConf, aConf
+		}
+
+		bKeys := make(map[string]string, len(bConf.Domain))
+		for key := range bConf.Domain {
+			bKeys[domainKey(key)] = key
+		}
+		for key, ruleA := range aConf.Domain {
+			bKey, ok := bKeys[domainKey(key)]
+			if !ok {
+				continue
+			}
+			ruleB := bConf.Domain[bKey]
+			if ruleA.Selector != ruleB.Selector {
+				conflicts = append(conflicts, MergeConflict{Domain: key, Field: "selector", A: ruleA.Selector, B: ruleB.Selector})
+			}
+			if ruleA.Path != ruleB.Path {
+				conflicts = append(conflicts, MergeConflict{Domain: key, Field: "path", A: ruleA.Path, B: ruleB.Path})
+			}
+			if ruleA.RawKey != ruleB.RawKey {
+				conflicts = append(conflicts, MergeConflict{Domain: key, Field: "key", A: redactedKey(ruleA.RawKey), B: redactedKey(ruleB.RawKey)})
+			}
+			if !slices.Equal(ruleA.Selectors, ruleB.Selectors) {
+				conflicts = append(conflicts, MergeConflict{Domain: key, Field: "selectors", A: formatSelectors(ruleA.Selectors), B: formatSelectors(ruleB.Selectors)})
+			}
+			if ruleA.SigningDomain != ruleB.SigningDomain {
+				conflicts = append(conflicts, MergeConflict{Domain: key, Field: "domain", A: ruleA.SigningDomain, B: ruleB.SigningDomain})
+			}
+		}
+
+		merged := *primary
+		merged.Domain = make(map[string]DomainRule, len(aConf.Domain)+len(bConf.Domain))
+		seen := make(map[string]bool, len(primary.Domain))
+		for key, rule := range primary.Domain {
+			merged.Domain[key] = rule
+			seen[domainKey(key)] = true
+		}
+		for key, rule := range secondary.Domain {
+			if !seen[domainKey(key)] {
+				merged.Domain[key] = rule
+			}
+		}
+		if merged.Sections != nil {
+			sections := make(map[string]*Section, len(merged.Sections))
+			for name, sec := range merged.Sections {
+				sections[name] = sec
+			}
+			sections["domain"] = domainSection(merged.Domain, append(primary.DomainNames(), secondary.DomainNames()...))
+			merged.Sections = sections
+		}
+		out.Conf = &merged
+	}
+
+	if a.Maps != nil || b.Maps != nil {
+		aMaps, bMaps := a.Maps, b.Maps
+		if aMaps == nil {
+			aMaps = &Maps{}
+		}
+		if bMaps == nil {
+			bMaps = &Maps{}
+		}
+		var c []MergeConflict
+		out.Maps = &Maps{}
+		out.Maps.Selectors, c = mergeMap("selector_map", aMaps.Selectors, bMaps.Selectors, preferB)
+		conflicts = append(conflicts, c...)
+		out.Maps.Paths, c = mergeMap("path_map", aMaps.Paths, bMaps.Paths, preferB)
+		conflicts = append(conflicts, c...)
+		out.Maps.SignedDomains, c = mergeMap("signed_domains_map", aMaps.SignedDomains, bMaps.SignedDomains, preferB)
+		conflicts = append(conflicts, c...)
+		out.Maps.SignNetworks, c = mergeNetworks(aMaps.SignNetworks, bMaps.SignNetworks, preferB)
+		conflicts = append(conflicts, c...)
+		if aMaps.Annotations != nil || bMaps.Annotations != nil {
+			primary, secondary := aMaps.Annotations, bMaps.Annotations
+			if preferB {
+				primary, secondary = secondary, primary
+			}
+			out.Maps.Annotations = make(map[string]map[string]string, len(primary)+len(secondary))
+			for key, ann := range secondary {
+				out.Maps.Annotations[domainKey(key)] = ann
+			}
+			for key, ann := range primary {
+				out.Maps.Annotations[domainKey(key)] = ann
+			}
+		}
+	}
+
+	if strategy == MergeFail && len(conflicts) > 0 {
+		return EffectiveSigningConf{}, conflicts, fmt.Errorf("%w: %d conflicts", ErrMergeConflict, len(conflicts))
+	}
+	return out, conflicts, nil
+}
+
+// mergeMap unions two map files, keeping b's entries on conflict if preferB
+// is set.
+func mergeMap(name string, a, b map[string]string, preferB bool) (map[string]string, []MergeConflict) {
+	if a == nil && b == nil {
+		return nil, nil
+	}
+	var conflicts []MergeConflict
+	bKeys := make(map[string]string, len(b))
+	for key := range b {
+		bKeys[domainKey(key)] = key
+	}
+	out := make(map[string]string, len(a)+len(b))
+	for key, val := range a {
+		bKey, ok := bKeys[domainKey(key)]
+		if !ok {
+			out[key] = val
+			continue
+		}
+		delete(bKeys, domainKey(key))
+		if b[bKey] != val {
+			conflicts = append(conflicts, MergeConflict{Domain: key, Field: name, A: val, B: b[bKey]})
+		}
+		if preferB {
+			out[bKey] = b[bKey]
+		} else {
+			out[key] = val
+		}
+	}
+	for _, key := range bKeys {
+		out[key] = b[key]
+	}
+	return out, conflicts
+}
+
+// redactedKey hides inline key material in a MergeConflict.
+func redactedKey(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	return "<redacted>"
+}
+
+// formatSelectors describes a selectors array in a MergeConflict as
+// "selector=path" entries, with inline keys redacted.
+func formatSelectors(sels []DomainSelector) string {
+	parts := make([]string, len(sels))
+	for i, sel := range sels {
+		parts[i] = sel.Selector
+		if sel.RawKey != "" {
+			parts[i] += "=" + redactedKey(sel.RawKey)
+		} else if sel.Path != "" {
+			parts[i] += "=" + sel.Path
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// domainSection rebuilds the raw `domain { ... }` section from typed rules,
+// keeping the rules named in order first and in that order.
+func domainSection(rules map[string]DomainRule, order []string) *Section {
+	sec := newSection()
+	for _, key := range append(order, sortedKeys(rules)...) {
+		rule, ok := rules[key]
+		if !ok || sec.Sections[key] != nil {
+			continue
+		}
+		child := newSection()
+		for _, kv := range [][2]string{{"selector", rule.Selector}, {"path", rule.Path}, {"key", rule.RawKey}, {"domain", rule.SigningDomain}} {
+			if kv[1] != "" {
+				child.Values[kv[0]] = kv[1]
+				child.order = append(child.order, kv[0])
+			}
+		}
+		for _, sel := range rule.Selectors {
+			entry := newSection()
+			for _, kv := range [][2]string{{"selector", sel.Selector}, {"path", sel.Path}, {"key", sel.RawKey}} {
+				if kv[1] != "" {
+					entry.Values[kv[0]] = kv[1]
+					entry.order = append(entry.order, kv[0])
+				}
+			}
+			child.Blocks["selectors"] = append(child.Blocks["selectors"], entry)
+		}
+		if len(rule.Selectors) > 0 {
+			child.order = append(child.order, "selectors")
+		}
+		sec.Sections[key] = child
+		sec.order = append(sec.order, key)
+	}
+	return sec
+}
+
+// DomainNames returns the names of the domain rules in the order they were
+// written in the configuration. Rules added since, for example by a
+// migration, follow in sorted order.
+func (c *DKIMSigningConf) DomainNames() []string {
+	var order []string
+	if sec, ok := c.Sections["domain"]; ok {
+		order = sec.Keys()
+	}
+	names := make([]string, 0, len(c.Domain))
+	seen := make(map[string]bool, len(c.Domain))
+	for _, key := range append(order, sortedKeys(c.Domain)...) {
+		if _, ok := c.Domain[key]; ok && !seen[key] {
+			seen[key] = true
+			names = append(names, key)
+		}
+	}
+	return names
+}
+
+// mergeNetworks unions two networks maps, keeping b's entry for a network
+// on both if preferB is set. Entries keep their order, those of the
+// preferred map first.
+func mergeNetworks(a, b Networks, preferB bool) (Networks, []MergeConflict) {
+	if a == nil && b == nil {
+		return nil, nil
+	}
+	var conflicts []MergeConflict
+	bValues := make(map[netip.Prefix]string, len(b))
+	for _, nw := range b {
+		bValues[nw.Prefix.Masked()] = nw.Value
+	}
+	for _, nw := range a {
+		if val, ok := bValues[nw.Prefix.Masked()]; ok && val != nw.Value {
+			conflicts = append(conflicts, MergeConflict{Domain: nw.Prefix.Masked().String(), Field: "sign_networks_map", A: nw.Value, B: val})
+		}
+	}
+	primary, secondary := a, b
+	if preferB {
+		primary, secondary = b, a
+	}
+	out := make(Networks, 0, len(a)+len(b))
+	seen := make(map[netip.Prefix]bool, len(primary))
+	for _, nw := range primary {
+		out = append(out, nw)
+		seen[nw.Prefix.Masked()] = true
+	}
+	for _, nw := range secondary {
+		if !seen[nw.Prefix.Masked()] {
+			out = append(out, nw)
+		}
+	}
+	return out, conflicts
+}
@@ -0,0 +1,97 @@
+package dkim
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeHosts(t *testing.T) {
+	a := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Selector: "s1",
+			Domain: map[string]DomainRule{
+				"example.com": {Selector: "s1", Path: "/keys/example.com.key"},
+				"a-only.com":  {Selector: "a"},
+			},
+		},
+		Maps: &Maps{Selectors: map[string]string{"shared.com": "s1", "a.com": "a"}},
+	}
+	b := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Selector: "s2",
+			Domain: map[string]DomainRule{
+				"Example.com": {Selector: "s2", Path: "/keys/example.com.key"},
+				"b-only.com":  {Selector: "b"},
+			},
+		},
+		Maps: &Maps{Selectors: map[string]string{"shared.com": "s2", "b.com": "b"}},
+	}
+
+	merged, conflicts, err := MergeHosts(a, b, MergePreferA)
+	require.NoError(t, err)
+	require.Equal(t, "s1", merged.Conf.Selector)
+	require.Len(t, merged.Conf.Domain, 3)
+	require.Equal(t, "s1", merged.Conf.Domain["example.com"].Selector)
+	require.Equal(t, map[string]string{"shared.com": "s1", "a.com": "a", "b.com": "b"}, merged.Maps.Selectors)
+	require.ElementsMatch(t, []MergeConflict{
+		{Domain: "example.com", Field: "selector", A: "s1", B: "s2"},
+		{Domain: "shared.com", Field: "selector_map", A: "s1", B: "s2"},
+	}, conflicts)
+
+	merged, _, err = MergeHosts(a, b, MergePreferB)
+	require.NoError(t, err)
+	require.Equal(t, "s2", merged.Conf.Selector)
+	require.Equal(t, "s2", merged.Conf.Domain["Example.com"].Selector)
+	require.Equal(t, "s2", merged.Maps.Selectors["shared.com"])
+
+	_, conflicts, err = MergeHosts(a, b, MergeFail)
+	require.ErrorIs(t, err, ErrMergeConflict)
+	require.Len(t, conflicts, 2)
+
+	a = EffectiveSigningConf{Conf: &DKIMSigningConf{Domain: map[string]DomainRule{
+		"dual.com": {Selectors: []DomainSelector{{Selector: "rsa", Path: "/keys/rsa.key"}, {Selector: "ed", RawKey: "c2VjcmV0"}}},
+	}}}
+	b = EffectiveSigningConf{Conf: &DKIMSigningConf{Domain: map[string]DomainRule{
+		"dual.com": {Selectors: []DomainSelector{{Selector: "rsa", Path: "/keys/rsa.key"}}},
+	}}}
+	_, conflicts, err = MergeHosts(a, b, MergeFail)
+	require.ErrorIs(t, err, ErrMergeConflict)
+	require.Equal(t, []MergeConflict{{Domain: "dual.com", Field: "selectors", A: "rsa=/keys/rsa.key, ed=<redacted>", B: "rsa=/keys/rsa.key"}}, conflicts)
+
+	_, conflicts, err = MergeHosts(a, a, MergeFail)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+}
+
+func TestMergeHostsSignNetworks(t *testing.T) {
+	nets := func(lines ...string) Networks {
+		out, err := ParseNetworksMap(strings.NewReader(strings.Join(lines, "\n")))
+		require.NoError(t, err)
+		return out
+	}
+	a := EffectiveSigningConf{Maps: &Maps{SignNetworks: nets("10.0.0.0/8 office", "192.0.2.1")}}
+	b := EffectiveSigningConf{Maps: &Maps{SignNetworks: nets("10.0.0.0/8 branch", "2001:db8::/32")}}
+
+	out, conflicts, err := MergeHosts(a, b, MergePreferA)
+	require.NoError(t, err)
+	require.Equal(t, []MergeConflict{{Domain: "10.0.0.0/8", Field: "sign_networks_map", A: "office", B: "branch"}}, conflicts)
+	require.Len(t, out.Maps.SignNetworks, 3)
+	require.Equal(t, "office", out.Maps.SignNetworks[0].Value)
+	require.True(t, out.InSignNetworks(netip.MustParseAddr("2001:db8::1")))
+	require.True(t, out.InSignNetworks(netip.MustParseAddr("192.0.2.1")))
+
+	out, _, err = MergeHosts(a, b, MergePreferB)
+	require.NoError(t, err)
+	require.Equal(t, "branch", out.Maps.SignNetworks[0].Value)
+
+	_, _, err = MergeHosts(a, b, MergeFail)
+	require.ErrorIs(t, err, ErrMergeConflict)
+
+	out, conflicts, err = MergeHosts(a, EffectiveSigningConf{}, MergeFail)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+	require.Equal(t, a.Maps.SignNetworks, out.Maps.SignNetworks)
+}
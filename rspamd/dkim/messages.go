@@ -0,0 +1,75 @@
+package dkim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Locale selects the language of report output. Messages without a
+// translation are written in English.
+type Locale string
+
+const (
+	English Locale = "en"
+	German  Locale = "de"
+)
+
+// catalogs maps a locale to translations of English format strings. The
+// translations take the same arguments in the same order.
+var catalogs = map[Locale]map[string]string{
+	German: {
+		"tenant":     "Mandant",
+		"algorithm":  "Algorithmus",
+		"delegation": "Delegierung",
+		"rotation":   "Rotation",
+
+		"duplicate-domain": "Domain-Duplikat",
+		"modules":          "Module",
+
+		"%s key %s belongs to tenant %s, not %s":                         "%s-Schlüssel %s gehört zu Mandant %s, nicht zu %s",
+		"selector %s rotation due on %s":                                 "Selektor %s muss am %s rotiert werden",
+		"selector %s rotation overdue since %s":                          "Rotation von Selektor %s ist seit %s überfällig",
+		"%s has entries %s that rspamd treats as one domain":             "%s enthält Einträge %s, die rspamd als eine Domain behandelt",
+		"%s has conflicting entries %s that rspamd treats as one domain": "%s enthält widersprüchliche Einträge %s, die rspamd als eine Domain behandelt",
+		"no signing key":      "kein Signaturschlüssel",
+		msgDelegatedRelaxed:   "Mail von %s wird mit d=%s signiert, was nur bei lockerem DMARC-Alignment übereinstimmt",
+		msgDelegatedUnaligned: "Mail von %s wird mit d=%s signiert, was nicht zur From-Domain passt; DMARC besteht nur über SPF",
+		msgModuleDisabled:     "dkim_signing ist aktiviert, aber das dkim-Modul ist deaktiviert, daher wird keine Mail signiert",
+		msgSigningHeaders:     "sign_headers in dkim_signing.conf wird ignoriert; Mail wird mit den sign_headers aus dkim.conf signiert",
+		msgFromNotSigned:      "sign_headers enthält from nicht, das jede DKIM-Signatur abdecken muss",
+		msgWhitelistedSigning: "%s ist ein vertrauenswürdiger Signierer und wird von diesem Host signiert, daher passiert hier signierte Mail das dkim-Modul ungeprüft",
+		msgHdrFromMismatch:    "allow_hdrfrom_mismatch signiert Mail, deren From-Domain von der Envelope-Domain abweicht, was DMARC-Alignment verhindern kann",
+	},
+}
+
+// ParseLocale returns the locale for a language tag such as "de" or
+// "de-AT", or English if the language has no catalog.
+func ParseLocale(tag string) Locale {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(tag, "_", "-")), "-")
+	if _, ok := catalogs[Locale(lang)]; ok {
+		return Locale(lang)
+	}
+	return English
+}
+
+// Locales returns the supported locales in sorted order.
+func Locales() []Locale {
+	out := []Locale{English}
+	for l := range catalogs {
+		out = append(out, l)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Sprintf formats the translation of format, falling back to format itself.
+func (l Locale) Sprintf(format string, args ...any) string {
+	if t, ok := catalogs[l][format]; ok {
+		format = t
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
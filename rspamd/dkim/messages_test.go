@@ -0,0 +1,38 @@
+package dkim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocale(t *testing.T) {
+	require.Equal(t, German, ParseLocale("de"))
+	require.Equal(t, German, ParseLocale("de_AT"))
+	require.Equal(t, German, ParseLocale("DE-de"))
+	require.Equal(t, English, ParseLocale("fr"))
+	require.Equal(t, English, ParseLocale(""))
+	require.Equal(t, []Locale{German, English}, Locales())
+}
+
+func TestLocalizedFinding(t *testing.T) {
+	rot := Rotation{Domain: "a.example", Selector: "s1", RotateAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Overdue: true}
+	require.Equal(t, Finding{Domain: "a.example", Check: "Rotation", Code: CodeRotationOverdue, Message: "Rotation von Selektor s1 ist seit 2024-01-01 überfällig"}, rot.LocalizedFinding(German))
+	require.Equal(t, rot.Finding(), rot.LocalizedFinding(English))
+
+	yes := true
+	warnings := CheckDelegation(EffectiveSigningConf{Conf: &DKIMSigningConf{
+		AllowHdrFromMismatch: &yes,
+		Domain:               map[string]DomainRule{"Shop.Example": {SigningDomain: "esp.net"}},
+	}})
+	require.Len(t, warnings, 2)
+	require.Equal(t, "allow_hdrfrom_mismatch signiert Mail, deren From-Domain von der Envelope-Domain abweicht, was DMARC-Alignment verhindern kann", warnings[0].LocalizedFinding(German).Message)
+	require.Equal(t, "Mail von shop.example wird mit d=esp.net signiert, was nicht zur From-Domain passt; DMARC besteht nur über SPF", warnings[1].LocalizedFinding(German).Message)
+	require.Equal(t, warnings[1].Message, warnings[1].LocalizedFinding(English).Message)
+
+	// Untranslated messages fall back to English.
+	v := AlgorithmViolation{Domain: "b.example", Problem: "no key for ed25519-sha256"}
+	require.Equal(t, Finding{Domain: "b.example", Check: "Algorithmus", Code: CodeAlgorithm, Message: "no key for ed25519-sha256"}, v.LocalizedFinding(German))
+	require.Equal(t, "kein Signaturschlüssel", AlgorithmViolation{Problem: "no signing key"}.LocalizedFinding(German).Message)
+}
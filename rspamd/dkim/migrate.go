@@ -0,0 +1,178 @@
+package dkim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+)
+
+// MigrationSkip is a domain rule MigrateToMaps left in the domain block
+// because the maps cannot express it.
+type MigrationSkip struct {
+	Domain string
+	Reason string
+}
+
+// MigrateToMaps moves domain rules into the selector and path maps and
+// points selector_map and path_map at the given files, which the caller
+// writes with WriteMap. A rule is only moved if every domain keeps signing
+// with the same keys; rules setting a signing domain, an inline key or a
+// selectors array, the "*" rule and rules whose domain would then fall
+// under the "*" rule stay and are reported.
+func MigrateToMaps(eff EffectiveSigningConf, selectorMap, pathMap string) (EffectiveSigningConf, []MigrationSkip) {
+	out, conf, maps := eff.migrationCopy()
+	var skipped []MigrationSkip
+	for _, domain := range sortedKeys(conf.Domain) {
+		rule := conf.Domain[domain]
+		switch {
+		case domain == "*":
+			skipped = append(skipped, MigrationSkip{Domain: domain, Reason: "the * rule has no map equivalent"})
+			continue
+		case rule.SigningDomain != "":
+			skipped = append(skipped, MigrationSkip{Domain: domain, Reason: "maps cannot set the signing domain"})
+			continue
+		case rule.RawKey != "":
+			skipped = append(skipped, MigrationSkip{Domain: domain, Reason: "maps cannot hold an inline key"})
+			continue
+		case len(rule.Selectors) > 0:
+			skipped = append(skipped, MigrationSkip{Domain: domain, Reason: "maps cannot hold a selectors array"})
+			continue
+		}
+
+		want := out.ResolveAll(domain)
+		selectors, paths := maps.Selectors, maps.Paths
+		maps.Selectors = setMapEntry(selectors, domain, rule.Selector)
+		maps.Paths = setMapEntry(paths, domain, rule.Path)
+		delete(conf.Domain, domain)
+		if !sameKeys(want, out.ResolveAll(domain)) {
+			conf.Domain[domain] = rule
+			maps.Selectors, maps.Paths = selectors, paths
+			skipped = append(skipped, MigrationSkip{Domain: domain, Reason: "the * rule would apply instead"})
+		}
+	}
+	if len(maps.Selectors) > 0 {
+		conf.SelectorMap = selectorMap
+	}
+	if len(maps.Paths) > 0 {
+		conf.PathMap = pathMap
+	}
+	out.finishMigration()
+	return out, skipped
+}
+
+// MigrateToDomainRules moves the selector and path map entries into domain
+// rules and drops the selector_map and path_map settings. Entries for a
+// domain that already has a rule fill its empty fields; entries for other
+// domains take the fields the "*" rule gave them.
+func MigrateToDomainRules(eff EffectiveSigningConf) EffectiveSigningConf {
+	out, conf, maps := eff.migrationCopy()
+	domains := make(map[string]bool)
+	for key := range maps.Selectors {
+		domains[domainKey(key)] = true
+	}
+	for key := range maps.Paths {
+		domains[domainKey(key)] = true
+	}
+	for domain := range domains {
+		name, rule := domain, conf.Domain["*"]
+		for key, r := range conf.Domain {
+			if key != "*" && domainKey(key) == domain {
+				name, rule = key, r
+				break
+			}
+		}
+		if rule.Selector == "" {
+			rule.Selector, _ = maps.lookupSelector(domain)
+		}
+		if rule.Path == "" {
+			rule.Path, _ = maps.lookupPath(domain)
+		}
+		conf.Domain[name] = rule
+	}
+	maps.Selectors, maps.Paths = nil, nil
+	conf.SelectorMap, conf.PathMap = "", ""
+	out.finishMigration()
+	return out
+}
+
+// migrationCopy returns a copy of e with its own domain rules and maps,
+// which the migrations modify.
+func (e EffectiveSigningConf) migrationCopy() (EffectiveSigningConf, *DKIMSigningConf, *Maps) {
+	conf := &DKIMSigningConf{}
+	if e.Conf != nil {
+		*conf = *e.Conf
+	}
+	conf.Domain = make(map[string]DomainRule, len(conf.Domain))
+	if e.Conf != nil {
+		for key, rule := range e.Conf.Domain {
+			conf.Domain[key] = rule
+		}
+	}
+	maps := &Maps{}
+	if e.Maps != nil {
+		*maps = *e.Maps
+		maps.Selectors = filterMap(e.Maps.Selectors, func(string) bool { return true })
+		maps.Paths = filterMap(e.Maps.Paths, func(string) bool { return true })
+	}
+	e.Conf, e.Maps = conf, maps
+	return e, conf, maps
+}
+
+// finishMigration rebuilds the raw domain section from the migrated rules.
+func (e EffectiveSigningConf) finishMigration() {
+	if e.Conf.Sections == nil {
+		return
+	}
+	sections := make(map[string]*Section, len(e.Conf.Sections))
+	for name, sec := range e.Conf.Sections {
+		sections[name] = sec
+	}
+	sections["domain"] = domainSection(e.Conf.Domain, e.Conf.DomainNames())
+	e.Conf.Sections = sections
+}
+
+// sameKeys reports whether a and b are the same keys, ignoring their Source.
+func sameKeys(a, b []SigningKey) bool {
+	return slices.EqualFunc(a, b, func(x, y SigningKey) bool {
+		x.Source, y.Source = "", ""
+		return x == y
+	})
+}
+
+// setMapEntry returns a copy of m with the entry for domain set to val, or
+// removed if val is empty. Entries differing only in case are replaced.
+func setMapEntry(m map[string]string, domain, val string) map[string]string {
+	out := make(map[string]string, len(m)+1)
+	norm := domainKey(domain)
+	for key, v := range m {
+		if domainKey(key) != norm {
+			out[key] = v
+		}
+	}
+	if val != "" {
+		out[domain] = val
+	}
+	return out
+}
+
+// WriteMap writes m as a map file with one sorted `key value` line per entry.
+func WriteMap(w io.Writer, m map[string]string) error {
+	bw := bufio.NewWriter(w)
+	for _, key := range sortedKeys(m) {
+		if _, err := fmt.Fprintf(bw, "%s %s\n", key, m[key]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateToMaps(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+selector = "dkim";
+path = "/keys/$domain.$selector.key";
+domain {
+  a.com { selector = "s1"; path = "/keys/a.key"; }
+  B.com { selector = "s2"; }
+  esp.net { selector = "esp"; domain = "esp.example"; }
+  inline.org { selector = "s4"; key = "c2VjcmV0"; }
+  dual.org { selectors [ { selector = "rsa"; }, { selector = "ed"; } ] }
+}
+`))
+	require.NoError(t, err)
+	eff := EffectiveSigningConf{Conf: conf, Maps: &Maps{Selectors: map[string]string{"b.com": "old", "c.com": "s3"}}}
+
+	out, skipped := MigrateToMaps(eff, "/etc/rspamd/maps.d/selectors.map", "/etc/rspamd/maps.d/paths.map")
+	require.Equal(t, []MigrationSkip{
+		{Domain: "dual.org", Reason: "maps cannot hold a selectors array"},
+		{Domain: "esp.net", Reason: "maps cannot set the signing domain"},
+		{Domain: "inline.org", Reason: "maps cannot hold an inline key"},
+	}, skipped)
+	require.Equal(t, map[string]DomainRule{
+		"dual.org":   {Selectors: []DomainSelector{{Selector: "rsa"}, {Selector: "ed"}}},
+		"esp.net":    {Selector: "esp", SigningDomain: "esp.example"},
+		"inline.org": {Selector: "s4", RawKey: "c2VjcmV0"},
+	}, out.Conf.Domain)
+	require.Equal(t, map[string]string{"B.com": "s2", "a.com": "s1", "c.com": "s3"}, out.Maps.Selectors)
+	require.Equal(t, map[string]string{"a.com": "/keys/a.key"}, out.Maps.Paths)
+	require.Equal(t, "/etc/rspamd/maps.d/selectors.map", out.Conf.SelectorMap)
+	require.Equal(t, "/etc/rspamd/maps.d/paths.map", out.Conf.PathMap)
+	require.ElementsMatch(t, []string{"dual.org", "esp.net", "inline.org"}, keysOf(out.Conf.Sections["domain"].Sections))
+	require.Equal(t, "c2VjcmV0", out.Conf.Sections["domain"].Sections["inline.org"].Values["key"])
+
+	// The input is left untouched.
+	require.Len(t, eff.Conf.Domain, 5)
+	require.Equal(t, "old", eff.Maps.Selectors["b.com"])
+
+	var b strings.Builder
+	require.NoError(t, WriteMap(&b, out.Maps.Selectors))
+	require.Equal(t, "B.com s2\na.com s1\nc.com s3\n", b.String())
+
+	for _, domain := range []string{"a.com", "b.com", "c.com", "esp.net", "inline.org", "dual.org"} {
+		require.True(t, sameKeys(eff.ResolveAll(domain), out.ResolveAll(domain)), domain)
+	}
+	require.Len(t, out.ResolveAll("dual.org"), 2)
+}
+
+func TestMigrateToMapsWildcard(t *testing.T) {
+	eff := EffectiveSigningConf{Conf: &DKIMSigningConf{
+		Selector: "dkim",
+		Path:     "/keys/$domain.key",
+		Domain: map[string]DomainRule{
+			"*":     {Selector: "wild"},
+			"a.com": {Selector: "s1"},
+			"b.com": {Path: "/keys/b.key"},
+		},
+	}}
+	out, skipped := MigrateToMaps(eff, "sel.map", "path.map")
+	require.Equal(t, []MigrationSkip{
+		{Domain: "*", Reason: "the * rule has no map equivalent"},
+		{Domain: "a.com", Reason: "the * rule would apply instead"},
+		{Domain: "b.com", Reason: "the * rule would apply instead"},
+	}, skipped)
+	require.Equal(t, eff.Conf.Domain, out.Conf.Domain)
+	require.Empty(t, out.Maps.Selectors)
+	require.Empty(t, out.Conf.SelectorMap)
+}
+
+func TestMigrateToDomainRules(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Selector:    "dkim",
+			Path:        "/keys/$domain.$selector.key",
+			SelectorMap: "/etc/rspamd/maps.d/selectors.map",
+			PathMap:     "/etc/rspamd/maps.d/paths.map",
+			Domain: map[string]DomainRule{
+				"*":     {Path: "/keys/wild.key"},
+				"A.com": {Selector: "rule"},
+			},
+		},
+		Maps: &Maps{
+			Selectors: map[string]string{"a.com": "ignored", "b.com": "s2"},
+			Paths:     map[string]string{"a.com": "/keys/a.key"},
+		},
+	}
+	out := MigrateToDomainRules(eff)
+	require.Equal(t, map[string]DomainRule{
+		"*":     {Path: "/keys/wild.key"},
+		"A.com": {Selector: "rule", Path: "/keys/a.key"},
+		"b.com": {Selector: "s2", Path: "/keys/wild.key"},
+	}, out.Conf.Domain)
+	require.Nil(t, out.Maps.Selectors)
+	require.Empty(t, out.Conf.SelectorMap)
+	require.Empty(t, out.Conf.PathMap)
+
+	for _, domain := range []string{"a.com", "b.com", "c.com"} {
+		want, wantOK := eff.Resolve(domain)
+		got, gotOK := out.Resolve(domain)
+		want.Source, got.Source = "", ""
+		require.Equal(t, wantOK, gotOK, domain)
+		require.Equal(t, want, got, domain)
+	}
+}
@@ -0,0 +1,138 @@
+package dkim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// Network is one line of a networks map such as the one sign_networks can
+// reference. A single address is stored as a prefix of its full length.
+// Value holds the optional columns after the network, joined by a space.
+type Network struct {
+	Prefix netip.Prefix
+	Value  string
+	Line   int
+}
+
+// Networks is a list of networks, as read by ParseNetworksMap.
+type Networks []Network
+
+// ParseNetworksMap parses a map file of IPv4 and IPv6 networks in CIDR
+// notation or as single addresses, one per line. Lines starting with # and
+// text after " #" are comments.
+func ParseNetworksMap(r io.Reader, opts ...Option) (Networks, error) {
+	o := newParseOptions(opts)
+	var read int64
+	scanner := bufio.NewScanner(newTextReader(newLimitReader(r, &read, o.limits.InputSize), o, o.filename))
+	var out Networks
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		prefix, err := parseNetwork(fields[0])
+		if err != nil {
+			return nil, &ParseError{File: o.filename, Line: lineNo, Column: 1, Err: withCode(CodeInvalidMapLine, err)}
+		}
+		out = append(out, Network{Prefix: prefix, Value: strings.Join(fields[1:], " "), Line: lineNo})
+		if err := o.checkEntries(len(out)); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseNetwork parses a CIDR prefix or a single address.
+func parseNetwork(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q", s)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network %q", s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Lookup returns the most specific network containing addr. IPv4 addresses
+// written as IPv4-mapped IPv6 addresses match IPv4 networks.
+func (n Networks) Lookup(addr netip.Addr) (Network, bool) {
+	addr = addr.Unmap()
+	var best Network
+	found := false
+	for _, nw := range n {
+		if nw.Prefix.Contains(addr) && (!found || nw.Prefix.Bits() > best.Prefix.Bits()) {
+			best, found = nw, true
+		}
+	}
+	return best, found
+}
+
+// Contains reports whether addr is in any of the networks.
+func (n Networks) Contains(addr netip.Addr) bool {
+	_, ok := n.Lookup(addr)
+	return ok
+}
+
+// signNetworks sets SignNetworkList and SignNetworksMap of conf from the
+// sign_networks option of root: a list of networks, a single network or a
+// map file reference.
+func (d *document) signNetworks(conf *DKIMSigningConf, root *Section) error {
+	list, ok := root.Arrays["sign_networks"]
+	if !ok {
+		if conf.SignNetworks == "" {
+			return nil
+		}
+		if prefix, err := parseNetwork(conf.SignNetworks); err == nil {
+			conf.SignNetworkList = []netip.Prefix{prefix}
+		} else {
+			conf.SignNetworksMap = conf.SignNetworks
+		}
+		return nil
+	}
+	for _, v := range list {
+		prefix, err := parseNetwork(v)
+		if err != nil {
+			if err := d.report(root.errorAt("sign_networks", withCode(CodeInvalidValue, fmt.Errorf("parse sign_networks: %w", err)))); err != nil {
+				return err
+			}
+			continue
+		}
+		conf.SignNetworkList = append(conf.SignNetworkList, prefix)
+	}
+	return nil
+}
+
+// InSignNetworks reports whether mail from addr comes from sign_networks,
+// given inline or through the map file loaded into Maps.SignNetworks.
+func (e EffectiveSigningConf) InSignNetworks(addr netip.Addr) bool {
+	if e.Maps != nil && e.Maps.SignNetworks.Contains(addr) {
+		return true
+	}
+	if e.Conf == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range e.Conf.SignNetworkList {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
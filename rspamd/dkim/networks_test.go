@@ -0,0 +1,80 @@
+package dkim
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNetworksMap(t *testing.T) {
+	input := "# relays\n10.0.0.0/8 internal\n10.1.2.3/16  branch office # comment\n192.168.1.5\n\n2001:db8::/32\n::1 # loopback\n"
+	networks, err := ParseNetworksMap(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, Networks{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "internal", Line: 2},
+		{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Value: "branch office", Line: 3},
+		{Prefix: netip.MustParsePrefix("192.168.1.5/32"), Line: 4},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Line: 6},
+		{Prefix: netip.MustParsePrefix("::1/128"), Line: 7},
+	}, networks)
+
+	nw, ok := networks.Lookup(netip.MustParseAddr("10.1.9.9"))
+	require.True(t, ok)
+	require.Equal(t, "branch office", nw.Value)
+	nw, ok = networks.Lookup(netip.MustParseAddr("10.2.0.1"))
+	require.True(t, ok)
+	require.Equal(t, "internal", nw.Value)
+	require.True(t, networks.Contains(netip.MustParseAddr("::ffff:192.168.1.5")))
+	require.True(t, networks.Contains(netip.MustParseAddr("2001:db8:1::25")))
+	require.False(t, networks.Contains(netip.MustParseAddr("192.168.1.6")))
+
+	_, err = ParseNetworksMap(strings.NewReader("10.0.0.0/8\nmail.example.com\n"), WithFilename("networks.map"))
+	pe := requireParseError(t, err, "networks.map", 2, 1)
+	require.EqualError(t, pe.Err, `invalid network "mail.example.com"`)
+	require.Equal(t, CodeInvalidMapLine, CodeOf(err))
+}
+
+func TestInSignNetworks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dkim_signing.conf":        {Data: []byte(`sign_networks = "maps.d/sign_networks.map";`)},
+		"maps.d/sign_networks.map": {Data: []byte("10.0.0.0/8\n2001:db8::/32\n")},
+	}
+	eff, err := LoadDKIMSigningConf(fsys, "dkim_signing.conf")
+	require.NoError(t, err)
+	require.Len(t, eff.Maps.SignNetworks, 2)
+	require.True(t, eff.InSignNetworks(netip.MustParseAddr("10.3.2.1")))
+	require.True(t, eff.InSignNetworks(netip.MustParseAddr("2001:db8::1")))
+	require.False(t, eff.InSignNetworks(netip.MustParseAddr("192.0.2.1")))
+
+	require.Equal(t, "maps.d/sign_networks.map", eff.Conf.SignNetworksMap)
+	require.Empty(t, eff.Conf.SignNetworkList)
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`sign_networks = ["10.0.0.0/8", "192.0.2.9/24", "::1"];`))
+	require.NoError(t, err)
+	require.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("::1/128"),
+	}, conf.SignNetworkList)
+	require.True(t, EffectiveSigningConf{Conf: conf}.InSignNetworks(netip.MustParseAddr("::ffff:10.1.1.1")))
+
+	_, err = ParseDKIMSigningConf(strings.NewReader("sign_networks = [\"10.0.0.0/8\", \"10.0.0.300\"];"))
+	require.EqualError(t, err, `line 1, column 1: [DKIMCONF0004] parse sign_networks: invalid network "10.0.0.300"`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(`sign_networks = ["192.0.2.0/24", "::1"];`))
+	require.NoError(t, err)
+	eff = EffectiveSigningConf{Conf: conf}
+	require.True(t, eff.InSignNetworks(netip.MustParseAddr("192.0.2.7")))
+	require.True(t, eff.InSignNetworks(netip.MustParseAddr("::1")))
+	require.False(t, eff.InSignNetworks(netip.MustParseAddr("10.0.0.1")))
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(`sign_networks = "192.0.2.0/24";`))
+	require.NoError(t, err)
+	require.Empty(t, conf.SignNetworksMap)
+	require.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, conf.SignNetworkList)
+	require.True(t, EffectiveSigningConf{Conf: conf}.InSignNetworks(netip.MustParseAddr("192.0.2.7")))
+}
@@ -0,0 +1,153 @@
+package dkim
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DuplicateDomain is a set of domain rule or map keys that name the same
+// domain, differing only in case, a trailing dot, a leading "@" or in being
+// written in Unicode or ASCII. rspamd looks domains up in one form, so only
+// the key already written that way is ever used.
+// Source is "domain" for domain rules or the map option name. Conflict is
+// set if the entries disagree on a value.
+type DuplicateDomain struct {
+	Domain   string
+	Source   string
+	Keys     []string
+	Conflict bool
+}
+
+// FindDuplicateDomains reports domain rules and selector, path and signed
+// domains map entries whose keys name the same domain, see DuplicateDomain.
+// Keys that are not canonical but have no duplicate are not reported.
+func FindDuplicateDomains(eff EffectiveSigningConf) []DuplicateDomain {
+	var out []DuplicateDomain
+	if eff.Conf != nil {
+		for _, group := range groupDomainKeys(eff.Conf.Domain) {
+			d := DuplicateDomain{Domain: domainKey(group[0]), Source: "domain", Keys: group}
+			for i, a := range group {
+				for _, b := range group[i+1:] {
+					d.Conflict = d.Conflict || rulesConflict(eff.Conf.Domain[a], eff.Conf.Domain[b])
+				}
+			}
+			out = append(out, d)
+		}
+	}
+	if eff.Maps != nil {
+		for _, m := range []struct {
+			name string
+			m    map[string]string
+		}{
+			{"selector_map", eff.Maps.Selectors},
+			{"path_map", eff.Maps.Paths},
+			{"signed_domains_map", eff.Maps.SignedDomains},
+		} {
+			for _, group := range groupDomainKeys(m.m) {
+				d := DuplicateDomain{Domain: domainKey(group[0]), Source: m.name, Keys: group}
+				for _, key := range group[1:] {
+					d.Conflict = d.Conflict || m.m[key] != m.m[group[0]]
+				}
+				out = append(out, d)
+			}
+		}
+	}
+	return out
+}
+
+// NormalizeDomains rewrites domain rule and map keys the way rspamd looks
+// them up and merges the duplicates FindDuplicateDomains reports, which it
+// also returns. Merged rules take each field from the canonical key if it
+// sets it, else from the other keys in sorted order; conflicting map entries
+// keep the canonical key's value, else the first in sorted order.
+func NormalizeDomains(eff EffectiveSigningConf) (EffectiveSigningConf, []DuplicateDomain) {
+	dups := FindDuplicateDomains(eff)
+	out, conf, maps := eff.migrationCopy()
+
+	rules := make(map[string]DomainRule, len(conf.Domain))
+	for _, key := range preferCanonical(sortedKeys(conf.Domain)) {
+		name := domainKey(key)
+		rule, r := rules[name], conf.Domain[key]
+		if rule.Selector == "" {
+			rule.Selector = r.Selector
+		}
+		if rule.Path == "" {
+			rule.Path = r.Path
+		}
+		if rule.RawKey == "" {
+			rule.RawKey = r.RawKey
+		}
+		if rule.SigningDomain == "" {
+			rule.SigningDomain = r.SigningDomain
+		}
+		if rule.Selectors == nil {
+			rule.Selectors = r.Selectors
+		}
+		rules[name] = rule
+	}
+	conf.Domain = rules
+
+	normalizeMap := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		norm := make(map[string]string, len(m))
+		for _, key := range preferCanonical(sortedKeys(m)) {
+			if _, ok := norm[domainKey(key)]; !ok {
+				norm[domainKey(key)] = m[key]
+			}
+		}
+		return norm
+	}
+	maps.Selectors = normalizeMap(maps.Selectors)
+	maps.Paths = normalizeMap(maps.Paths)
+	maps.SignedDomains = normalizeMap(maps.SignedDomains)
+	out.finishMigration()
+	return out, dups
+}
+
+// groupDomainKeys returns the sorted keys of m that share a canonical form
+// with another key, grouped by that form.
+func groupDomainKeys[V any](m map[string]V) [][]string {
+	groups := make(map[string][]string)
+	for _, key := range sortedKeys(m) {
+		name := domainKey(key)
+		groups[name] = append(groups[name], key)
+	}
+	var out [][]string
+	for _, name := range sortedKeys(groups) {
+		if len(groups[name]) > 1 {
+			out = append(out, groups[name])
+		}
+	}
+	return out
+}
+
+// preferCanonical moves keys already in canonical form to the front, keeping
+// the order otherwise.
+func preferCanonical(keys []string) []string {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i] == domainKey(keys[i]) && keys[j] != domainKey(keys[j])
+	})
+	return keys
+}
+
+// Finding converts d for GroupByOwner.
+func (d DuplicateDomain) Finding() Finding { return d.LocalizedFinding(English) }
+
+// LocalizedFinding is like Finding with the check and message in locale l.
+func (d DuplicateDomain) LocalizedFinding(l Locale) Finding {
+	msg := "%s has entries %s that rspamd treats as one domain"
+	if d.Conflict {
+		msg = "%s has conflicting entries %s that rspamd treats as one domain"
+	}
+	return Finding{Domain: d.Domain, Check: l.Sprintf("duplicate-domain"), Code: CodeDuplicateDomain,
+		Message: l.Sprintf(msg, d.Source, strings.Join(d.Keys, ", "))}
+}
+
+func rulesConflict(a, b DomainRule) bool {
+	differ := func(x, y string) bool { return x != "" && y != "" && x != y }
+	return differ(a.Selector, b.Selector) || differ(a.Path, b.Path) || differ(a.RawKey, b.RawKey) || differ(a.SigningDomain, b.SigningDomain) ||
+		a.Selectors != nil && b.Selectors != nil && !slices.Equal(a.Selectors, b.Selectors)
+}
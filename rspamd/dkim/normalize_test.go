@@ -0,0 +1,72 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDomains(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{Domain: map[string]DomainRule{
+			"example.com":  {Selector: "s1"},
+			"Example.COM.": {Selector: "s2", Path: "/keys/example.key"},
+			"EXAMPLE.com":  {Path: "/keys/example.key"},
+			"Other.org":    {Selector: "o"},
+		}},
+		Maps: &Maps{
+			Selectors: map[string]string{"a.com": "s1", "A.com.": "s1", "b.com": "s2"},
+			Paths:     map[string]string{"C.com": "/keys/c2.key", "c.com.": "/keys/c1.key"},
+		},
+	}
+
+	dups := FindDuplicateDomains(eff)
+	require.Equal(t, []DuplicateDomain{
+		{Domain: "example.com", Source: "domain", Keys: []string{"EXAMPLE.com", "Example.COM.", "example.com"}, Conflict: true},
+		{Domain: "a.com", Source: "selector_map", Keys: []string{"A.com.", "a.com"}},
+		{Domain: "c.com", Source: "path_map", Keys: []string{"C.com", "c.com."}, Conflict: true},
+	}, dups)
+	require.Equal(t, Finding{Domain: "example.com", Check: "duplicate-domain", Code: CodeDuplicateDomain,
+		Message: "domain has conflicting entries EXAMPLE.com, Example.COM., example.com that rspamd treats as one domain"}, dups[0].Finding())
+
+	out, got := NormalizeDomains(eff)
+	require.Equal(t, dups, got)
+	require.Equal(t, map[string]DomainRule{
+		"example.com": {Selector: "s1", Path: "/keys/example.key"},
+		"other.org":   {Selector: "o"},
+	}, out.Conf.Domain)
+	require.Equal(t, map[string]string{"a.com": "s1", "b.com": "s2"}, out.Maps.Selectors)
+	require.Equal(t, map[string]string{"c.com": "/keys/c2.key"}, out.Maps.Paths)
+	require.Nil(t, out.Maps.SignedDomains)
+	require.Len(t, eff.Conf.Domain, 4)
+
+	require.Empty(t, FindDuplicateDomains(out))
+}
+
+func TestNormalizeDomainsSelectors(t *testing.T) {
+	conf, err := ParseDKIMSigningConf(strings.NewReader(`
+path = "/k/$selector.key";
+domain {
+  Example.com {
+    selectors [ { selector = "rsa"; }, { selector = "ed25519"; path = "/k/ed.key"; } ]
+  }
+  example.com. { selector = "s1"; }
+  other.org {
+    selectors [ { selector = "a"; }, { selector = "b"; } ]
+  }
+}
+`))
+	require.NoError(t, err)
+	eff := EffectiveSigningConf{Conf: conf}
+	dups := FindDuplicateDomains(eff)
+	require.Equal(t, []DuplicateDomain{{Domain: "example.com", Source: "domain", Keys: []string{"Example.com", "example.com."}}}, dups)
+
+	out, _ := NormalizeDomains(eff)
+	require.Equal(t, []DomainSelector{{Selector: "rsa"}, {Selector: "ed25519", Path: "/k/ed.key"}}, out.Conf.Domain["example.com"].Selectors)
+	require.Equal(t, eff.ResolveAll("other.org"), out.ResolveAll("other.org"))
+	require.Len(t, out.ResolveAll("example.com"), 2)
+
+	eff.Conf.Domain["example.com."] = DomainRule{Selectors: []DomainSelector{{Selector: "x"}}}
+	require.True(t, FindDuplicateDomains(eff)[0].Conflict)
+}
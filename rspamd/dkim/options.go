@@ -0,0 +1,248 @@
+package dkim
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Option configures how configuration files are parsed.
+type Option func(*parseOptions)
+
+type parseOptions struct {
+	rawEscapes  bool
+	recovery    bool
+	maxErrors   int
+	strict      bool
+	foldKeys    bool
+	invalidUTF8 InvalidUTF8Policy
+	limits      Limits
+
+	duplicateKeys DuplicateKeyPolicy
+	onWarning     func(Warning)
+	logger        *slog.Logger
+	trace         io.Writer
+
+	snippets       bool
+	snippetContext int
+	filename       string
+	includeDir     string
+	rootPrefix     string
+	macros         map[string]string
+
+	open func(name string) (io.ReadCloser, error)
+	glob func(pattern string) ([]string, error)
+}
+
+func newParseOptions(opts []Option) *parseOptions {
+	o := &parseOptions{
+		macros: DefaultMacros(),
+		open:   hostOpen,
+		glob:   hostGlob,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithRawEscapes keeps backslash escapes in double-quoted strings exactly as
+// written instead of decoding them, so values can be written back unchanged.
+func WithRawEscapes() Option {
+	return func(o *parseOptions) {
+		o.rawEscapes = true
+	}
+}
+
+// WithRecovery keeps parsing after a syntax error by skipping to the next
+// entry. The parse functions then return the partial result together with a
+// ParseErrors listing every problem found.
+func WithRecovery() Option {
+	return func(o *parseOptions) {
+		o.recovery = true
+	}
+}
+
+// WithMaxErrors enables recovery mode, as WithRecovery, but stops parsing
+// once n errors have been found. A limit of zero or less means no limit.
+func WithMaxErrors(n int) Option {
+	return func(o *parseOptions) {
+		o.recovery = true
+		o.maxErrors = n
+	}
+}
+
+// WithStrict rejects keys that rspamd does not know for the file being
+// parsed, such as a misspelt `selctor`, at the top level and in domain rules.
+func WithStrict() Option {
+	return func(o *parseOptions) {
+		o.strict = true
+	}
+}
+
+// WithLenient ignores unknown keys, reporting each as a Warning. This is
+// the default and undoes an earlier WithStrict.
+func WithLenient() Option {
+	return func(o *parseOptions) {
+		o.strict = false
+	}
+}
+
+// WithCaseInsensitiveKeys matches module options and domain rule keys
+// ignoring case, so that `Selector = "s1"` is read as `selector`. Each key
+// read this way is reported as a Warning. Without it such keys are unknown
+// and ignored, or rejected with WithStrict.
+func WithCaseInsensitiveKeys() Option {
+	return func(o *parseOptions) {
+		o.foldKeys = true
+	}
+}
+
+// DuplicateKeyPolicy decides what happens when a key is repeated in the same
+// block at the same include priority.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeepLast keeps the last value, as rspamd does.
+	DuplicateKeepLast DuplicateKeyPolicy = iota
+	// DuplicateKeepFirst keeps the first value.
+	DuplicateKeepFirst
+	// DuplicateFail makes a repeated key a parse error.
+	DuplicateFail
+	// DuplicateCollect gathers all values of the key into an array.
+	DuplicateCollect
+)
+
+// WithDuplicateKeys sets the policy for repeated keys. Except with
+// DuplicateFail, every repeat is reported as a Warning. The duplicate
+// parameter of an .include directive takes precedence for its file.
+func WithDuplicateKeys(policy DuplicateKeyPolicy) Option {
+	return func(o *parseOptions) {
+		o.duplicateKeys = policy
+	}
+}
+
+// WithWarningHandler calls fn for every Warning as it is found. Warnings
+// are also returned in the Warnings field of the parsed config.
+func WithWarningHandler(fn func(Warning)) Option {
+	return func(o *parseOptions) {
+		o.onWarning = fn
+	}
+}
+
+// WithLogger logs every Warning to logger at the Warn level, with the
+// file, line, column and code as attributes, so operators see what the
+// parser ignored. It can be combined with WithWarningHandler.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *parseOptions) {
+		o.logger = logger
+	}
+}
+
+// emit passes w to the warning handler and logger, if set.
+func (o *parseOptions) emit(w Warning) {
+	if o.onWarning != nil {
+		o.onWarning(w)
+	}
+	if o.logger != nil {
+		o.logger.LogAttrs(context.Background(), slog.LevelWarn, w.Message,
+			slog.String("file", w.File),
+			slog.Int("line", w.Line),
+			slog.Int("column", w.Column),
+			slog.String("code", string(w.Code)),
+		)
+	}
+}
+
+// WithFilename sets the name reported in ParseError for the top-level input.
+func WithFilename(name string) Option {
+	return func(o *parseOptions) {
+		o.filename = name
+	}
+}
+
+// WithIncludeDir resolves relative .include paths in the parsed input against
+// dir rather than the working directory. Includes inside included files are
+// always resolved against the directory of the including file.
+func WithIncludeDir(dir string) Option {
+	return func(o *parseOptions) {
+		o.includeDir = dir
+	}
+}
+
+// WithMacros sets macros expanded in values and include paths, such as
+// $LOCAL_CONFDIR. The given entries are added to DefaultMacros, replacing
+// builtins of the same name.
+func WithMacros(macros map[string]string) Option {
+	return func(o *parseOptions) {
+		for name, val := range macros {
+			o.macros[name] = val
+		}
+	}
+}
+
+// WithRootPrefix opens absolute include paths below dir, for parsing a
+// configuration from a chroot, mounted image or extracted backup as if it
+// were installed at /. Paths reported in the result are left unprefixed.
+func WithRootPrefix(dir string) Option {
+	return func(o *parseOptions) {
+		o.rootPrefix = dir
+	}
+}
+
+// RootedPath returns path relocated below root if path is absolute and root
+// is not empty. Relative paths are returned unchanged.
+func RootedPath(root, path string) string {
+	if root == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// unrootedPath is the inverse of RootedPath.
+func unrootedPath(root, path string) string {
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return string(filepath.Separator) + rel
+}
+
+// WithFS reads included files from fsys, such as an archive returned by
+// OpenArchive, instead of the host filesystem. Absolute include paths are
+// looked up relative to the root of fsys.
+func WithFS(fsys fs.FS) Option {
+	return func(o *parseOptions) {
+		o.open = func(name string) (io.ReadCloser, error) {
+			return fsys.Open(fsPath(name))
+		}
+		o.glob = func(pattern string) ([]string, error) {
+			matches, err := fs.Glob(fsys, fsPath(pattern))
+			if err != nil {
+				return nil, err
+			}
+			if filepath.IsAbs(pattern) {
+				for i, m := range matches {
+					matches[i] = "/" + m
+				}
+			}
+			return matches, nil
+		}
+	}
+}
+
+// fsPath converts a host path into an fs.FS path.
+func fsPath(name string) string {
+	p := strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
@@ -0,0 +1,205 @@
+package dkim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Owners maps domains to the teams or addresses responsible for them. It is
+// read from a CODEOWNERS-style file of `pattern owner...` lines, where a
+// pattern is a domain, `*.domain` for its subdomains or `*` for everything,
+// and the last matching line wins:
+//
+//	example.com       @mail-team
+//	*.shop.example    shop-team@example.com
+type Owners struct {
+	rules []ownerRule
+}
+
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+// ParseOwners reads an owners file. Blank lines and lines starting with #
+// are ignored.
+func ParseOwners(r io.Reader) (*Owners, error) {
+	o := &Owners{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid owners line: %q", line)
+		}
+		o.rules = append(o.rules, ownerRule{pattern: domainKey(fields[0]), owners: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Lookup returns the owners of domain, or nil if no line matches.
+func (o *Owners) Lookup(domain string) []string {
+	if o == nil {
+		return nil
+	}
+	domain = domainKey(domain)
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		r := o.rules[i]
+		switch {
+		case r.pattern == "*",
+			r.pattern == domain,
+			strings.HasPrefix(r.pattern, "*.") && strings.HasSuffix(domain, r.pattern[1:]):
+			return r.owners
+		}
+	}
+	return nil
+}
+
+// Finding is a single problem reported by one of the checks, in a form that
+// can be routed to the domain's owners.
+type Finding struct {
+	Domain  string
+	Check   string
+	Code    Code
+	Message string
+}
+
+// Finding converts v for GroupByOwner.
+func (v TenantViolation) Finding() Finding { return v.LocalizedFinding(English) }
+
+// LocalizedFinding is like Finding with the check and message in locale l.
+func (v TenantViolation) LocalizedFinding(l Locale) Finding {
+	return Finding{Domain: v.Domain, Check: l.Sprintf("tenant"), Code: CodeTenantKey, Message: l.Sprintf("%s key %s belongs to tenant %s, not %s", v.Source, v.Path, v.PathTenant, v.Tenant)}
+}
+
+// Finding converts v for GroupByOwner.
+func (v AlgorithmViolation) Finding() Finding { return v.LocalizedFinding(English) }
+
+// LocalizedFinding is like Finding with the check and message in locale l.
+// Problems that carry error text are left untranslated.
+func (v AlgorithmViolation) LocalizedFinding(l Locale) Finding {
+	return Finding{Domain: v.Domain, Check: l.Sprintf("algorithm"), Code: CodeAlgorithm, Message: l.Sprintf(v.Problem)}
+}
+
+// Finding converts w for GroupByOwner.
+func (w DelegationWarning) Finding() Finding { return w.LocalizedFinding(English) }
+
+// LocalizedFinding is like Finding with the check and message in locale l.
+// Warnings about use_domain options are left untranslated.
+func (w DelegationWarning) LocalizedFinding(l Locale) Finding {
+	msg := l.Sprintf(w.Message)
+	if w.Option == "domain" {
+		msg = l.Sprintf(w.messageFormat(), domainKey(w.Domain), w.SigningDomain)
+	}
+	return Finding{Domain: w.Domain, Check: l.Sprintf("delegation"), Code: CodeDelegation, Message: msg}
+}
+
+// Finding converts r for GroupByOwner.
+func (r Rotation) Finding() Finding { return r.LocalizedFinding(English) }
+
+// LocalizedFinding is like Finding with the check and message in locale l.
+func (r Rotation) LocalizedFinding(l Locale) Finding {
+	msg, code := "selector %s rotation due on %s", CodeRotationDue
+	if r.Overdue {
+		msg, code = "selector %s rotation overdue since %s", CodeRotationOverdue
+	}
+	return Finding{Domain: r.Domain, Check: l.Sprintf("rotation"), Code: code, Message: l.Sprintf(msg, r.Selector, r.RotateAfter.Format(time.DateOnly))}
+}
+
+// Unowned is the GroupByOwner key for findings without an owner, including
+// those not tied to a domain.
+const Unowned = "unowned"
+
+// GroupByOwner groups findings by owner. A finding whose domain has several
+// owners is listed under each of them. Findings keep their order.
+func GroupByOwner(findings []Finding, owners *Owners) map[string][]Finding {
+	out := make(map[string][]Finding)
+	for _, f := range findings {
+		names := []string{Unowned}
+		if f.Domain != "" {
+			if o := owners.Lookup(f.Domain); len(o) > 0 {
+				names = o
+			}
+		}
+		for _, name := range names {
+			out[name] = append(out[name], f)
+		}
+	}
+	return out
+}
+
+// WriteOwnerReports writes one file per owner into dir, named after the
+// owner with characters unsafe in file names replaced, with a line per
+// finding: domain, check and message separated by tabs. It returns the
+// paths written in sorted order.
+func WriteOwnerReports(dir string, groups map[string][]Finding) ([]string, error) {
+	if err := checkWritable("write owner reports"); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, c := range ownerReports(groups) {
+		path := filepath.Join(dir, c.Name)
+		if err := os.WriteFile(path, c.Data, c.Perm); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// WriteOwnerReportsTo is like WriteOwnerReports but writes the reports to
+// sink. It returns the names written.
+func WriteOwnerReportsTo(sink Sink, groups map[string][]Finding) ([]string, error) {
+	changes := ownerReports(groups)
+	n, err := Apply(sink, changes)
+	names := make([]string, n)
+	for i := range names {
+		names[i] = changes[i].Name
+	}
+	return names, err
+}
+
+func ownerReports(groups map[string][]Finding) []FileChange {
+	owners := make([]string, 0, len(groups))
+	for owner := range groups {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	changes := make([]FileChange, 0, len(owners))
+	for _, owner := range owners {
+		var b strings.Builder
+		for _, f := range groups[owner] {
+			fmt.Fprintf(&b, "%s\t%s\t%s\n", f.Domain, f.Check, f.Message)
+		}
+		changes = append(changes, FileChange{Name: ownerFileName(owner) + ".txt", Data: []byte(b.String()), Perm: 0o644})
+	}
+	return changes
+}
+
+func ownerFileName(owner string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimPrefix(owner, "@"))
+	if name == "" || strings.Trim(name, ".") == "" {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,60 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwners(t *testing.T) {
+	owners, err := ParseOwners(strings.NewReader(`# domain owners
+*                  @mail-team
+*.shop.example     shop-team@example.com
+billing.example    @billing @mail-team
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"@mail-team"}, owners.Lookup("other.org"))
+	require.Equal(t, []string{"shop-team@example.com"}, owners.Lookup("eu.shop.example."))
+	require.Equal(t, []string{"@mail-team"}, owners.Lookup("shop.example"))
+	require.Equal(t, []string{"@billing", "@mail-team"}, owners.Lookup("Billing.example"))
+	require.Nil(t, (*Owners)(nil).Lookup("a.com"))
+
+	_, err = ParseOwners(strings.NewReader("lonely.example\n"))
+	require.Error(t, err)
+}
+
+func TestGroupByOwnerAndWriteReports(t *testing.T) {
+	owners, err := ParseOwners(strings.NewReader("billing.example @billing @mail-team\n*.shop.example shop-team@example.com\n"))
+	require.NoError(t, err)
+
+	findings := []Finding{
+		AlgorithmViolation{Domain: "billing.example", Problem: "no key for ed25519-sha256"}.Finding(),
+		Rotation{Domain: "eu.shop.example", Selector: "s1", RotateAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Overdue: true}.Finding(),
+		DelegationWarning{Option: "use_domain", Message: "use_domain = \"envelope\" signs with the envelope domain"}.Finding(),
+		{Domain: "nobody.example", Check: "custom", Message: "x"},
+	}
+	groups := GroupByOwner(findings, owners)
+	require.Equal(t, map[string][]Finding{
+		"@billing":              {findings[0]},
+		"@mail-team":            {findings[0]},
+		"shop-team@example.com": {findings[1]},
+		Unowned:                 {findings[2], findings[3]},
+	}, groups)
+
+	dir := t.TempDir()
+	paths, err := WriteOwnerReports(dir, groups)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "billing.txt"),
+		filepath.Join(dir, "mail-team.txt"),
+		filepath.Join(dir, "shop-team@example.com.txt"),
+		filepath.Join(dir, "unowned.txt"),
+	}, paths)
+	data, err := os.ReadFile(paths[2])
+	require.NoError(t, err)
+	require.Equal(t, "eu.shop.example\trotation\tselector s1 rotation overdue since 2024-01-01\n", string(data))
+}
@@ -0,0 +1,107 @@
+package dkim
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+)
+
+// Parse reads any rspamd configuration file into a generic ucl.Value tree,
+// processing includes and macros as ParseDKIMSigningConf does. It gives
+// access to options the typed structs do not model. In recovery mode the
+// partial tree is returned together with ParseErrors.
+func Parse(r io.Reader, opts ...Option) (*ucl.Value, error) {
+	o := newParseOptions(opts)
+	doc, err := parseRspamdConfig(r, o)
+	if err != nil {
+		return nil, err
+	}
+	return doc.root.object(position{file: o.filename, line: 1, col: 1}), doc.err()
+}
+
+// object converts s into a ucl.Value of Kind Object with the keys in the
+// order of Keys.
+func (s *Section) object(pos position) *ucl.Value {
+	v := &ucl.Value{Kind: ucl.Object, Pos: pos.ucl(), Fields: make(map[string]*ucl.Value)}
+	add := func(key string) {
+		if _, ok := v.Fields[key]; ok {
+			return
+		}
+		var field *ucl.Value
+		if child, ok := s.Sections[key]; ok {
+			field = child.object(s.pos[key])
+		} else if blocks, ok := s.Blocks[key]; ok {
+			field = &ucl.Value{Kind: ucl.Array, Pos: s.pos[key].ucl(), Elems: make([]*ucl.Value, len(blocks))}
+			for i, block := range blocks {
+				field.Elems[i] = block.object(s.pos[key])
+			}
+		} else if list, ok := s.Arrays[key]; ok {
+			field = &ucl.Value{Kind: ucl.Array, Pos: s.pos[key].ucl(), Elems: make([]*ucl.Value, len(list))}
+			elems := s.elems[key]
+			for i, raw := range list {
+				var e element
+				if i < len(elems) {
+					e = elems[i]
+				}
+				field.Elems[i] = scalar(raw, e.quoted, e.pos)
+			}
+		} else if raw, ok := s.Values[key]; ok {
+			field = scalar(raw, s.quoted[key], s.pos[key])
+		} else {
+			return
+		}
+		field.Comments = s.Comments[key]
+		v.Keys = append(v.Keys, key)
+		v.Fields[key] = field
+	}
+	for _, key := range s.Keys() {
+		add(key)
+	}
+	return v
+}
+
+// scalar types raw the way libucl does: quoted values are strings, and
+// unquoted ones are booleans, null, numbers, sizes such as 10k, durations
+// such as 10s, or else strings.
+func scalar(raw string, quoted bool, pos position) *ucl.Value {
+	v := &ucl.Value{Kind: ucl.String, Pos: pos.ucl(), Raw: raw}
+	if quoted || raw == "" {
+		return v
+	}
+	switch strings.ToLower(raw) {
+	case "true", "yes", "on":
+		v.Kind, v.Bool = ucl.Bool, true
+		return v
+	case "false", "no", "off":
+		v.Kind = ucl.Bool
+		return v
+	case "null":
+		v.Kind = ucl.Null
+		return v
+	}
+	if c := raw[0]; c != '-' && c != '+' && c != '.' && (c < '0' || c > '9') {
+		return v
+	}
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		v.Kind, v.Int = ucl.Int, n
+		return v
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		v.Kind, v.Float = ucl.Float, f
+		return v
+	}
+	if n, err := Value(raw).Size(); err == nil {
+		v.Kind, v.Int = ucl.Int, n
+		return v
+	}
+	if d, err := Value(raw).Duration(); err == nil {
+		v.Kind, v.Time = ucl.Time, d
+	}
+	return v
+}
+
+func (p position) ucl() ucl.Position {
+	return ucl.Position{File: p.file, Line: p.line, Column: p.col}
+}
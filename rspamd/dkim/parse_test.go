@@ -0,0 +1,92 @@
+package dkim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	input := `# rspamd options
+use_domain = "header";
+enabled = yes;
+dkim_cache_size = 2k;
+dkim_cache_expire = 1d;
+ratio = 0.5;
+count = 42;
+quoted = "42";
+selector = s1;
+nothing = null;
+domain {
+  b.com { selector = "b"; }
+  a.com { selector = "a"; }
+}
+sign_headers = ["from", to];
+`
+	v, err := Parse(strings.NewReader(input), WithFilename("dkim_signing.conf"))
+	require.NoError(t, err)
+	require.Equal(t, ucl.Object, v.Kind)
+	require.Equal(t, []string{"use_domain", "enabled", "dkim_cache_size", "dkim_cache_expire", "ratio", "count", "quoted", "selector", "nothing", "domain", "sign_headers"}, v.Keys)
+
+	require.Equal(t, &ucl.Value{Kind: ucl.String, Raw: "header", Pos: ucl.Position{File: "dkim_signing.conf", Line: 2, Column: 1}, Comments: []string{"# rspamd options"}}, v.Fields["use_domain"])
+	require.True(t, v.Fields["enabled"].Bool)
+	require.Equal(t, ucl.Int, v.Fields["dkim_cache_size"].Kind)
+	require.Equal(t, int64(2000), v.Fields["dkim_cache_size"].Int)
+	require.Equal(t, ucl.Time, v.Fields["dkim_cache_expire"].Kind)
+	require.Equal(t, 24*time.Hour, v.Fields["dkim_cache_expire"].Time)
+	require.Equal(t, 0.5, v.Fields["ratio"].Float)
+	require.Equal(t, int64(42), v.Fields["count"].Int)
+	require.Equal(t, ucl.String, v.Fields["quoted"].Kind)
+	require.Equal(t, ucl.String, v.Fields["selector"].Kind)
+	require.Equal(t, ucl.Null, v.Fields["nothing"].Kind)
+
+	require.Equal(t, []string{"b.com", "a.com"}, v.Get("domain").Keys)
+	require.Equal(t, "a", v.Get("domain", "a.com", "selector").Raw)
+	require.Equal(t, ucl.Position{File: "dkim_signing.conf", Line: 13, Column: 3}, v.Get("domain", "a.com").Pos)
+
+	headers := v.Fields["sign_headers"]
+	require.Equal(t, ucl.Array, headers.Kind)
+	require.Len(t, headers.Elems, 2)
+	require.Equal(t, "to", headers.Elems[1].Raw)
+	require.Equal(t, ucl.Position{File: "dkim_signing.conf", Line: 15, Column: 25}, headers.Elems[1].Pos)
+
+	v, err = Parse(strings.NewReader(`selectors [ { selector = "rsa"; }, { selector = "ed25519"; } ]`))
+	require.NoError(t, err)
+	selectors := v.Fields["selectors"]
+	require.Equal(t, ucl.Array, selectors.Kind)
+	require.Len(t, selectors.Elems, 2)
+	require.Equal(t, "ed25519", selectors.Elems[1].Fields["selector"].Raw)
+}
+
+func TestParseRecovery(t *testing.T) {
+	v, err := Parse(strings.NewReader("a = @;\nb = 1;\n"), WithRecovery())
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs))
+	require.Equal(t, []string{"b"}, v.Keys)
+}
+
+func TestParseComments(t *testing.T) {
+	input := `# rotated 2024-01
+// owner: mail team
+selector = "s2024a";
+domain {
+  /* legacy
+     key */
+  a.com { path = "/a.key"; } # trailing note
+  b.com { path = "/b.key"; }
+}
+`
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []string{"/* legacy\n     key */"}, conf.Sections["domain"].Comments["a.com"])
+	require.Equal(t, []string{"# trailing note"}, conf.Sections["domain"].Comments["b.com"])
+
+	v, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []string{"# rotated 2024-01", "// owner: mail team"}, v.Fields["selector"].Comments)
+	require.Nil(t, v.Fields["domain"].Comments)
+}
@@ -0,0 +1,105 @@
+package dkim
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Preset is a named baseline policy for signing configurations.
+type Preset struct {
+	Name        string
+	Description string
+	// MinRSABits is the smallest acceptable RSA key.
+	MinRSABits int
+	// RequireAligned fails domains signed with an unaligned d= domain.
+	RequireAligned bool
+	// Algorithms, if it has a Default or Domains, is checked with
+	// CheckAlgorithmPolicy.
+	Algorithms AlgorithmPolicy
+	// SignHeaders must all be covered by the dkim module's sign_headers.
+	SignHeaders []string
+}
+
+// Preset requirement names, in addition to those of CheckCompliance.
+const (
+	RequireAlgorithms  = "algorithms"
+	RequireSignHeaders = "sign-headers"
+)
+
+// m3aawgSignHeaders are the headers M3AAWG recommends covering.
+var m3aawgSignHeaders = []string{
+	"from", "to", "cc", "subject", "date", "message-id", "reply-to",
+	"mime-version", "content-type", "list-unsubscribe", "list-unsubscribe-post",
+}
+
+var presets = map[string]Preset{
+	"m3aawg-2023": {
+		Name:           "m3aawg-2023",
+		Description:    "M3AAWG DKIM signing best practices: 2048-bit RSA keys, aligned signatures and the recommended signed headers.",
+		MinRSABits:     2048,
+		RequireAligned: true,
+		SignHeaders:    m3aawgSignHeaders,
+	},
+	"strict": {
+		Name:           "strict",
+		Description:    "m3aawg-2023 plus dual Ed25519 and RSA signing for every domain.",
+		MinRSABits:     2048,
+		RequireAligned: true,
+		Algorithms:     AlgorithmPolicy{Default: []Algorithm{Ed25519SHA256, RSASHA256}},
+		SignHeaders:    m3aawgSignHeaders,
+	},
+	"legacy-compatible": {
+		Name:        "legacy-compatible",
+		Description: "RSA-only signing with 1024-bit keys accepted, for receivers that do not verify Ed25519.",
+		MinRSABits:  1024,
+		Algorithms:  AlgorithmPolicy{Default: []Algorithm{RSASHA256}},
+		SignHeaders: []string{"from"},
+	},
+}
+
+// LookupPreset returns the preset called name: "m3aawg-2023", "strict" or
+// "legacy-compatible".
+func LookupPreset(name string) (Preset, error) {
+	p, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset %q", name)
+	}
+	return p, nil
+}
+
+// PresetNames returns the names of the built-in presets in sorted order.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check evaluates eff and the dkim module config against p. Domains and
+// module are as for CheckCompliance. The report has the dkim-all-domains
+// and rsa-N results, followed by aligned-d, algorithms and sign-headers for
+// the parts p sets.
+func (p Preset) Check(eff EffectiveSigningConf, module *DKIMConf, domains []string) ComplianceReport {
+	present, aligned, size := checkDomainKeys(eff, domains, p.MinRSABits, false)
+	results := []ComplianceResult{present, size}
+	if p.RequireAligned {
+		results = append(results, aligned)
+	}
+	if p.Algorithms.Default != nil || len(p.Algorithms.Domains) > 0 {
+		algs := ComplianceResult{Requirement: RequireAlgorithms, Pass: true}
+		for _, v := range CheckAlgorithmPolicy(eff, p.Algorithms) {
+			algs.fail("%s: %s", v.Domain, v.Problem)
+		}
+		results = append(results, algs)
+	}
+	if len(p.SignHeaders) > 0 {
+		headers := ComplianceResult{Requirement: RequireSignHeaders, Pass: true}
+		for _, name := range missingSignHeaders(module, p.SignHeaders) {
+			headers.fail("sign_headers does not include %s", name)
+		}
+		results = append(results, headers)
+	}
+	return ComplianceReport{Results: results}
+}
@@ -0,0 +1,73 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresets(t *testing.T) {
+	require.Equal(t, []string{"legacy-compatible", "m3aawg-2023", "strict"}, PresetNames())
+	_, err := LookupPreset("nope")
+	require.Error(t, err)
+
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsaPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rsa.key"), rsaPem, 0o600))
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ed.key"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "rsa", Path: filepath.Join(dir, "rsa.key")},
+				"b.com": {Selector: "ed", Path: filepath.Join(dir, "ed.key")},
+			},
+		},
+	}
+	module, err := ParseDKIMConf(strings.NewReader(`sign_headers = "from:to:subject";`))
+	require.NoError(t, err)
+
+	legacy, err := LookupPreset("legacy-compatible")
+	require.NoError(t, err)
+	report := legacy.Check(eff, module, nil)
+	require.False(t, report.Pass())
+	require.Equal(t, []ComplianceResult{
+		{Requirement: RequireDKIMAllDomains, Pass: true},
+		{Requirement: "rsa-1024", Pass: true},
+		{Requirement: RequireAlgorithms, Details: []string{`b.com: selector "ed" uses ed25519-sha256, which the policy does not allow`}},
+		{Requirement: RequireSignHeaders, Pass: true},
+	}, report.Results)
+
+	m3aawg, err := LookupPreset("m3aawg-2023")
+	require.NoError(t, err)
+	report = m3aawg.Check(eff, module, []string{"a.com"})
+	require.Equal(t, []ComplianceResult{
+		{Requirement: RequireDKIMAllDomains, Pass: true},
+		{Requirement: "rsa-2048", Details: []string{"a.com: 1024-bit RSA key"}},
+		{Requirement: RequireAlignedDomain, Pass: true},
+		{Requirement: RequireSignHeaders, Details: []string{
+			"sign_headers does not include cc",
+			"sign_headers does not include date",
+			"sign_headers does not include message-id",
+			"sign_headers does not include reply-to",
+			"sign_headers does not include mime-version",
+			"sign_headers does not include content-type",
+			"sign_headers does not include list-unsubscribe",
+			"sign_headers does not include list-unsubscribe-post",
+		}},
+	}, report.Results)
+}
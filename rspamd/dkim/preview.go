@@ -0,0 +1,121 @@
+package dkim
+
+import (
+	"sort"
+	"strings"
+)
+
+// previewBodyHash is the bh= tag of an empty body in relaxed
+// canonicalization, used in place of the hash of a real message.
+const previewBodyHash = "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+// SignaturePreview is an example DKIM-Signature header showing what rspamd
+// would sign mail from Domain with.
+type SignaturePreview struct {
+	Domain string
+	Key    SigningKey
+	// Header is the folded header field with CRLF line endings and no
+	// trailing newline.
+	Header string
+}
+
+// PreviewSignatures renders a DKIM-Signature header for each domain with a
+// domain rule or map entry in eff that Resolve finds a key for, in domain
+// order. The h= list follows the sign_headers of module, or rspamd's default
+// if module is nil or leaves it unset, assuming every header is present;
+// oversigned headers are listed twice. The body hash is that of an empty
+// body and b= is left empty, as nothing is signed. The algorithm is read
+// from the key file, falling back to rsa-sha256 if it cannot be read.
+func PreviewSignatures(eff EffectiveSigningConf, module *DKIMConf) []SignaturePreview {
+	headers := parseSignHeaders(defaultSignHeaders)
+	if module != nil && module.SignHeaders != "" {
+		headers = module.SignHeaderList
+	}
+	var names []string
+	for _, h := range headers {
+		name := strings.ToLower(h.Name)
+		names = append(names, name)
+		if h.Oversigned {
+			names = append(names, name)
+		}
+	}
+	signed := strings.Join(names, ":")
+
+	var domains []string
+	for domain := range configuredDomains(eff) {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	var out []SignaturePreview
+	for _, domain := range domains {
+		key, ok := eff.Resolve(domain)
+		if !ok {
+			continue
+		}
+		alg := key.Algorithm
+		if alg == "" {
+			alg = RSASHA256
+			if signer, err := eff.signer(key); err == nil {
+				if a, err := KeyAlgorithm(signer); err == nil {
+					alg = a
+				}
+			}
+		}
+		tags := []string{
+			"v=1",
+			"a=" + string(alg),
+			"c=relaxed/relaxed",
+			"d=" + key.Domain,
+			"s=" + key.Selector,
+			"h=" + signed,
+			"bh=" + previewBodyHash,
+			"b=",
+		}
+		out = append(out, SignaturePreview{Domain: domain, Key: key, Header: foldHeader("DKIM-Signature", tags)})
+	}
+	return out
+}
+
+// foldHeader joins tags into a header field, folding lines before a tag
+// that would make them longer than 78 characters and inside an h= list at
+// colons.
+func foldHeader(name string, tags []string) string {
+	var b strings.Builder
+	b.WriteString(name + ":")
+	line := len(name) + 1
+	write := func(s string) {
+		if line+1+len(s) > 78 {
+			b.WriteString("\r\n\t")
+			line = 1
+		} else {
+			b.WriteString(" ")
+			line++
+		}
+		b.WriteString(s)
+		line += len(s)
+	}
+	for i, tag := range tags {
+		if i < len(tags)-1 {
+			tag += ";"
+		}
+		if !strings.HasPrefix(tag, "h=") || len(tag) <= 77 {
+			write(tag)
+			continue
+		}
+		parts := strings.Split(tag, ":")
+		write(parts[0] + ":")
+		for j, part := range parts[1:] {
+			if j < len(parts)-2 {
+				part += ":"
+			}
+			if line+len(part) > 78 {
+				b.WriteString("\r\n\t")
+				line = 1
+			}
+			b.WriteString(part)
+			line += len(part)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package dkim
+
+import (
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewSignatures(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, filepath.Join(dir, "a.key"))
+	no := false
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			TryFallback: &no,
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "ed", Path: filepath.Join(dir, "a.key")},
+				"b.com": {Selector: "esp", Path: "/missing.key", SigningDomain: "esp.net"},
+			},
+		},
+	}
+	module, err := ParseDKIMConf(strings.NewReader(`sign_headers = "(o)from:(x)date:to";`))
+	require.NoError(t, err)
+
+	previews := PreviewSignatures(eff, module)
+	require.Len(t, previews, 2)
+	require.Equal(t, "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed; d=a.com; s=ed;\r\n"+
+		"\th=from:from:date:to; bh=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=; b=", previews[0].Header)
+	require.Equal(t, "b.com", previews[1].Domain)
+	require.Contains(t, previews[1].Header, "a=rsa-sha256;")
+	require.Contains(t, previews[1].Header, "d=esp.net; s=esp;")
+
+	previews = PreviewSignatures(eff, nil)
+	for _, line := range strings.Split(previews[0].Header, "\r\n") {
+		require.LessOrEqual(t, len(line), 78)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(previews[0].Header + "\r\n\r\n"))
+	require.NoError(t, err)
+	sig := m.Header.Get("DKIM-Signature")
+	require.Contains(t, sig, "h=from:from:sender:reply-to:reply-to:subject:subject:date:")
+	require.True(t, strings.HasSuffix(sig, "autocrypt; bh=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=; b="), sig)
+}
@@ -0,0 +1,37 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrReadOnly is returned by functions that would write while read-only
+// mode is on, see SetReadOnly.
+var ErrReadOnly = errors.New("read-only mode")
+
+var readOnly atomic.Bool
+
+// SetReadOnly turns read-only mode on or off for the whole process, for
+// audits on hosts under change control. While it is on, Apply,
+// ApplyChangeSet, DirSink, HTTPSink, MoveKeys and WriteOwnerReports fail
+// with ErrReadOnly before creating, changing or removing anything, and so
+// does a Reconciler in enforce mode. Sinks implemented outside this
+// package are only guarded when written through Apply.
+func SetReadOnly(on bool) {
+	readOnly.Store(on)
+}
+
+// ReadOnly reports whether read-only mode is on.
+func ReadOnly() bool {
+	return readOnly.Load()
+}
+
+// checkWritable fails with ErrReadOnly in read-only mode; op describes the
+// write refused.
+func checkWritable(op string) error {
+	if readOnly.Load() {
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
+	return nil
+}
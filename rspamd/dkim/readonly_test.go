@@ -0,0 +1,43 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnly(t *testing.T) {
+	SetReadOnly(true)
+	t.Cleanup(func() { SetReadOnly(false) })
+	require.True(t, ReadOnly())
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.key"), []byte("key"), 0o600))
+	sink := DirSink(dir)
+	changes := []FileChange{{Name: "maps.d/selectors.map", Data: []byte("a.com s1\n")}}
+
+	_, err := Apply(sink, changes)
+	require.ErrorIs(t, err, ErrReadOnly)
+	_, err = ApplyChangeSet(sink, ChangeSet{Changes: changes})
+	require.ErrorIs(t, err, ErrReadOnly)
+	require.ErrorIs(t, sink.WriteFile("a.conf", nil, 0o644), ErrReadOnly)
+	require.ErrorIs(t, sink.Commit(changes), ErrReadOnly)
+	require.ErrorIs(t, sink.Recover(), ErrReadOnly)
+	_, err = MoveKeys(dir, []KeyMove{{From: "/old.key", To: "/new/new.key"}})
+	require.ErrorIs(t, err, ErrReadOnly)
+	_, err = WriteOwnerReports(dir, map[string][]Finding{"ops": {{Domain: "a.com"}}})
+	require.ErrorIs(t, err, ErrReadOnly)
+	_, err = WriteOwnerReportsTo(sink, map[string][]Finding{"ops": {{Domain: "a.com"}}})
+	require.ErrorIs(t, err, ErrReadOnly)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "old.key", entries[0].Name())
+
+	SetReadOnly(false)
+	_, err = Apply(sink, changes)
+	require.NoError(t, err)
+}
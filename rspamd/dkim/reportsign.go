@@ -0,0 +1,96 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrBadReportSignature is returned by VerifyReport when the signature does
+// not match the report.
+var ErrBadReportSignature = errors.New("report signature does not verify")
+
+// SignedReport is a report encoded as JSON with a detached signature over
+// the SHA-256 hash of Body, made with an RSA (PKCS #1 v1.5) or Ed25519 key as
+// in DKIM's a= tag.
+type SignedReport struct {
+	Body      []byte
+	Algorithm Algorithm
+	Signature []byte
+}
+
+// SignReport encodes report, such as a Summary or ComplianceReport, as
+// indented JSON and signs it with key.
+func SignReport(report any, key crypto.Signer) (SignedReport, error) {
+	alg, err := KeyAlgorithm(key)
+	if err != nil {
+		return SignedReport{}, err
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return SignedReport{}, fmt.Errorf("encode report: %w", err)
+	}
+	body = append(body, '\n')
+	digest := sha256.Sum256(body)
+	opts := crypto.SignerOpts(crypto.SHA256)
+	if alg == Ed25519SHA256 {
+		opts = crypto.Hash(0)
+	}
+	sig, err := key.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		return SignedReport{}, fmt.Errorf("sign report: %w", err)
+	}
+	return SignedReport{Body: body, Algorithm: alg, Signature: sig}, nil
+}
+
+// Files returns the report as name and its detached signature as name.sig,
+// which holds the algorithm and the base64 signature on one line.
+func (r SignedReport) Files(name string) []FileChange {
+	sig := string(r.Algorithm) + " " + base64.StdEncoding.EncodeToString(r.Signature) + "\n"
+	return []FileChange{
+		{Name: name, Data: r.Body, Perm: 0o644},
+		{Name: name + ".sig", Data: []byte(sig), Perm: 0o644},
+	}
+}
+
+// VerifyReport checks the detached signature sig, in the format written by
+// Files, of body with pub, an *rsa.PublicKey or ed25519.PublicKey.
+func VerifyReport(body, sig []byte, pub crypto.PublicKey) error {
+	alg, b64, ok := bytes.Cut(bytes.TrimSpace(sig), []byte(" "))
+	if !ok {
+		return errors.New("invalid report signature: want algorithm and signature")
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(b64))
+	if err != nil {
+		return fmt.Errorf("invalid report signature: %w", err)
+	}
+	digest := sha256.Sum256(body)
+	switch Algorithm(alg) {
+	case RSASHA256:
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("report signed with %s, got %T key", alg, pub)
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], raw) != nil {
+			return ErrBadReportSignature
+		}
+	case Ed25519SHA256:
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("report signed with %s, got %T key", alg, pub)
+		}
+		if !ed25519.Verify(k, digest[:], raw) {
+			return ErrBadReportSignature
+		}
+	default:
+		return fmt.Errorf("unsupported report signature algorithm %q", alg)
+	}
+	return nil
+}
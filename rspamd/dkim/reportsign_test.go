@@ -0,0 +1,49 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignReport(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	report := ComplianceReport{Results: []ComplianceResult{{Requirement: RequireKeySize, Pass: true}}}
+
+	for _, tc := range []struct {
+		key crypto.Signer
+		alg Algorithm
+	}{{edKey, Ed25519SHA256}, {rsaKey, RSASHA256}} {
+		signed, err := SignReport(report, tc.key)
+		require.NoError(t, err)
+		require.Equal(t, tc.alg, signed.Algorithm)
+
+		var decoded ComplianceReport
+		require.NoError(t, json.Unmarshal(signed.Body, &decoded))
+		require.Equal(t, report, decoded)
+
+		files := signed.Files("report.json")
+		require.Equal(t, "report.json", files[0].Name)
+		require.Equal(t, "report.json.sig", files[1].Name)
+		require.NoError(t, VerifyReport(files[0].Data, files[1].Data, tc.key.Public()))
+
+		tampered := append([]byte(nil), files[0].Data...)
+		tampered[len(tampered)-2] ^= 1
+		require.ErrorIs(t, VerifyReport(tampered, files[1].Data, tc.key.Public()), ErrBadReportSignature)
+	}
+
+	signed, err := SignReport(report, edKey)
+	require.NoError(t, err)
+	sig := signed.Files("r")[1].Data
+	require.ErrorContains(t, VerifyReport(signed.Body, sig, rsaKey.Public()), "report signed with ed25519-sha256")
+	require.ErrorContains(t, VerifyReport(signed.Body, []byte("md5 AAAA"), edKey.Public()), `unsupported report signature algorithm "md5"`)
+	require.ErrorContains(t, VerifyReport(signed.Body, []byte("garbage"), edKey.Public()), "invalid report signature")
+}
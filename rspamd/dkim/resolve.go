@@ -0,0 +1,195 @@
+package dkim
+
+import (
+	"strings"
+)
+
+// SigningKey is the selector and private key path used to sign mail for a
+// domain. Source tells where the values came from: "domain" for a domain
+// rule, "map" for the selector or path maps and "default" for the global
+// selector and path. Algorithm is only set by Plan, which reads the key.
+// RawKey is the key material of a rule's inline key option; it is used
+// instead of Path, which may then be empty.
+type SigningKey struct {
+	Domain    string
+	Selector  string
+	Path      string
+	RawKey    string
+	Source    string
+	Algorithm Algorithm
+}
+
+// ResolveHook can override the key Resolve picked for a domain or veto
+// signing by returning false. Embedders that want site policy written in an
+// expression language such as CEL or Starlark evaluate it inside the hook.
+type ResolveHook func(key SigningKey) (SigningKey, bool)
+
+// Resolve returns the key mail for domain is signed with, following the
+// order of rspamd's dkim_signing module. A matching domain rule (or the "*" rule) wins, then the selector and path
+// maps, and finally the global selector and path if try_fallback is not
+// disabled. Missing fields are filled from the next source in that order.
+// Domain is the d= domain, which a rule's SigningDomain may set to a third
+// party. The $domain and $selector placeholders in the path are substituted
+// with it and the selector. The result is finally passed through
+// e.Override, if set. Internationalized domains are looked up in their
+// ASCII form and IP literals never resolve, see ASCIIDomain.
+//
+// Resolve is more lenient than rspamd, which only finds rules and map
+// entries keyed by the lowercase domain: it also matches keys that differ in
+// case, a trailing dot or a leading "@", or are written in Unicode,
+// preferring the exact key. rspamd ignores such keys; FindDuplicateDomains
+// and NormalizeDomains find and rewrite them. A rule with a
+// selectors array resolves to its first entry; see ResolveAll for the rest.
+func (e EffectiveSigningConf) Resolve(domain string) (SigningKey, bool) {
+	key, ok := e.resolve(domain)
+	if !ok || e.Override == nil {
+		return key, ok
+	}
+	return e.Override(key)
+}
+
+// ResolveAll returns every key mail for domain is signed with: one per entry
+// of the matching rule's selectors array, or the single key Resolve returns.
+// Each key is resolved and passed through e.Override like Resolve does.
+func (e EffectiveSigningConf) ResolveAll(domain string) []SigningKey {
+	n := 1
+	if ascii, err := ASCIIDomain(domain); err == nil && e.Conf != nil {
+		if rule, ok := e.Conf.lookupRule(ascii); ok && len(rule.Selectors) > 1 {
+			n = len(rule.Selectors)
+		}
+	}
+	var keys []SigningKey
+	for i := 0; i < n; i++ {
+		key, ok := e.resolveAt(domain, i)
+		if ok && e.Override != nil {
+			key, ok = e.Override(key)
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (e EffectiveSigningConf) resolve(domain string) (SigningKey, bool) {
+	return e.resolveAt(domain, 0)
+}
+
+// resolveAt resolves domain using entry i of the matching rule's selectors
+// array, if it has one.
+func (e EffectiveSigningConf) resolveAt(domain string, i int) (SigningKey, bool) {
+	domain, err := ASCIIDomain(domain)
+	if err != nil {
+		return SigningKey{}, false
+	}
+	key := SigningKey{Domain: domain}
+	conf := e.Conf
+	if conf == nil {
+		conf = &DKIMSigningConf{}
+	}
+
+	rule, ok := conf.lookupRule(domain)
+	if ok {
+		key.Selector, key.Path, key.RawKey, key.Source = rule.Selector, rule.Path, rule.RawKey, "domain"
+		if i < len(rule.Selectors) {
+			sel := rule.Selectors[i]
+			key.Selector = sel.Selector
+			if sel.Path != "" || sel.RawKey != "" {
+				key.Path, key.RawKey = sel.Path, sel.RawKey
+			}
+		}
+		if rule.SigningDomain != "" {
+			key.Domain = domainKey(rule.SigningDomain)
+		}
+	}
+	if e.Maps != nil {
+		if key.Selector == "" {
+			if sel, ok := e.Maps.lookupSelector(domain); ok {
+				key.Selector = sel
+				if key.Source == "" {
+					key.Source = "map"
+				}
+			}
+		}
+		if key.Path == "" {
+			if path, ok := e.Maps.lookupPath(domain); ok {
+				key.Path = path
+				if key.Source == "" {
+					key.Source = "map"
+				}
+			}
+		}
+	}
+	if key.Source == "" {
+		if conf.TryFallback != nil && !*conf.TryFallback {
+			return SigningKey{}, false
+		}
+		key.Source = "default"
+	}
+	if key.Selector == "" {
+		key.Selector = conf.Selector
+	}
+	if key.Path == "" && key.RawKey == "" {
+		key.Path = conf.Path
+	}
+	if key.Selector == "" || key.Path == "" && key.RawKey == "" {
+		return SigningKey{}, false
+	}
+	key.Path = strings.NewReplacer("$domain", key.Domain, "$selector", key.Selector).Replace(key.Path)
+	return key, true
+}
+
+// lookupRule returns the domain rule for domain, or the "*" rule.
+func (c *DKIMSigningConf) lookupRule(domain string) (DomainRule, bool) {
+	if rule, ok := lookup(c.Domain, domain); ok {
+		return rule, true
+	}
+	rule, ok := c.Domain["*"]
+	return rule, ok
+}
+
+// lookupSelector returns the selector map entry for domain.
+func (m *Maps) lookupSelector(domain string) (string, bool) {
+	return lookup(m.Selectors, domain)
+}
+
+// lookupPath returns the path map entry for domain.
+func (m *Maps) lookupPath(domain string) (string, bool) {
+	return lookup(m.Paths, domain)
+}
+
+// lookup returns the entry of m for domain: the entry keyed by domain
+// itself, else one whose key has the same domainKey form. When several keys
+// share the form, the key written in that form wins, else the first in
+// sorted order, so lookups do not depend on map iteration order.
+func lookup[V any](m map[string]V, domain string) (V, bool) {
+	if v, ok := m[domain]; ok {
+		return v, true
+	}
+	form := domainKey(domain)
+	found, match := "", false
+	for key := range m {
+		if domainKey(key) != form {
+			continue
+		}
+		if !match || found != form && (key == form || key < found) {
+			found, match = key, true
+		}
+	}
+	if !match {
+		var zero V
+		return zero, false
+	}
+	return m[found], true
+}
+
+// domainKey returns a domain, domain rule or map key in the form every
+// lookup in this package compares domains in: lowercase, without a leading
+// "@" or a trailing dot, and in ASCII, see ASCIIDomain.
+func domainKey(key string) string {
+	key = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(key, "@"), "."))
+	if ascii, err := ASCIIDomain(key); err == nil {
+		return ascii
+	}
+	return key
+}
@@ -0,0 +1,117 @@
+package dkim
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	f, err := os.Open("../../examples/3/dkim_signing.conf")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	conf, err := ParseDKIMSigningConf(f)
+	require.NoError(t, err)
+
+	m, err := os.Open("../../examples/3/maps.d/dkim_selectors.map")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = m.Close() })
+	selectors, err := ParseDKIMSelectorsMap(m)
+	require.NoError(t, err)
+
+	eff := EffectiveSigningConf{Conf: conf, Maps: &Maps{Selectors: selectors}}
+
+	key, ok := eff.Resolve("Test.Mailer.com.")
+	require.True(t, ok)
+	require.Equal(t, SigningKey{
+		Domain:   "test.mailer.com",
+		Selector: "mail",
+		Path:     "/var/lib/rspamd/dkim/test.mailer.com.key",
+		Source:   "map",
+	}, key)
+
+	key, ok = eff.Resolve("other.com")
+	require.True(t, ok)
+	require.Equal(t, "s1", key.Selector)
+	require.Equal(t, "default", key.Source)
+
+	noFallback := false
+	conf.TryFallback = &noFallback
+	_, ok = eff.Resolve("other.com")
+	require.False(t, ok)
+
+	conf.Domain = map[string]DomainRule{"*": {Selector: "wild"}}
+	key, ok = eff.Resolve("other.com")
+	require.True(t, ok)
+	require.Equal(t, "wild", key.Selector)
+	require.Equal(t, "/var/lib/rspamd/dkim/other.com.key", key.Path)
+	require.Equal(t, "domain", key.Source)
+}
+
+func TestResolveCaseVariants(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{Path: "/k/$selector.key", Domain: map[string]DomainRule{
+			"Example.com": {Selector: "mixed"},
+			"EXAMPLE.com": {Selector: "upper"},
+			"b.com.":      {Selector: "dot"},
+		}},
+		Maps: &Maps{Selectors: map[string]string{"C.com": "c1", "@c.com": "c2"}},
+	}
+	for i := 0; i < 20; i++ {
+		key, ok := eff.Resolve("example.com")
+		require.True(t, ok)
+		require.Equal(t, "upper", key.Selector)
+		key, _ = eff.Resolve("c.com")
+		require.Equal(t, "c2", key.Selector)
+	}
+	key, _ := eff.Resolve("B.com")
+	require.Equal(t, "dot", key.Selector)
+
+	// The exact key wins, also once the rules changed after a lookup.
+	eff.Conf.Domain["example.com"] = DomainRule{Selector: "exact"}
+	key, _ = eff.Resolve("Example.COM")
+	require.Equal(t, "exact", key.Selector)
+	delete(eff.Conf.Domain, "b.com.")
+	_, ok := eff.Resolve("b.com")
+	require.False(t, ok)
+
+	// Edits keeping the number of rules are seen too.
+	delete(eff.Conf.Domain, "Example.com")
+	eff.Conf.Domain["Other.org"] = DomainRule{Selector: "other"}
+	key, ok = eff.Resolve("other.org")
+	require.True(t, ok)
+	require.Equal(t, "other", key.Selector)
+	eff.Maps.Selectors["C.com"] = "c3"
+	delete(eff.Maps.Selectors, "@c.com")
+	key, _ = eff.Resolve("c.com")
+	require.Equal(t, "c3", key.Selector)
+}
+
+func TestResolveOverride(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{Selector: "s1", Path: "/keys/$domain.$selector.key"},
+		Override: func(key SigningKey) (SigningKey, bool) {
+			switch key.Domain {
+			case "blocked.com":
+				return SigningKey{}, false
+			case "rotated.com":
+				key.Selector = "s2"
+				key.Path = "/keys/rotated.com.s2.key"
+			}
+			return key, true
+		},
+	}
+
+	_, ok := eff.Resolve("blocked.com")
+	require.False(t, ok)
+
+	key, ok := eff.Resolve("rotated.com")
+	require.True(t, ok)
+	require.Equal(t, "s2", key.Selector)
+	require.Equal(t, "/keys/rotated.com.s2.key", key.Path)
+
+	key, ok = eff.Resolve("plain.com")
+	require.True(t, ok)
+	require.Equal(t, "/keys/plain.com.s1.key", key.Path)
+}
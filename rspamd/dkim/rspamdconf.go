@@ -0,0 +1,38 @@
+package dkim
+
+import "io"
+
+// RspamdConf holds the DKIM views of a complete rspamd.conf. DKIM and
+// DKIMSigning are nil if the configuration has no dkim or dkim_signing
+// section.
+type RspamdConf struct {
+	DKIM        *DKIMConf
+	DKIMSigning *DKIMSigningConf
+	Includes    []Include
+	Warnings    []Warning
+}
+
+// ParseRspamdConf parses a complete rspamd.conf, following its includes of
+// modules.d, local.d and override.d, and returns the dkim and dkim_signing
+// module sections. Set WithMacros (CONFDIR, LOCAL_CONFDIR, DBDIR) or
+// WithRootPrefix when the configuration is not installed at /etc/rspamd.
+// Options of other modules are parsed but not returned; use Parse for them.
+func ParseRspamdConf(r io.Reader, opts ...Option) (*RspamdConf, error) {
+	doc, err := parseRspamdConfig(r, newParseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	conf := &RspamdConf{Includes: doc.includes}
+	if sec, ok := doc.root.Sections["dkim"]; ok {
+		if conf.DKIM, err = doc.dkimConf(sec); err != nil {
+			return nil, err
+		}
+	}
+	if sec, ok := doc.root.Sections["dkim_signing"]; ok {
+		if conf.DKIMSigning, err = doc.dkimSigningConf(sec); err != nil {
+			return nil, err
+		}
+	}
+	conf.Warnings = doc.warnings
+	return conf, doc.err()
+}
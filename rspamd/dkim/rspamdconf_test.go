@@ -0,0 +1,74 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRspamdConf(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"rspamd.conf": `
+.include "$CONFDIR/common.conf"
+options {
+  .include "$CONFDIR/options.inc"
+}
+modules {
+  path = "$PLUGINSDIR";
+}
+.include "$CONFDIR/modules.conf"
+`,
+		"common.conf":  "logging { level = \"info\"; }\n",
+		"options.inc":  "dns { timeout = 1s; }\n",
+		"modules.conf": `.include(glob=true) "$CONFDIR/modules.d/*.conf"` + "\n",
+		"modules.d/dkim.conf": `dkim {
+  sign_headers = "from:to";
+  .include(try=true,priority=1,duplicate=merge) "$LOCAL_CONFDIR/local.d/dkim.conf"
+}
+`,
+		"modules.d/dkim_signing.conf": `dkim_signing {
+  enabled = true;
+  selector = "dkim";
+  path = "/var/lib/rspamd/dkim/$domain.$selector.key";
+  .include(try=true,priority=5) "${DBDIR}/dynamic/dkim_signing.conf"
+  .include(try=true,priority=1,duplicate=merge) "$LOCAL_CONFDIR/local.d/dkim_signing.conf"
+  .include(try=true,priority=10) "$LOCAL_CONFDIR/override.d/dkim_signing.conf"
+}
+`,
+		"local.d/dkim_signing.conf": `selector = "s2024";
+domain { a.com { path = "/keys/a.key"; } }
+`,
+		"override.d/dkim_signing.conf": `allow_username_mismatch = true;`,
+	})
+
+	conf, err := ParseRspamdConf(strings.NewReader(`.include "`+dir+`/rspamd.conf"`), WithMacros(map[string]string{
+		"CONFDIR":       dir,
+		"LOCAL_CONFDIR": dir,
+		"DBDIR":         dir,
+	}))
+	require.NoError(t, err)
+	require.NotNil(t, conf.DKIM)
+	require.Equal(t, "from:to", conf.DKIM.SignHeaders)
+
+	signing := conf.DKIMSigning
+	require.NotNil(t, signing)
+	require.True(t, *signing.Enabled)
+	require.Equal(t, "s2024", signing.Selector)
+	require.Equal(t, "/var/lib/rspamd/dkim/$domain.$selector.key", signing.Path)
+	require.Equal(t, "/keys/a.key", signing.Domain["a.com"].Path)
+	require.True(t, *signing.AllowUsernameMismatch)
+
+	var skipped []string
+	for _, inc := range conf.Includes {
+		if !inc.Resolved {
+			skipped = append(skipped, strings.TrimPrefix(inc.Path, dir))
+		}
+	}
+	require.ElementsMatch(t, []string{"/local.d/dkim.conf", "/dynamic/dkim_signing.conf"}, skipped)
+
+	conf, err = ParseRspamdConf(strings.NewReader(`options { filters = "spf"; }`))
+	require.NoError(t, err)
+	require.Nil(t, conf.DKIM)
+	require.Nil(t, conf.DKIMSigning)
+}
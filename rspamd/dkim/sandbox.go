@@ -0,0 +1,81 @@
+package dkim
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrKeyRejected is returned, wrapped, when a key file fails a KeySandbox
+// check.
+var ErrKeyRejected = errors.New("key file rejected")
+
+// KeySandbox restricts which key files may be loaded, for hosts where
+// tenants can edit their own configuration. The zero value allows every
+// file.
+type KeySandbox struct {
+	// Root, if set, is the directory keys must be in once symlinks are
+	// resolved, as a host path after any RootPrefix.
+	Root string
+	// MaxSize, if positive, is the largest key file in bytes.
+	MaxSize int64
+	// UIDs and GIDs, if not empty, list the owners and groups a key file
+	// may have. They are checked only on Unix systems; elsewhere a sandbox
+	// setting them rejects every key.
+	UIDs []int
+	GIDs []int
+}
+
+// Check reports whether the key file at path may be loaded.
+func (s *KeySandbox) Check(path string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if s.Root != "" {
+		root, err := filepath.EvalSymlinks(s.Root)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %s is outside %s", ErrKeyRejected, path, s.Root)
+		}
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s is not a regular file", ErrKeyRejected, path)
+	}
+	if s.MaxSize > 0 && info.Size() > s.MaxSize {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrKeyRejected, path, s.MaxSize)
+	}
+	if len(s.UIDs) == 0 && len(s.GIDs) == 0 {
+		return nil
+	}
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return fmt.Errorf("%w: cannot check the owner of %s on this system", ErrKeyRejected, path)
+	}
+	if len(s.UIDs) > 0 && !slices.Contains(s.UIDs, uid) {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrKeyRejected, path, uid)
+	}
+	if len(s.GIDs) > 0 && !slices.Contains(s.GIDs, gid) {
+		return fmt.Errorf("%w: %s has group gid %d", ErrKeyRejected, path, gid)
+	}
+	return nil
+}
+
+// ReadPrivateKey checks path with Check and then reads the key.
+func (s *KeySandbox) ReadPrivateKey(path string) (crypto.Signer, error) {
+	if err := s.Check(path); err != nil {
+		return nil, err
+	}
+	return ReadPrivateKey(path)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package dkim
+
+import "io/fs"
+
+func fileOwner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
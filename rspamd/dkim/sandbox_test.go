@@ -0,0 +1,63 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySandbox(t *testing.T) {
+	dir := t.TempDir()
+	keys := filepath.Join(dir, "keys")
+	require.NoError(t, os.Mkdir(keys, 0o700))
+	writeEd25519Key(t, filepath.Join(keys, "s1.key"))
+	writeEd25519Key(t, filepath.Join(dir, "other.key"))
+	require.NoError(t, os.Symlink("../other.key", filepath.Join(keys, "escape.key")))
+	require.NoError(t, os.Symlink("s1.key", filepath.Join(keys, "link.key")))
+
+	sandbox := &KeySandbox{Root: keys}
+	_, err := sandbox.ReadPrivateKey(filepath.Join(keys, "s1.key"))
+	require.NoError(t, err)
+	_, err = sandbox.ReadPrivateKey(filepath.Join(keys, "link.key"))
+	require.NoError(t, err)
+	_, err = sandbox.ReadPrivateKey(filepath.Join(keys, "escape.key"))
+	require.ErrorIs(t, err, ErrKeyRejected)
+	_, err = sandbox.ReadPrivateKey(filepath.Join(keys, "..", "other.key"))
+	require.ErrorIs(t, err, ErrKeyRejected)
+	require.ErrorIs(t, sandbox.Check(keys), ErrKeyRejected)
+
+	sandbox = &KeySandbox{MaxSize: 16}
+	err = sandbox.Check(filepath.Join(keys, "s1.key"))
+	require.ErrorIs(t, err, ErrKeyRejected)
+	require.ErrorContains(t, err, "larger than 16 bytes")
+
+	info, err := os.Stat(filepath.Join(keys, "s1.key"))
+	require.NoError(t, err)
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		t.Skip("file ownership not available")
+	}
+	require.NoError(t, (&KeySandbox{UIDs: []int{uid}, GIDs: []int{gid}}).Check(filepath.Join(keys, "s1.key")))
+	err = (&KeySandbox{UIDs: []int{uid + 1}}).Check(filepath.Join(keys, "s1.key"))
+	require.ErrorIs(t, err, ErrKeyRejected)
+	err = (&KeySandbox{GIDs: []int{gid + 1}}).Check(filepath.Join(keys, "s1.key"))
+	require.ErrorIs(t, err, ErrKeyRejected)
+}
+
+func TestPlanUsesKeySandbox(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, filepath.Join(dir, "s1.key"))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "keys"), 0o700))
+	eff := EffectiveSigningConf{
+		Conf:    &DKIMSigningConf{Selector: "s1", Path: filepath.Join(dir, "s1.key")},
+		Sandbox: &KeySandbox{Root: filepath.Join(dir, "keys")},
+	}
+	_, err := eff.Plan("example.com", AlgorithmPolicy{})
+	require.ErrorContains(t, err, "key file rejected")
+
+	eff.Sandbox.Root = dir
+	_, err = eff.Plan("example.com", AlgorithmPolicy{})
+	require.NoError(t, err)
+}
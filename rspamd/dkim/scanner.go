@@ -0,0 +1,84 @@
+package dkim
+
+import (
+	"io"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind int
+
+const (
+	TokenEOF       = TokenKind(tokenEOF)
+	TokenIdent     = TokenKind(tokenIdent)
+	TokenString    = TokenKind(tokenString)
+	TokenLBrace    = TokenKind(tokenLBrace)
+	TokenRBrace    = TokenKind(tokenRBrace)
+	TokenEqual     = TokenKind(tokenEqual)
+	TokenColon     = TokenKind(tokenColon)
+	TokenSemicolon = TokenKind(tokenSemicolon)
+	TokenLBracket  = TokenKind(tokenLBracket)
+	TokenRBracket  = TokenKind(tokenRBracket)
+	TokenComma     = TokenKind(tokenComma)
+	TokenLParen    = TokenKind(tokenLParen)
+	TokenRParen    = TokenKind(tokenRParen)
+	// TokenDirective is a `.name` directive such as .include; Text is the
+	// name without the dot.
+	TokenDirective = TokenKind(tokenDirective)
+)
+
+func (k TokenKind) String() string {
+	return tokenType(k).String()
+}
+
+// Token is a lexical token of an rspamd configuration file. Text is the
+// identifier, the decoded string or heredoc body, or the directive name.
+// Comments are the comments skipped before the token.
+type Token struct {
+	Kind     TokenKind
+	Text     string
+	Pos      ucl.Position
+	Comments []string
+}
+
+// Scanner splits an rspamd configuration file into tokens using the same
+// rules as the parser: comments are skipped, strings are unquoted and their
+// escapes decoded unless WithRawEscapes is set. WithFilename, WithLimits,
+// WithInvalidUTF8 and WithTrace also apply; other options are ignored.
+type Scanner struct {
+	l      *lexer
+	peeked *Token
+	err    error
+}
+
+// NewScanner returns a Scanner reading from r.
+func NewScanner(r io.Reader, opts ...Option) *Scanner {
+	o := newParseOptions(opts)
+	var read int64
+	return &Scanner{l: newLexer(newLimitReader(r, &read, o.limits.InputSize), o, o.filename)}
+}
+
+// Next returns the next token. At the end of the input it returns a token
+// of kind TokenEOF. Errors are *ParseError values.
+func (s *Scanner) Next() (Token, error) {
+	if s.peeked != nil {
+		tok, err := *s.peeked, s.err
+		s.peeked, s.err = nil, nil
+		return tok, err
+	}
+	tok, err := s.l.next()
+	if err != nil {
+		return Token{}, errorAt(s.l.tok, err)
+	}
+	return Token{Kind: TokenKind(tok.typ), Text: tok.val, Pos: tok.pos.ucl(), Comments: tok.comments}, nil
+}
+
+// Peek returns the next token without consuming it.
+func (s *Scanner) Peek() (Token, error) {
+	if s.peeked == nil {
+		tok, err := s.Next()
+		s.peeked, s.err = &tok, err
+	}
+	return *s.peeked, s.err
+}
@@ -0,0 +1,47 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanner(t *testing.T) {
+	s := NewScanner(strings.NewReader("# local.d/options.inc\ndns {\n  nameserver = [\"127.0.0.1\"]; // resolver\n}\n.include \"x\"\n"), WithFilename("options.inc"))
+
+	var kinds []TokenKind
+	var texts []string
+	tok, err := s.Peek()
+	require.NoError(t, err)
+	require.Equal(t, Token{Kind: TokenIdent, Text: "dns", Pos: ucl.Position{File: "options.inc", Line: 2, Column: 1}, Comments: []string{"# local.d/options.inc"}}, tok)
+	for {
+		tok, err := s.Next()
+		require.NoError(t, err)
+		if tok.Kind == TokenEOF {
+			break
+		}
+		kinds = append(kinds, tok.Kind)
+		texts = append(texts, tok.Text)
+	}
+	require.Equal(t, []TokenKind{
+		TokenIdent, TokenLBrace, TokenIdent, TokenEqual, TokenLBracket, TokenString, TokenRBracket, TokenSemicolon, TokenRBrace,
+		TokenDirective, TokenString,
+	}, kinds)
+	require.Equal(t, "127.0.0.1", texts[5])
+	require.Equal(t, "include", texts[9])
+	require.Equal(t, "'{'", TokenLBrace.String())
+}
+
+func TestScannerError(t *testing.T) {
+	s := NewScanner(strings.NewReader("a = @"))
+	for range 2 {
+		_, err := s.Next()
+		require.NoError(t, err)
+	}
+	_, err := s.Peek()
+	requireParseError(t, err, "", 1, 5)
+	_, err = s.Next()
+	requireParseError(t, err, "", 1, 5)
+}
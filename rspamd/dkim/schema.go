@@ -0,0 +1,271 @@
+package dkim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dkimConfKeys are the options of the rspamd dkim module (dkim.conf).
+var dkimConfKeys = map[string]bool{
+	"enabled":                 true,
+	"sign_headers":            true,
+	"check_pubkey":            true,
+	"min_bits":                true,
+	"dkim_cache_size":         true,
+	"dkim_cache_expire":       true,
+	"time_jitter":             true,
+	"trusted_only":            true,
+	"skip_multi":              true,
+	"max_sigs":                true,
+	"whitelisted_signers_map": true,
+	"check_local":             true,
+	"check_authed":            true,
+	"symbol":                  true,
+	"symbol_allow":            true,
+	"symbol_reject":           true,
+	"symbol_tempfail":         true,
+	"symbol_na":               true,
+	"symbol_permfail":         true,
+	"domain":                  true,
+	"whitelist":               true,
+}
+
+// dkimSigningConfKeys are the options of the dkim_signing module
+// (dkim_signing.conf).
+var dkimSigningConfKeys = map[string]bool{
+	"enabled":                              true,
+	"allow_envfrom_empty":                  true,
+	"allow_hdrfrom_mismatch":               true,
+	"allow_hdrfrom_mismatch_local":         true,
+	"allow_hdrfrom_mismatch_sign_networks": true,
+	"allow_hdrfrom_multiple":               true,
+	"allow_username_mismatch":              true,
+	"allow_pubkey_mismatch":                true,
+	"auth_only":                            true,
+	"check_pubkey":                         true,
+	"domain":                               true,
+	"key_prefix":                           true,
+	"path":                                 true,
+	"path_map":                             true,
+	"selector":                             true,
+	"selector_map":                         true,
+	"selector_prefix":                      true,
+	"sign_authenticated":                   true,
+	"sign_condition":                       true,
+	"sign_inbound":                         true,
+	"sign_local":                           true,
+	"sign_networks":                        true,
+	"signing_table":                        true,
+	"key_table":                            true,
+	"try_fallback":                         true,
+	"use_domain":                           true,
+	"use_domain_sign_inbound":              true,
+	"use_domain_sign_local":                true,
+	"use_domain_sign_networks":             true,
+	"use_esld":                             true,
+	"use_http_headers":                     true,
+	"http_sign_header":                     true,
+	"http_domain_header":                   true,
+	"http_selector_header":                 true,
+	"http_key_header":                      true,
+	"use_redis":                            true,
+	"redis":                                true,
+	"symbol":                               true,
+}
+
+// domainRuleKeys are the options of a rule in the dkim_signing domain block.
+var domainRuleKeys = map[string]bool{
+	"selector":  true,
+	"path":      true,
+	"selectors": true,
+	"key":       true,
+	"domain":    true,
+}
+
+// foldKeys renames keys of sec that match a key of known only when case is
+// ignored, such as `Selector`, when WithCaseInsensitiveKeys is set. Each
+// rename is reported as a Warning. A key that is also set in the expected
+// case is dropped in favour of that one.
+func (d *document) foldKeys(sec *Section, known map[string]bool) {
+	if !d.opts.foldKeys || sec == nil {
+		return
+	}
+	order := make([]string, 0, len(sec.order))
+	for _, key := range sec.order {
+		lower := strings.ToLower(key)
+		if lower == key || !known[lower] {
+			order = append(order, key)
+			continue
+		}
+		pos := sec.pos[key]
+		if sec.has(lower) {
+			d.warn(pos, CodeDuplicateKey, fmt.Sprintf("key %q ignored, %q is also set", key, lower))
+		} else {
+			d.warn(pos, CodeKeyCase, fmt.Sprintf("key %q read as %q", key, lower))
+			sec.rename(key, lower)
+			order = append(order, lower)
+		}
+		sec.remove(key)
+	}
+	sec.order = order
+}
+
+// dkimConfDeprecated are options of the dkim module that still work but
+// should be moved, with the advice given for each.
+var dkimConfDeprecated = map[string]string{
+	"domain": "signing in the dkim module is superseded by the dkim_signing module; move the domain rules to dkim_signing.conf",
+}
+
+// checkKeys reports keys of sec missing from known: as errors when
+// WithStrict is set and as warnings otherwise. domain names the domain rule
+// sec belongs to, if any. Keys are reported in the order they appear in the
+// input.
+func (d *document) checkKeys(sec *Section, known map[string]bool, domain string) error {
+	keys := keySet(sec.Values)
+	for key := range sec.Arrays {
+		keys[key] = true
+	}
+	for key := range sec.Sections {
+		keys[key] = true
+	}
+	for key := range sec.Blocks {
+		keys[key] = true
+	}
+	var unknown []string
+	for key := range keys {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		a, b := sec.pos[unknown[i]], sec.pos[unknown[j]]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.col < b.col
+	})
+	for _, key := range unknown {
+		if !d.opts.strict {
+			msg := fmt.Sprintf("unknown key %q ignored", key)
+			if domain != "" {
+				msg = fmt.Sprintf("unknown key %q in domain %q ignored", key, domain)
+			}
+			d.warn(sec.pos[key], CodeIgnoredKey, msg)
+			continue
+		}
+		err := withCode(CodeUnknownKey, fmt.Errorf("unknown key %q", key))
+		if domain != "" {
+			err = withCode(CodeUnknownKey, fmt.Errorf("unknown key %q in domain %q", key, domain))
+		}
+		if err := d.report(sec.errorAt(key, err)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDeprecated warns about keys of sec listed in deprecated.
+func (d *document) checkDeprecated(sec *Section, deprecated map[string]string) {
+	for _, key := range sec.Keys() {
+		if advice, ok := deprecated[key]; ok {
+			d.warn(sec.pos[key], CodeDeprecatedKey, fmt.Sprintf("%s is deprecated: %s", key, advice))
+		}
+	}
+}
+
+// checkValues warns about selector and path values of sec, the top level of
+// dkim_signing.conf or the rule for domain, that rspamd accepts but that are
+// unlikely to work: selectors that are not valid DNS labels and relative key
+// paths, which rspamd resolves against its working directory.
+func (d *document) checkValues(sec *Section, domain string) {
+	in := ""
+	if domain != "" {
+		in = fmt.Sprintf(" in domain %q", domain)
+	}
+	if sel, ok := sec.Values["selector"]; ok && sel != "" && !validSelector(sel) {
+		d.warn(sec.pos["selector"], CodeSuspiciousValue, fmt.Sprintf("selector %q%s is not a valid DNS name", sel, in))
+	}
+	if path, ok := sec.Values["path"]; ok && path != "" && !strings.HasPrefix(path, "/") {
+		d.warn(sec.pos["path"], CodeSuspiciousValue, fmt.Sprintf("key path %q%s is relative to the rspamd working directory", path, in))
+	}
+}
+
+// validSelector reports whether sel is a dot-separated list of letters,
+// digits and inner hyphens, as RFC 6376 requires.
+func validSelector(sel string) bool {
+	for _, label := range strings.Split(sel, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func keySet[V any](m map[string]V) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
+
+func (s *Section) has(key string) bool {
+	_, v := s.Values[key]
+	_, a := s.Arrays[key]
+	_, sec := s.Sections[key]
+	_, b := s.Blocks[key]
+	return v || a || sec || b
+}
+
+// rename copies everything recorded for from to to. The caller removes from.
+func (s *Section) rename(from, to string) {
+	if v, ok := s.Values[from]; ok {
+		s.Values[to] = v
+	}
+	if v, ok := s.Arrays[from]; ok {
+		s.Arrays[to] = v
+	}
+	if v, ok := s.Sections[from]; ok {
+		s.Sections[to] = v
+	}
+	if v, ok := s.Blocks[from]; ok {
+		s.Blocks[to] = v
+	}
+	if v, ok := s.Comments[from]; ok {
+		s.Comments[to] = v
+	}
+	if v, ok := s.priority[from]; ok {
+		s.priority[to] = v
+	}
+	if v, ok := s.pos[from]; ok {
+		s.pos[to] = v
+	}
+	if v, ok := s.quoted[from]; ok {
+		s.quoted[to] = v
+	}
+	if v, ok := s.elems[from]; ok {
+		s.elems[to] = v
+	}
+}
+
+// remove deletes key from every map of s but not from order.
+func (s *Section) remove(key string) {
+	delete(s.Values, key)
+	delete(s.Arrays, key)
+	delete(s.Sections, key)
+	delete(s.Blocks, key)
+	delete(s.Comments, key)
+	delete(s.priority, key)
+	delete(s.pos, key)
+	delete(s.quoted, key)
+	delete(s.elems, key)
+}
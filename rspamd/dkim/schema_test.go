@@ -0,0 +1,126 @@
+package dkim
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictRejectsUnknownKeys(t *testing.T) {
+	input := "selctor = \"s1\";\ndomain {\n  a.com { selector = \"a\"; pth = \"/a.key\"; }\n}\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Empty(t, conf.Selector)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithStrict())
+	pe := requireParseError(t, err, "", 1, 1)
+	require.EqualError(t, pe.Err, `unknown key "selctor"`)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithStrict(), WithRecovery())
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	requireParseError(t, errs[1], "", 3, 27)
+	require.EqualError(t, errs[1].Err, `unknown key "pth" in domain "a.com"`)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithStrict(), WithLenient())
+	require.NoError(t, err)
+
+	_, err = ParseDKIMConf(strings.NewReader("enabled = true;\nsign_header = \"from\";\n"), WithStrict())
+	requireParseError(t, err, "", 2, 1)
+}
+
+func TestMaxErrors(t *testing.T) {
+	input := "a = @;\nb = @;\nc = @;\nselector = \"s1\";\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input), WithMaxErrors(2))
+	var errs ParseErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	require.NotNil(t, conf)
+	require.Empty(t, conf.Selector)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(input), WithMaxErrors(0))
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 3)
+	require.Equal(t, "s1", conf.Selector)
+}
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	input := "Selector = \"s1\";\nENABLED = false;\npath = \"/a.key\";\nPATH = \"/b.key\";\ndomain {\n  A.com { Path = \"/a.key\"; }\n}\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Empty(t, conf.Selector)
+	require.Nil(t, conf.Enabled)
+
+	_, err = ParseDKIMSigningConf(strings.NewReader(input), WithStrict())
+	requireParseError(t, err, "", 1, 1)
+
+	conf, err = ParseDKIMSigningConf(strings.NewReader(input), WithCaseInsensitiveKeys(), WithStrict())
+	require.NoError(t, err)
+	require.Equal(t, "s1", conf.Selector)
+	require.False(t, *conf.Enabled)
+	require.Equal(t, "/a.key", conf.Path)
+	require.Equal(t, "/a.key", conf.Domain["A.com"].Path)
+	require.Equal(t, []Warning{
+		{Line: 1, Column: 1, Code: CodeKeyCase, Message: `key "Selector" read as "selector"`},
+		{Line: 2, Column: 1, Code: CodeKeyCase, Message: `key "ENABLED" read as "enabled"`},
+		{Line: 4, Column: 1, Code: CodeDuplicateKey, Message: `key "PATH" ignored, "path" is also set`},
+		{Line: 6, Column: 11, Code: CodeKeyCase, Message: `key "Path" read as "path"`},
+	}, conf.Warnings)
+
+	val, err := Parse(strings.NewReader(input), WithCaseInsensitiveKeys())
+	require.NoError(t, err)
+	require.NotNil(t, val.Get("Selector"))
+
+	dkimConf, err := ParseDKIMConf(strings.NewReader("Sign_Headers = \"from\";\n"), WithCaseInsensitiveKeys())
+	require.NoError(t, err)
+	require.Equal(t, "from", dkimConf.SignHeaders)
+}
+
+func TestIgnoredKeyWarnings(t *testing.T) {
+	input := "selctor = \"s1\";\npath = \"keys/$domain.key\";\ndomain {\n  a.com { selector = \"s_1\"; pth = \"/a.key\"; }\n}\n"
+
+	conf, err := ParseDKIMSigningConf(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []Warning{
+		{Line: 1, Column: 1, Code: CodeIgnoredKey, Message: `unknown key "selctor" ignored`},
+		{Line: 4, Column: 29, Code: CodeIgnoredKey, Message: `unknown key "pth" in domain "a.com" ignored`},
+		{Line: 2, Column: 1, Code: CodeSuspiciousValue, Message: `key path "keys/$domain.key" is relative to the rspamd working directory`},
+		{Line: 4, Column: 11, Code: CodeSuspiciousValue, Message: `selector "s_1" in domain "a.com" is not a valid DNS name`},
+	}, conf.Warnings)
+
+	dkimConf, err := ParseDKIMConf(strings.NewReader("domain {\n  a.com { selector = \"s1\"; }\n}\n"))
+	require.NoError(t, err)
+	require.Equal(t, []Warning{
+		{Line: 1, Column: 1, Code: CodeDeprecatedKey, Message: "domain is deprecated: signing in the dkim module is superseded by the dkim_signing module; move the domain rules to dkim_signing.conf"},
+	}, dkimConf.Warnings)
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	_, err := ParseDKIMSigningConf(strings.NewReader("selctor = \"s1\";\n"), WithFilename("dkim_signing.conf"), WithLogger(logger))
+	require.NoError(t, err)
+	require.Equal(t, `level=WARN msg="unknown key \"selctor\" ignored" file=dkim_signing.conf line=1 column=1 code=DKIMCONF0017`+"\n", buf.String())
+
+	buf.Reset()
+	fsys := fstest.MapFS{"dkim_signing.conf": {Data: []byte(`selector_map = "https://maps.example/selectors.map";`)}}
+	_, err = LoadDKIMSigningConf(fsys, "dkim_signing.conf", WithLogger(logger))
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "code="+string(CodeMapNotLoaded))
+}
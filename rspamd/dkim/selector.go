@@ -0,0 +1,101 @@
+package dkim
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// maxSelectorAttempts bounds how many candidates NewSelector tries.
+const maxSelectorAttempts = 26
+
+// SelectorStrategy returns the candidate selector for domain on the given
+// attempt, starting at 0. NewSelector asks for further attempts while the
+// candidate collides with a selector already in use.
+type SelectorStrategy func(domain string, attempt int) (string, error)
+
+// DateSelector names selectors after the year of now with a letter suffix,
+// e.g. "s2024a", then "s2024b" if that one is taken.
+func DateSelector(now time.Time) SelectorStrategy {
+	return func(_ string, attempt int) (string, error) {
+		return "s" + strconv.Itoa(now.Year()) + string(rune('a'+attempt)), nil
+	}
+}
+
+// RandomHexSelector returns selectors of n random hex characters read from
+// r, or crypto/rand if r is nil.
+func RandomHexSelector(n int, r io.Reader) SelectorStrategy {
+	if r == nil {
+		r = rand.Reader
+	}
+	return func(string, int) (string, error) {
+		if n <= 0 {
+			return "", fmt.Errorf("invalid selector length %d", n)
+		}
+		buf := make([]byte, (n+1)/2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(buf)[:n], nil
+	}
+}
+
+// HMACSelector derives selectors of n hex characters from an HMAC-SHA256 of
+// the domain under secret, so the same domain always gets the same selector
+// without storing it anywhere.
+func HMACSelector(secret []byte, n int) SelectorStrategy {
+	return func(domain string, attempt int) (string, error) {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(domainKey(domain)))
+		if attempt > 0 {
+			mac.Write([]byte{0})
+			mac.Write([]byte(strconv.Itoa(attempt)))
+		}
+		sum := hex.EncodeToString(mac.Sum(nil))
+		if n <= 0 || n > len(sum) {
+			return "", fmt.Errorf("invalid selector length %d", n)
+		}
+		return sum[:n], nil
+	}
+}
+
+// NewSelector returns a selector for domain from strategy that is not
+// already used for that domain by eff: neither a selector it resolves to
+// nor one listed in its domain rule, its selectors array or the selectors
+// map.
+func NewSelector(strategy SelectorStrategy, domain string, eff EffectiveSigningConf) (string, error) {
+	taken := make(map[string]bool)
+	for _, key := range eff.ResolveAll(domain) {
+		taken[key.Selector] = true
+	}
+	domain = domainKey(domain)
+	if eff.Conf != nil {
+		if rule, ok := eff.Conf.lookupRule(domain); ok {
+			taken[rule.Selector] = true
+			for _, sel := range rule.Selectors {
+				taken[sel.Selector] = true
+			}
+		}
+	}
+	if eff.Maps != nil {
+		if sel, ok := eff.Maps.lookupSelector(domain); ok {
+			taken[sel] = true
+		}
+	}
+
+	for attempt := 0; attempt < maxSelectorAttempts; attempt++ {
+		sel, err := strategy(domain, attempt)
+		if err != nil {
+			return "", err
+		}
+		if !taken[sel] {
+			return sel, nil
+		}
+	}
+	return "", fmt.Errorf("no free selector for %q after %d attempts", domain, maxSelectorAttempts)
+}
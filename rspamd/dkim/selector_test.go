@@ -0,0 +1,63 @@
+package dkim
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSelector(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Selector: "s2024a",
+			Path:     "/keys/$domain.$selector.key",
+			Domain:   map[string]DomainRule{"ruled.com": {Selector: "s2024b"}},
+		},
+		Maps: &Maps{Selectors: map[string]string{"ruled.com": "s2024c"}},
+	}
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	sel, err := NewSelector(DateSelector(now), "other.com", eff)
+	require.NoError(t, err)
+	require.Equal(t, "s2024b", sel)
+
+	sel, err = NewSelector(DateSelector(now), "Ruled.com", eff)
+	require.NoError(t, err)
+	require.Equal(t, "s2024a", sel)
+
+	sel, err = NewSelector(RandomHexSelector(6, bytes.NewReader([]byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02})), "other.com", eff)
+	require.NoError(t, err)
+	require.Equal(t, "deadbe", sel)
+
+	for _, n := range []int{0, -1} {
+		_, err = NewSelector(RandomHexSelector(n, nil), "other.com", eff)
+		require.EqualError(t, err, fmt.Sprintf("invalid selector length %d", n))
+	}
+
+	h1, err := NewSelector(HMACSelector([]byte("secret"), 10), "example.com", EffectiveSigningConf{})
+	require.NoError(t, err)
+	h2, err := NewSelector(HMACSelector([]byte("secret"), 10), "EXAMPLE.com", EffectiveSigningConf{})
+	require.NoError(t, err)
+	require.Equal(t, h1, h2)
+	require.Len(t, h1, 10)
+
+	taken := EffectiveSigningConf{Conf: &DKIMSigningConf{Domain: map[string]DomainRule{"example.com": {Selector: h1}}}}
+	h3, err := NewSelector(HMACSelector([]byte("secret"), 10), "example.com", taken)
+	require.NoError(t, err)
+	require.NotEqual(t, h1, h3)
+
+	dual := EffectiveSigningConf{Conf: &DKIMSigningConf{
+		Path:   "/keys/$selector.key",
+		Domain: map[string]DomainRule{"dual.com": {Selectors: []DomainSelector{{Selector: "s2024b"}, {Selector: "s2024a"}}}},
+	}}
+	sel, err = NewSelector(DateSelector(now), "dual.com", dual)
+	require.NoError(t, err)
+	require.Equal(t, "s2024c", sel)
+
+	always := func(string, int) (string, error) { return "s2024a", nil }
+	_, err = NewSelector(always, "other.com", eff)
+	require.Error(t, err)
+}
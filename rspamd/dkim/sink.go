@@ -0,0 +1,71 @@
+package dkim
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Sink receives the files computed by this package, such as owner reports,
+// generated configuration and map files. name is a slash-separated path
+// relative to the sink. Implementations write to the local filesystem, an
+// HTTP service or any other target, such as an SFTP server or an S3 bucket.
+type Sink interface {
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// FileChange is a file to write to a Sink.
+type FileChange struct {
+	Name string
+	Data []byte
+	Perm fs.FileMode
+}
+
+// Apply writes changes to sink in order, stopping at the first failure. It
+// returns the number of changes written. If sink is a Committer, the
+// changes are written all or nothing.
+func Apply(sink Sink, changes []FileChange) (int, error) {
+	if err := checkWritable("apply changes"); err != nil {
+		return 0, err
+	}
+	if c, ok := sink.(Committer); ok {
+		if err := c.Commit(changes); err != nil {
+			return 0, err
+		}
+		return len(changes), nil
+	}
+	for i, c := range changes {
+		perm := c.Perm
+		if perm == 0 {
+			perm = 0o644
+		}
+		if err := sink.WriteFile(c.Name, c.Data, perm); err != nil {
+			return i, fmt.Errorf("write %s: %w", c.Name, err)
+		}
+	}
+	return len(changes), nil
+}
+
+// DirSink writes files below a local directory, creating directories as
+// needed. Each file is written to a temporary file, synced and renamed into
+// place, so readers never see a partly written file; Commit extends this to
+// several files.
+type DirSink string
+
+// WriteFile writes data to name below the directory.
+func (d DirSink) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := checkWritable("write " + name); err != nil {
+		return err
+	}
+	entries, err := d.stage([]FileChange{{Name: name, Data: data, Perm: perm}})
+	if err != nil {
+		return err
+	}
+	target := d.path(name)
+	if err := os.Rename(d.path(entries[0].Temp), target); err != nil {
+		os.Remove(d.path(entries[0].Temp))
+		return err
+	}
+	return syncDir(filepath.Dir(target))
+}
@@ -0,0 +1,64 @@
+//go:build !dkimconf_nohttp
+
+package dkim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+func init() { enableFeature(FeatureHTTPSink) }
+
+// HTTPSink writes each file with an HTTP PUT request to URL joined with the
+// file name, as accepted by WebDAV servers, configuration services and
+// presigned S3 URLs. Header is added to every request, for example for
+// authorization. A nil Client means http.DefaultClient. The file mode is
+// not sent.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+	Header http.Header
+}
+
+// WriteFile puts data at name below the sink's URL. Any status other than
+// 2xx is an error.
+func (s HTTPSink) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := checkWritable("write " + name); err != nil {
+		return err
+	}
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return err
+	}
+	u = u.JoinPath(strings.Split(path.Clean(name), "/")...)
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, vals := range s.Header {
+		req.Header[key] = append([]string(nil), vals...)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", u.Redacted(), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build !dkimconf_nohttp
+
+package dkim
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSink(t *testing.T) {
+	got := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		got[r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	sink := HTTPSink{URL: srv.URL + "/config", Header: http.Header{"Authorization": {"Bearer secret"}}}
+	names, err := WriteOwnerReportsTo(sink, map[string][]Finding{
+		"@mail team": {{Domain: "a.com", Check: "rotation", Message: "due"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"mail_team.txt"}, names)
+	require.Equal(t, map[string]string{"/config/mail_team.txt": "a.com\trotation\tdue\n"}, got)
+
+	_, err = Apply(HTTPSink{URL: srv.URL}, []FileChange{{Name: "a.map"}})
+	require.EqualError(t, err, "write a.map: PUT "+srv.URL+"/a.map: 403 Forbidden")
+
+	SetReadOnly(true)
+	defer SetReadOnly(false)
+	require.ErrorIs(t, sink.WriteFile("b.map", nil, 0o644), ErrReadOnly)
+	require.NotContains(t, got, "/config/b.map")
+}
@@ -0,0 +1,30 @@
+package dkim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	n, err := Apply(DirSink(dir), []FileChange{
+		{Name: "maps/selectors.map", Data: []byte("a.com s1\n")},
+		{Name: "local.d/dkim_signing.conf", Data: []byte("selector = \"s1\";\n"), Perm: 0o600},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	data, err := os.ReadFile(filepath.Join(dir, "maps", "selectors.map"))
+	require.NoError(t, err)
+	require.Equal(t, "a.com s1\n", string(data))
+	fi, err := os.Stat(filepath.Join(dir, "local.d", "dkim_signing.conf"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	n, err = Apply(DirSink(dir), []FileChange{{Name: "ok", Data: nil}, {Name: "../escape"}})
+	require.Equal(t, 0, n)
+	require.EqualError(t, err, `invalid file name "../escape"`)
+	require.NoFileExists(t, filepath.Join(dir, "ok"))
+}
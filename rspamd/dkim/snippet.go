@@ -0,0 +1,125 @@
+package dkim
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// WithSnippets sets ParseError.Snippet on parse errors to the failing line
+// of the input with up to context lines before and after it, so users can
+// paste a short reproduction into a bug report. Values of keys that look
+// like secrets, such as inline private keys and passwords, PEM blocks and
+// long base64 strings are replaced by <redacted>. Errors in value
+// conversion, such as an invalid boolean, get a snippet as well.
+func WithSnippets(context int) Option {
+	return func(o *parseOptions) {
+		o.snippets = true
+		o.snippetContext = max(context, 0)
+	}
+}
+
+// newLexer returns a lexer for the input of file, keeping a copy of the
+// decoded text if WithSnippets is set.
+func (d *document) newLexer(r io.Reader, file string) *lexer {
+	r = newLimitReader(r, &d.read, d.opts.limits.InputSize)
+	l := newLexer(r, d.opts, file)
+	if d.opts.snippets {
+		if d.sources == nil {
+			d.sources = make(map[string]*bytes.Buffer)
+		}
+		buf := &bytes.Buffer{}
+		d.sources[file] = buf
+		l.r = bufio.NewReader(io.TeeReader(newTextReader(r, d.opts, file), buf))
+	}
+	return l
+}
+
+// drain reads the rest of the input of l, so that a snippet can show the
+// lines after an error.
+func (l *lexer) drain() {
+	_, _ = io.Copy(io.Discard, l.r)
+}
+
+// snapshot sets the snippet of every ParseError in err that has none and
+// returns err.
+func (d *document) snapshot(err error) error {
+	if !d.opts.snippets || err == nil {
+		return err
+	}
+	var list ParseErrors
+	var pe *ParseError
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &pe):
+		list = ParseErrors{pe}
+	}
+	for _, pe := range list {
+		if src, ok := d.sources[pe.File]; ok && pe.Snippet == "" && pe.Line > 0 {
+			pe.Snippet = snippet(src.String(), pe.Line, pe.Column, d.opts.snippetContext)
+		}
+	}
+	return err
+}
+
+// snippet renders lines line-context to line+context of src with line
+// numbers, marking line and column.
+func snippet(src string, line, col, context int) string {
+	lines := redactLines(strings.Split(strings.TrimSuffix(src, "\n"), "\n"))
+	if line == len(lines)+1 {
+		// Errors at the end of the input point past the last line.
+		lines = append(lines, "")
+	}
+	if line > len(lines) {
+		return ""
+	}
+	first, last := max(line-context, 1), min(line+context, len(lines))
+	width := len(fmt.Sprint(last))
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		mark := " "
+		if n == line {
+			mark = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", mark, width, n, lines[n-1])
+		if n == line && col > 0 {
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", strings.Repeat(" ", col-1))
+		}
+	}
+	return b.String()
+}
+
+var (
+	// secretAssignment matches assignments to keys that hold secrets.
+	secretAssignment = regexp.MustCompile(`^(\s*"?(?:[\w.-]*(?:password|passwd|secret|token|private)[\w.-]*|key|api_key)"?(?:\s*[=:]\s*|\s+))(.+?)(\s*;?\s*)$`)
+	// longBase64 matches key material written on one line.
+	longBase64 = regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`)
+)
+
+// redactLines replaces secret values, PEM blocks and long base64 strings
+// in lines.
+func redactLines(lines []string) []string {
+	out := make([]string, len(lines))
+	inPEM := false
+	for i, line := range lines {
+		switch {
+		case strings.Contains(line, "-----BEGIN "):
+			inPEM = !strings.Contains(line, "-----END ")
+			line = line[:strings.Index(line, "-----BEGIN ")] + "<redacted>"
+		case inPEM:
+			inPEM = !strings.Contains(line, "-----END ")
+			line = "<redacted>"
+		default:
+			if m := secretAssignment.FindStringSubmatch(line); m != nil && m[2] != "{" {
+				line = m[1] + "<redacted>" + m[3]
+			}
+			line = longBase64.ReplaceAllString(line, "<redacted>")
+		}
+		out[i] = line
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+)
+
+// SkipBlock is returned by Handler.OnBlockStart to skip the contents of a
+// block. OnBlockEnd is not called for a skipped block.
+var SkipBlock = errors.New("skip this block")
+
+// Assignment is a key set to a scalar or an array, as reported by Stream.
+// Path names the blocks enclosing it, outermost first. Array is nil for
+// scalars.
+type Assignment struct {
+	Path  []string
+	Key   string
+	Value Value
+	Array []Value
+	Pos   ucl.Position
+}
+
+// Handler receives the entries of a configuration file from Stream. Nil
+// callbacks are skipped. Path slices are only valid during the call. An
+// error returned by a callback stops Stream and is returned by it.
+type Handler struct {
+	OnAssignment func(Assignment) error
+	OnBlockStart func(path []string, name string, pos ucl.Position) error
+	OnBlockEnd   func(path []string, name string) error
+	// OnInclude is called for .include and .try_include directives, which
+	// Stream does not follow.
+	OnInclude func(path string, pos ucl.Position) error
+}
+
+// Stream parses r and reports every entry to h as it is read, without
+// building the configuration in memory, for filtering a few keys out of very
+// large generated files. Macros and $variables are expanded as by the
+// parser, but repeated keys are reported each time they are set. The
+// options of NewScanner apply, together with WithMacros.
+func Stream(r io.Reader, h Handler, opts ...Option) error {
+	o := newParseOptions(opts)
+	var read int64
+	s := &streamer{
+		l:   newLexer(newLimitReader(r, &read, o.limits.InputSize), o, o.filename),
+		doc: &document{opts: o},
+		h:   h,
+	}
+	err := s.block(tokenEOF)
+	var he handlerError
+	if errors.As(err, &he) {
+		return he.err
+	}
+	if err != nil {
+		return errorAt(s.l.tok, err)
+	}
+	return nil
+}
+
+// handlerError marks an error returned by a Handler callback, which Stream
+// returns as is.
+type handlerError struct{ err error }
+
+func (e handlerError) Error() string { return e.err.Error() }
+
+func handled(err error) error {
+	if err == nil {
+		return nil
+	}
+	return handlerError{err}
+}
+
+type streamer struct {
+	l    *lexer
+	doc  *document
+	h    Handler
+	path []string
+}
+
+// block reads entries up to end, which is tokenRBrace or tokenEOF.
+func (s *streamer) block(end tokenType) error {
+	for {
+		tok, err := s.l.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case tok.typ == end:
+			return nil
+		case tok.typ == tokenEOF:
+			return withCode(CodeUnterminated, errors.New("unexpected end of input in block"))
+		case tok.typ == tokenDirective:
+			err = s.directive(tok)
+		case tok.typ == tokenIdent || tok.typ == tokenString:
+			err = s.entry(tok)
+		default:
+			err = withCode(CodeSyntax, fmt.Errorf("unexpected token: %v", tok.typ))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *streamer) entry(key token) error {
+	l := s.l
+	next, err := l.next()
+	if err != nil {
+		return err
+	}
+	if next.typ == tokenEqual || next.typ == tokenColon {
+		if next, err = l.next(); err != nil {
+			return err
+		}
+	}
+	variable := key.typ == tokenIdent && strings.HasPrefix(key.val, "$")
+	switch {
+	case next.typ == tokenLBrace && !variable:
+		return s.nested(key)
+	case next.typ == tokenLBracket && !variable:
+		items, err := parseArray(l)
+		if err != nil {
+			return err
+		}
+		vals := make([]Value, len(items))
+		for i, item := range items {
+			val, err := s.doc.expand(item.val)
+			if err != nil {
+				return errorAt(item.pos, err)
+			}
+			vals[i] = Value(val)
+		}
+		skipSeparator(l)
+		if s.h.OnAssignment == nil {
+			return nil
+		}
+		return handled(s.h.OnAssignment(Assignment{Path: s.path, Key: key.val, Array: vals, Pos: key.pos.ucl()}))
+	case next.typ == tokenLBrace || next.typ == tokenLBracket:
+		return errorAt(next.pos, withCode(CodeSyntax, fmt.Errorf("variable %s must be a single value", key.val)))
+	}
+	l.unread(next)
+	val, err := parseValueToken(l)
+	if err != nil {
+		return err
+	}
+	skipSeparator(l)
+	if val.val, err = s.doc.expand(val.val); err != nil {
+		return errorAt(val.pos, err)
+	}
+	if variable {
+		s.doc.define(key.val[1:], val.val)
+		return nil
+	}
+	if s.h.OnAssignment == nil {
+		return nil
+	}
+	return handled(s.h.OnAssignment(Assignment{Path: s.path, Key: key.val, Value: Value(val.val), Pos: key.pos.ucl()}))
+}
+
+// nested reads the block named by key, whose opening brace was read.
+func (s *streamer) nested(key token) error {
+	skip := false
+	if s.h.OnBlockStart != nil {
+		err := s.h.OnBlockStart(s.path, key.val, key.pos.ucl())
+		if errors.Is(err, SkipBlock) {
+			skip = true
+		} else if err != nil {
+			return handled(err)
+		}
+	}
+	if skip {
+		if err := s.skip(); err != nil {
+			return err
+		}
+		skipSeparator(s.l)
+		return nil
+	}
+	s.path = append(s.path, key.val)
+	err := s.block(tokenRBrace)
+	s.path = s.path[:len(s.path)-1]
+	if err != nil {
+		return err
+	}
+	skipSeparator(s.l)
+	if s.h.OnBlockEnd != nil {
+		return handled(s.h.OnBlockEnd(s.path, key.val))
+	}
+	return nil
+}
+
+// skip discards tokens up to the brace closing the current block.
+func (s *streamer) skip() error {
+	for depth := 1; depth > 0; {
+		tok, err := s.l.next()
+		if err != nil {
+			return err
+		}
+		switch tok.typ {
+		case tokenLBrace:
+			depth++
+		case tokenRBrace:
+			depth--
+		case tokenEOF:
+			return withCode(CodeUnterminated, errors.New("unexpected end of input in block"))
+		}
+	}
+	return nil
+}
+
+func (s *streamer) directive(tok token) error {
+	switch tok.val {
+	case "include", "try_include":
+	default:
+		return errorAt(tok.pos, withCode(CodeUnknownDirective, fmt.Errorf("unknown directive .%s", tok.val)))
+	}
+	if _, err := parseIncludeParams(s.l); err != nil {
+		return err
+	}
+	path, err := parseValue(s.l)
+	if err != nil {
+		return err
+	}
+	skipSeparator(s.l)
+	if s.h.OnInclude == nil {
+		return nil
+	}
+	if path, err = s.doc.expand(path); err != nil {
+		return errorAt(tok.pos, err)
+	}
+	return handled(s.h.OnInclude(path, tok.pos.ucl()))
+}
@@ -0,0 +1,76 @@
+package dkim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	input := `
+$sel = "s1";
+selector = "$sel";
+sign_networks = ["10.0.0.0/8", "$sel"];
+.include(try=true) "$CONFDIR/local.d/dkim_signing.conf"
+domain {
+  example.com { selector = "a"; }
+  skipped.org { selector = "b"; nested { x = 1; } }
+}
+`
+	var events []string
+	err := Stream(strings.NewReader(input), Handler{
+		OnAssignment: func(a Assignment) error {
+			if a.Array != nil {
+				events = append(events, strings.Join(a.Path, ".")+"/"+a.Key+"=["+string(a.Array[0])+" "+string(a.Array[1])+"]")
+				return nil
+			}
+			events = append(events, strings.Join(a.Path, ".")+"/"+a.Key+"="+a.Value.String())
+			return nil
+		},
+		OnBlockStart: func(path []string, name string, pos ucl.Position) error {
+			if name == "skipped.org" {
+				return SkipBlock
+			}
+			events = append(events, "start "+name)
+			return nil
+		},
+		OnBlockEnd: func(path []string, name string) error {
+			events = append(events, "end "+name)
+			return nil
+		},
+		OnInclude: func(path string, pos ucl.Position) error {
+			events = append(events, "include "+path)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/selector=s1",
+		"/sign_networks=[10.0.0.0/8 s1]",
+		"include /etc/rspamd/local.d/dkim_signing.conf",
+		"start domain",
+		"start example.com",
+		"domain.example.com/selector=a",
+		"end example.com",
+		"end domain",
+	}, events)
+}
+
+func TestStreamErrors(t *testing.T) {
+	stop := errors.New("found it")
+	err := Stream(strings.NewReader("a = 1;\nb = 2;\n"), Handler{OnAssignment: func(a Assignment) error {
+		if a.Key == "a" {
+			return stop
+		}
+		t.Fatal("read past the stopping key")
+		return nil
+	}})
+	require.Equal(t, stop, err)
+
+	err = Stream(strings.NewReader("a {\n  b = 1;\n"), Handler{}, WithFilename("big.conf"))
+	require.EqualError(t, err, "big.conf:3:1: [DKIMCONF0002] unexpected end of input in block")
+	require.Equal(t, CodeUnterminated, CodeOf(err))
+}
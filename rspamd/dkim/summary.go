@@ -0,0 +1,125 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"sort"
+)
+
+// Summary is an anonymized description of a signing configuration that can
+// be attached to bug reports. It holds counts and option names only, never
+// domain names, selectors or key paths.
+type Summary struct {
+	Domains                 int            `json:"domains"`
+	DomainRules             int            `json:"domain_rules"`
+	WildcardRule            bool           `json:"wildcard_rule"`
+	SelectorMapEntries      int            `json:"selector_map_entries"`
+	PathMapEntries          int            `json:"path_map_entries"`
+	SignedDomainsMapEntries int            `json:"signed_domains_map_entries"`
+	KeyTypes                map[string]int `json:"key_types"`
+	RSAKeyBits              map[int]int    `json:"rsa_key_bits"`
+	Features                []string       `json:"features"`
+}
+
+// Summarize collects a Summary for eff. Key files are read to count key
+// types and sizes; keys that cannot be read are counted as "unreadable".
+func Summarize(eff EffectiveSigningConf) Summary {
+	s := Summary{
+		KeyTypes:   make(map[string]int),
+		RSAKeyBits: make(map[int]int),
+	}
+	conf := eff.Conf
+	if conf == nil {
+		conf = &DKIMSigningConf{}
+	}
+
+	domains := make(map[string]bool)
+	for key := range conf.Domain {
+		if key == "*" {
+			s.WildcardRule = true
+			continue
+		}
+		s.DomainRules++
+		domains[domainKey(key)] = true
+	}
+	if eff.Maps != nil {
+		s.SelectorMapEntries = len(eff.Maps.Selectors)
+		s.PathMapEntries = len(eff.Maps.Paths)
+		s.SignedDomainsMapEntries = len(eff.Maps.SignedDomains)
+		for _, m := range []map[string]string{eff.Maps.Selectors, eff.Maps.Paths, eff.Maps.SignedDomains} {
+			for key := range m {
+				domains[domainKey(key)] = true
+			}
+		}
+	}
+	s.Domains = len(domains)
+
+	keys := make(map[SigningKey]bool)
+	for domain := range domains {
+		if key, ok := eff.Resolve(domain); ok {
+			keys[SigningKey{Path: key.Path, RawKey: key.RawKey}] = true
+		}
+	}
+	for key := range keys {
+		signer, err := eff.signer(key)
+		if err != nil {
+			s.KeyTypes["unreadable"]++
+			continue
+		}
+		switch k := signer.(type) {
+		case *rsa.PrivateKey:
+			s.KeyTypes["rsa"]++
+			s.RSAKeyBits[k.N.BitLen()]++
+		case ed25519.PrivateKey:
+			s.KeyTypes["ed25519"]++
+		}
+	}
+
+	flags := map[string]*bool{
+		"enabled":                      conf.Enabled,
+		"allow_username_mismatch":      conf.AllowUsernameMismatch,
+		"sign_authenticated":           conf.SignAuthenticated,
+		"sign_local":                   conf.SignLocal,
+		"sign_inbound":                 conf.SignInbound,
+		"allow_hdrfrom_mismatch":       conf.AllowHdrFromMismatch,
+		"allow_hdrfrom_mismatch_local": conf.AllowHdrFromMismatchLocal,
+		"allow_hdrfrom_multiple":       conf.AllowHdrFromMultiple,
+		"allow_envfrom_empty":          conf.AllowEnvfromEmpty,
+		"auth_only":                    conf.AuthOnly,
+		"use_esld":                     conf.UseESLD,
+		"try_fallback":                 conf.TryFallback,
+		"use_redis":                    conf.UseRedis,
+	}
+	for name, val := range flags {
+		if val != nil && *val {
+			s.Features = append(s.Features, name)
+		}
+	}
+	settings := map[string]string{
+		"use_domain":               conf.UseDomain,
+		"use_domain_sign_local":    conf.UseDomainSignLocal,
+		"use_domain_sign_networks": conf.UseDomainSignNetworks,
+	}
+	for name, val := range settings {
+		if val != "" {
+			s.Features = append(s.Features, name+"="+val)
+		}
+	}
+	if conf.SelectorMap != "" {
+		s.Features = append(s.Features, "selector_map")
+	}
+	if conf.PathMap != "" {
+		s.Features = append(s.Features, "path_map")
+	}
+	if conf.KeyPrefix != "" {
+		s.Features = append(s.Features, "key_prefix")
+	}
+	if conf.SelectorPrefix != "" {
+		s.Features = append(s.Features, "selector_prefix")
+	}
+	if conf.SignCondition != "" {
+		s.Features = append(s.Features, "sign_condition")
+	}
+	sort.Strings(s.Features)
+	return s
+}
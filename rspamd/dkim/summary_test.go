@@ -0,0 +1,53 @@
+package dkim
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret-domain.com.key"), keyPem, 0o600))
+
+	yes := true
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Enabled:     &yes,
+			UseDomain:   "header",
+			Selector:    "s1",
+			Path:        filepath.Join(dir, "$domain.key"),
+			SelectorMap: "/etc/rspamd/maps.d/selectors.map",
+			Domain: map[string]DomainRule{
+				"*":                 {Selector: "s1"},
+				"secret-domain.com": {Selector: "mail"},
+			},
+		},
+		Maps: &Maps{Selectors: map[string]string{"hidden.org": "s2", "secret-domain.com": "mail"}},
+	}
+
+	s := Summarize(eff)
+	require.Equal(t, 2, s.Domains)
+	require.Equal(t, 1, s.DomainRules)
+	require.True(t, s.WildcardRule)
+	require.Equal(t, 2, s.SelectorMapEntries)
+	require.Equal(t, map[string]int{"rsa": 1, "unreadable": 1}, s.KeyTypes)
+	require.Equal(t, map[int]int{1024: 1}, s.RSAKeyBits)
+	require.Equal(t, []string{"enabled", "selector_map", "use_domain=header"}, s.Features)
+
+	blob, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.NotContains(t, string(blob), "secret-domain")
+	require.NotContains(t, string(blob), "hidden")
+	require.NotContains(t, string(blob), dir)
+}
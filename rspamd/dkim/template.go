@@ -0,0 +1,45 @@
+package dkim
+
+import (
+	"fmt"
+	"text/template"
+)
+
+// TemplateFuncs returns template helpers backed by eff, for rendering MTA
+// configs and runbooks from the same source as rspamd:
+//
+//	{{ dkimSelector "example.com" }}  selector used for the domain
+//	{{ dkimKeyPath "example.com" }}   private key path used for the domain
+//	{{ dkimRecord "example.com" }}    DNS TXT record value for the domain's key
+//
+// Each helper fails template execution if the domain has no signing key.
+func TemplateFuncs(eff EffectiveSigningConf) template.FuncMap {
+	resolve := func(domain string) (SigningKey, error) {
+		key, ok := eff.Resolve(domain)
+		if !ok {
+			return SigningKey{}, fmt.Errorf("no DKIM key configured for %q", domain)
+		}
+		return key, nil
+	}
+	return template.FuncMap{
+		"dkimSelector": func(domain string) (string, error) {
+			key, err := resolve(domain)
+			return key.Selector, err
+		},
+		"dkimKeyPath": func(domain string) (string, error) {
+			key, err := resolve(domain)
+			return key.Path, err
+		},
+		"dkimRecord": func(domain string) (string, error) {
+			key, err := resolve(domain)
+			if err != nil {
+				return "", err
+			}
+			signer, err := eff.signer(key)
+			if err != nil {
+				return "", fmt.Errorf("read key for %q: %w", domain, err)
+			}
+			return PublicKeyRecord(signer)
+		},
+	}
+}
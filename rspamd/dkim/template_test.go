@@ -0,0 +1,40 @@
+package dkim
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "example.com.key")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	eff := EffectiveSigningConf{Conf: &DKIMSigningConf{
+		Selector: "s1",
+		Path:     filepath.Join(dir, "$domain.key"),
+	}}
+
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(eff)).Parse(
+		`{{ dkimSelector "example.com" }}|{{ dkimKeyPath "example.com" }}|{{ dkimRecord "example.com" }}`))
+	var out strings.Builder
+	require.NoError(t, tmpl.Execute(&out, nil))
+	require.Equal(t, "s1|"+keyPath+"|v=DKIM1; k=ed25519; p="+base64.StdEncoding.EncodeToString(pub), out.String())
+
+	tmpl = template.Must(template.New("t").Funcs(TemplateFuncs(EffectiveSigningConf{})).Parse(`{{ dkimSelector "example.com" }}`))
+	require.Error(t, tmpl.Execute(&out, nil))
+}
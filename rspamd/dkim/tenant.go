@@ -0,0 +1,100 @@
+package dkim
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TenantPolicy describes which tenant owns each domain and each key path on a
+// shared host.
+type TenantPolicy struct {
+	// Tenants maps a domain to the tenant owning it. Domains without an
+	// entry are not checked.
+	Tenants map[string]string
+	// PathOwner extracts the owning tenant from a key path through a named
+	// group "tenant", e.g. `^/var/lib/rspamd/dkim/(?P<tenant>[^/]+)/`. Paths
+	// it does not match are not checked.
+	PathOwner *regexp.Regexp
+}
+
+// TenantViolation is a domain whose key path belongs to another tenant.
+// Source is "domain" for domain rules or the name of the map the entry
+// came from ("path_map", "signed_domains_map").
+type TenantViolation struct {
+	Domain     string
+	Tenant     string
+	Path       string
+	PathTenant string
+	Source     string
+}
+
+// CheckTenantIsolation reports every domain rule and map entry that points at
+// a key path owned by a different tenant than the domain itself.
+func CheckTenantIsolation(eff EffectiveSigningConf, policy TenantPolicy) ([]TenantViolation, error) {
+	if policy.PathOwner == nil {
+		return nil, fmt.Errorf("tenant policy has no path owner pattern")
+	}
+	group := policy.PathOwner.SubexpIndex("tenant")
+	if group < 0 {
+		return nil, fmt.Errorf("path owner pattern %q has no \"tenant\" group", policy.PathOwner)
+	}
+	tenants := make(map[string]string, len(policy.Tenants))
+	for domain, tenant := range policy.Tenants {
+		tenants[domainKey(domain)] = tenant
+	}
+
+	var out []TenantViolation
+	check := func(source, domain, path string) {
+		tenant, ok := tenants[domainKey(domain)]
+		if !ok || path == "" {
+			return
+		}
+		m := policy.PathOwner.FindStringSubmatch(path)
+		if m == nil || m[group] == tenant {
+			return
+		}
+		out = append(out, TenantViolation{
+			Domain:     domain,
+			Tenant:     tenant,
+			Path:       path,
+			PathTenant: m[group],
+			Source:     source,
+		})
+	}
+
+	if eff.Conf != nil {
+		for domain, rule := range eff.Conf.Domain {
+			d := domain
+			if rule.SigningDomain != "" {
+				d = rule.SigningDomain
+			}
+			path := strings.NewReplacer("$domain", domainKey(d), "$selector", rule.Selector).Replace(rule.Path)
+			check("domain", domain, path)
+			for _, sel := range rule.Selectors {
+				path := sel.Path
+				if path == "" && sel.RawKey == "" {
+					path = rule.Path
+				}
+				check("domain", domain, strings.NewReplacer("$domain", domainKey(d), "$selector", sel.Selector).Replace(path))
+			}
+		}
+	}
+	if eff.Maps != nil {
+		for domain, path := range eff.Maps.Paths {
+			check("path_map", domain, path)
+		}
+		for domain, path := range eff.Maps.SignedDomains {
+			check("signed_domains_map", domain, path)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Domain != out[j].Domain {
+			return out[i].Domain < out[j].Domain
+		}
+		return out[i].Source < out[j].Source
+	})
+	return out, nil
+}
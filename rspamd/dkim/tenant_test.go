@@ -0,0 +1,48 @@
+package dkim
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTenantIsolation(t *testing.T) {
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			Domain: map[string]DomainRule{
+				"a.com": {Selector: "s1", Path: "/keys/alpha/$domain.$selector.key"},
+				"b.com": {Selector: "s1", Path: "/keys/alpha/b.com.key"},
+				"c.com": {Selector: "s1", Path: "/elsewhere/c.com.key"},
+				"d.com": {Path: "/keys/alpha/$selector.key", Selectors: []DomainSelector{
+					{Selector: "rsa"},
+					{Selector: "ed", Path: "/keys/beta/$domain.ed.key"},
+				}},
+			},
+		},
+		Maps: &Maps{
+			Paths:         map[string]string{"b.com": "/keys/beta/b.com.key"},
+			SignedDomains: map[string]string{"@A.com": "/keys/beta/a.com.key"},
+		},
+	}
+	policy := TenantPolicy{
+		Tenants: map[string]string{
+			"a.com": "alpha",
+			"b.com": "beta",
+			"c.com": "gamma",
+			"d.com": "alpha",
+		},
+		PathOwner: regexp.MustCompile(`^/keys/(?P<tenant>[^/]+)/`),
+	}
+
+	violations, err := CheckTenantIsolation(eff, policy)
+	require.NoError(t, err)
+	require.Equal(t, []TenantViolation{
+		{Domain: "@A.com", Tenant: "alpha", Path: "/keys/beta/a.com.key", PathTenant: "beta", Source: "signed_domains_map"},
+		{Domain: "b.com", Tenant: "beta", Path: "/keys/alpha/b.com.key", PathTenant: "alpha", Source: "domain"},
+		{Domain: "d.com", Tenant: "alpha", Path: "/keys/beta/d.com.ed.key", PathTenant: "beta", Source: "domain"},
+	}, violations)
+
+	_, err = CheckTenantIsolation(eff, TenantPolicy{PathOwner: regexp.MustCompile(`^/keys/`)})
+	require.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package dkim
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestMessageOptions configures GenerateTestMessages.
+type TestMessageOptions struct {
+	// To is the recipient of every message, typically a mailbox whose
+	// delivered messages show the Authentication-Results header.
+	To string
+	// LocalPart is the local part of the From address. It defaults to
+	// "dkim-test".
+	LocalPart string
+	// Now is used for the Date header and Message-ID. It defaults to the
+	// current time.
+	Now time.Time
+}
+
+// TestMessage is a message to inject through the MTA to check that mail from
+// Domain is signed with Key.
+type TestMessage struct {
+	Domain string
+	From   string
+	Key    SigningKey
+	// Data is the RFC 5322 message with CRLF line endings.
+	Data []byte
+}
+
+// GenerateTestMessages returns one message per domain with a domain rule or
+// map entry in eff that Resolve finds a key for, in domain order. The From
+// header uses that domain and the body states the expected d= and s= tags.
+func GenerateTestMessages(eff EffectiveSigningConf, opts TestMessageOptions) ([]TestMessage, error) {
+	if _, err := mail.ParseAddress(opts.To); err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", opts.To, err)
+	}
+	local := opts.LocalPart
+	if local == "" {
+		local = "dkim-test"
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var domains []string
+	for domain := range configuredDomains(eff) {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	var out []TestMessage
+	for _, domain := range domains {
+		key, ok := eff.Resolve(domain)
+		if !ok {
+			continue
+		}
+		from := local + "@" + domain
+		if _, err := mail.ParseAddress(from); err != nil {
+			return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+		}
+
+		var b strings.Builder
+		header := func(name, val string) {
+			b.WriteString(name + ": " + val + "\r\n")
+		}
+		header("From", from)
+		header("To", opts.To)
+		header("Subject", fmt.Sprintf("DKIM test for %s (s=%s)", domain, key.Selector))
+		header("Date", now.Format(time.RFC1123Z))
+		header("Message-ID", fmt.Sprintf("<dkim-test.%d.%s@%s>", now.Unix(), key.Selector, domain))
+		header("MIME-Version", "1.0")
+		header("Content-Type", "text/plain; charset=us-ascii")
+		b.WriteString("\r\n")
+		b.WriteString("This message checks DKIM signing after a configuration change.\r\n\r\n")
+		b.WriteString("Expected signature: d=" + key.Domain + "; s=" + key.Selector + "\r\n")
+		b.WriteString("Key source: " + key.Source + "\r\n")
+
+		out = append(out, TestMessage{Domain: domain, From: from, Key: key, Data: []byte(b.String())})
+	}
+	return out, nil
+}
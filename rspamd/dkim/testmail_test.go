@@ -0,0 +1,43 @@
+package dkim
+
+import (
+	"bytes"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTestMessages(t *testing.T) {
+	no := false
+	eff := EffectiveSigningConf{
+		Conf: &DKIMSigningConf{
+			TryFallback: &no,
+			Domain: map[string]DomainRule{
+				"*":     {Path: "/keys/$domain.key"},
+				"a.com": {Selector: "s1", Path: "/keys/a.key"},
+				"b.com": {Selector: "esp", Path: "/keys/esp.key", SigningDomain: "esp.net"},
+			},
+		},
+		Maps: &Maps{Selectors: map[string]string{"C.com": "s2", "d.com": ""}},
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	msgs, err := GenerateTestMessages(eff, TestMessageOptions{To: "postmaster@example.org", Now: now})
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, "a.com", msgs[0].Domain)
+	require.Equal(t, "esp.net", msgs[1].Key.Domain)
+	require.Equal(t, "dkim-test@c.com", msgs[2].From)
+
+	m, err := mail.ReadMessage(bytes.NewReader(msgs[1].Data))
+	require.NoError(t, err)
+	require.Equal(t, "dkim-test@b.com", m.Header.Get("From"))
+	require.Equal(t, "Wed, 01 May 2024 12:00:00 +0000", m.Header.Get("Date"))
+	require.Equal(t, "<dkim-test.1714564800.esp@b.com>", m.Header.Get("Message-ID"))
+	require.Contains(t, string(msgs[1].Data), "Expected signature: d=esp.net; s=esp\r\n")
+
+	_, err = GenerateTestMessages(eff, TestMessageOptions{To: "not an address"})
+	require.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package dkim
+
+import (
+	"fmt"
+	"io"
+)
+
+// WithTrace writes a line to w for every token read and every decision the
+// parser takes: assignments and how repeated keys were resolved, blocks
+// entered and left, includes opened or skipped and input skipped after an
+// error in recovery mode. Each line starts with the position it refers to.
+// The format is meant for reading and may change between releases.
+func WithTrace(w io.Writer) Option {
+	return func(o *parseOptions) {
+		o.trace = w
+	}
+}
+
+// tracef writes a trace line for pos if WithTrace is set.
+func (o *parseOptions) tracef(pos position, format string, args ...any) {
+	if o.trace == nil {
+		return
+	}
+	if pos.file != "" {
+		fmt.Fprintf(o.trace, "%s:%d:%d: ", pos.file, pos.line, pos.col)
+	} else {
+		fmt.Fprintf(o.trace, "%d:%d: ", pos.line, pos.col)
+	}
+	fmt.Fprintf(o.trace, format+"\n", args...)
+}
+
+// traceToken writes a trace line for a token read by the lexer.
+func (o *parseOptions) traceToken(tok token) {
+	switch tok.typ {
+	case tokenIdent, tokenString, tokenDirective:
+		o.tracef(tok.pos, "token %v %q", tok.typ, tok.val)
+	default:
+		o.tracef(tok.pos, "token %v", tok.typ)
+	}
+}
+
+func (a mergeAction) String() string {
+	switch a {
+	case actionSet:
+		return "set"
+	case actionMerge:
+		return "merge"
+	case actionSkip:
+		return "skip"
+	case actionCollect:
+		return "collect"
+	}
+	return fmt.Sprintf("action(%d)", int(a))
+}
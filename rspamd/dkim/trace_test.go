@@ -0,0 +1,59 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTrace(t *testing.T) {
+	input := "selector = s1;\nselector = s2;\ndomain {\n  a.com { path = \"/a.key\"; }\n}\n.try_include \"/nonexistent/x.conf\"\nbad = ;\nsign = [a, b];\n"
+	var trace strings.Builder
+	_, err := ParseDKIMSigningConf(strings.NewReader(input), WithTrace(&trace), WithRecovery(), WithFilename("dkim_signing.conf"))
+	require.Error(t, err)
+	require.Equal(t, `dkim_signing.conf:1:1: token identifier "selector"
+dkim_signing.conf:1:10: token '='
+dkim_signing.conf:1:12: token identifier "s1"
+dkim_signing.conf:1:1: assign "selector" = "s1" (set)
+dkim_signing.conf:1:14: token ';'
+dkim_signing.conf:2:1: token identifier "selector"
+dkim_signing.conf:2:10: token '='
+dkim_signing.conf:2:12: token identifier "s2"
+dkim_signing.conf:2:1: assign "selector" = "s2" (set)
+dkim_signing.conf:2:14: token ';'
+dkim_signing.conf:3:1: token identifier "domain"
+dkim_signing.conf:3:8: token '{'
+dkim_signing.conf:3:1: enter block "domain" (set)
+dkim_signing.conf:4:3: token identifier "a.com"
+dkim_signing.conf:4:9: token '{'
+dkim_signing.conf:4:3: enter block "a.com" (set)
+dkim_signing.conf:4:11: token identifier "path"
+dkim_signing.conf:4:16: token '='
+dkim_signing.conf:4:18: token string "/a.key"
+dkim_signing.conf:4:11: assign "path" = "/a.key" (set)
+dkim_signing.conf:4:26: token ';'
+dkim_signing.conf:4:28: token '}'
+dkim_signing.conf:4:28: leave block "a.com"
+dkim_signing.conf:5:1: token '}'
+dkim_signing.conf:5:1: leave block "domain"
+dkim_signing.conf:6:1: token directive "try_include"
+dkim_signing.conf:6:14: token string "/nonexistent/x.conf"
+dkim_signing.conf:6:1: .try_include "/nonexistent/x.conf" (try=true glob=false priority=0 prefix="")
+dkim_signing.conf:7:1: token identifier "bad"
+dkim_signing.conf:6:1: skip missing "/nonexistent/x.conf"
+dkim_signing.conf:7:5: token '='
+dkim_signing.conf:7:7: token ';'
+dkim_signing.conf:7:7: recover from unexpected value token: ';': skip to the next entry
+dkim_signing.conf:8:1: token identifier "sign"
+dkim_signing.conf:8:6: token '='
+dkim_signing.conf:8:8: token '['
+dkim_signing.conf:8:9: token identifier "a"
+dkim_signing.conf:8:10: token ','
+dkim_signing.conf:8:12: token identifier "b"
+dkim_signing.conf:8:13: token ']'
+dkim_signing.conf:8:1: array "sign" with 2 elements (set)
+dkim_signing.conf:8:14: token ';'
+dkim_signing.conf:9:1: token end of file
+`, trace.String())
+}
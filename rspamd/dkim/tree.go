@@ -0,0 +1,92 @@
+package dkim
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ConfigTree is the DKIM related configuration of an rspamd installation.
+// Modules without any configuration file are nil. ARC is read with the
+// dkim_signing option set, which the arc module shares.
+type ConfigTree struct {
+	DKIM        *DKIMConf
+	DKIMSigning *EffectiveSigningConf
+	ARC         *EffectiveSigningConf
+	Files       []SourceFile
+}
+
+// SourceFile is a file read by LoadConfigTree. Role is "config" for
+// configuration files and the referencing option, such as "selector_map",
+// for map files.
+type SourceFile struct {
+	Module string
+	Role   string
+	Path   string
+}
+
+// LoadConfigTree reads the dkim, dkim_signing and arc modules of the rspamd
+// configuration in fsys, which stands for the filesystem root, such as
+// os.DirFS("/") or an archive from OpenArchive. Each module is read from
+// $CONFDIR/modules.d, which includes its local.d and override.d files, or
+// from $LOCAL_CONFDIR/local.d and override.d alone if there is no modules.d
+// file. Use WithMacros if the configuration is not in /etc/rspamd.
+func LoadConfigTree(fsys fs.FS, opts ...Option) (*ConfigTree, error) {
+	macros := newParseOptions(opts).macros
+	tree := &ConfigTree{}
+	for _, module := range []string{"dkim", "dkim_signing", "arc"} {
+		root := localIncludes(
+			path.Join(macros["LOCAL_CONFDIR"], "local.d", module+".conf"),
+			path.Join(macros["LOCAL_CONFDIR"], "override.d", module+".conf"))
+		defaults := path.Join(macros["CONFDIR"], "modules.d", module+".conf")
+		if _, err := fs.Stat(fsys, fsPath(defaults)); err == nil {
+			root = `.include "` + defaults + `"`
+		}
+		modOpts := append(append([]Option{}, opts...), WithFS(fsys))
+		doc, err := parseRspamdConfig(strings.NewReader(root), newParseOptions(modOpts))
+		if err != nil {
+			return nil, err
+		}
+		var files []SourceFile
+		for _, inc := range doc.includes {
+			if inc.Resolved {
+				files = append(files, SourceFile{Module: module, Role: "config", Path: inc.Path})
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		tree.Files = append(tree.Files, files...)
+
+		sec := unwrapModule(doc.root, module)
+		if module == "dkim" {
+			if tree.DKIM, err = doc.dkimConf(sec); err != nil {
+				return nil, err
+			}
+		} else {
+			conf, err := doc.dkimSigningConf(sec)
+			if err != nil {
+				return nil, err
+			}
+			eff := &EffectiveSigningConf{Conf: conf}
+			maps, err := eff.loadMaps(fsys, files[0].Path, macros["CONFDIR"], modOpts)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", module, err)
+			}
+			for _, m := range maps {
+				m.Module = module
+				tree.Files = append(tree.Files, m)
+			}
+			if module == "arc" {
+				tree.ARC = eff
+			} else {
+				tree.DKIMSigning = eff
+			}
+		}
+		if err := doc.err(); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,48 @@
+package dkim
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigTree(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/rspamd/modules.d/dkim_signing.conf": {Data: []byte(`dkim_signing {
+  selector = "dkim";
+  path = "/var/lib/rspamd/dkim/$domain.$selector.key";
+  .include(try=true,priority=1,duplicate=merge) "$LOCAL_CONFDIR/local.d/dkim_signing.conf"
+  .include(try=true,priority=10) "$LOCAL_CONFDIR/override.d/dkim_signing.conf"
+}
+`)},
+		"etc/rspamd/local.d/dkim_signing.conf":         {Data: []byte(`selector_map = "$LOCAL_CONFDIR/local.d/maps.d/dkim_selectors.map";`)},
+		"etc/rspamd/local.d/maps.d/dkim_selectors.map": {Data: []byte("a.com s2024\n")},
+		"etc/rspamd/local.d/arc.conf":                  {Data: []byte(`selector = "arc";`)},
+		"etc/rspamd/override.d/arc.conf":               {Data: []byte(`use_esld = false;`)},
+	}
+
+	tree, err := LoadConfigTree(fsys)
+	require.NoError(t, err)
+	require.Nil(t, tree.DKIM)
+
+	require.Equal(t, "dkim", tree.DKIMSigning.Conf.Selector)
+	require.Equal(t, map[string]string{"a.com": "s2024"}, tree.DKIMSigning.Maps.Selectors)
+	key, ok := tree.DKIMSigning.Resolve("a.com")
+	require.True(t, ok)
+	require.Equal(t, "/var/lib/rspamd/dkim/a.com.s2024.key", key.Path)
+
+	require.Equal(t, "arc", tree.ARC.Conf.Selector)
+	require.False(t, *tree.ARC.Conf.UseESLD)
+
+	require.Equal(t, []SourceFile{
+		{Module: "dkim_signing", Role: "config", Path: "/etc/rspamd/modules.d/dkim_signing.conf"},
+		{Module: "dkim_signing", Role: "config", Path: "/etc/rspamd/local.d/dkim_signing.conf"},
+		{Module: "dkim_signing", Role: "selector_map", Path: "/etc/rspamd/local.d/maps.d/dkim_selectors.map"},
+		{Module: "arc", Role: "config", Path: "/etc/rspamd/local.d/arc.conf"},
+		{Module: "arc", Role: "config", Path: "/etc/rspamd/override.d/arc.conf"},
+	}, tree.Files)
+
+	_, err = LoadConfigTree(fstest.MapFS{"etc/rspamd/local.d/dkim.conf": {Data: []byte(`sign_headers = "from`)}})
+	require.EqualError(t, err, "/etc/rspamd/local.d/dkim.conf:1:16: [DKIMCONF0002] unterminated string")
+}
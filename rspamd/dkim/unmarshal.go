@@ -0,0 +1,237 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+)
+
+// Unmarshal parses an rspamd configuration file like Parse and decodes it
+// into v, which must be a non-nil pointer. See UnmarshalValue for how values
+// are decoded.
+func Unmarshal(r io.Reader, v any, opts ...Option) error {
+	val, err := Parse(r, opts...)
+	if err != nil {
+		return err
+	}
+	return UnmarshalValue(val, v)
+}
+
+// UnmarshalValue decodes val into v, which must be a non-nil pointer.
+//
+// Struct fields are matched to object keys by their `ucl:"key"` tag, or
+// else by field name ignoring case and underscores, so UseESLD matches
+// use_esld. Fields tagged `ucl:"-"` are skipped and keys without a field
+// are ignored. Embedded structs without a tag share the keys of the outer
+// struct. Scalars are interpreted from their text the way
+// the Value accessors do, so `10k` decodes into an int and `"yes"` into a
+// bool, and a time.Duration accepts `10s` as well as a number of seconds. A
+// scalar decodes into a slice as a single element. A *ucl.Value field
+// receives the node as-is, and an interface field receives map[string]any,
+// []any, string, int64, float64, bool, time.Duration or nil.
+//
+// A value that cannot be decoded is reported as a *ParseError at its
+// position.
+func UnmarshalValue(val *ucl.Value, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal: need a non-nil pointer, got %T", v)
+	}
+	return decode(val, rv.Elem())
+}
+
+var (
+	valueType    = reflect.TypeFor[*ucl.Value]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+func decode(val *ucl.Value, rv reflect.Value) error {
+	if rv.Type() == valueType {
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	}
+	if val == nil || val.Kind == ucl.Null {
+		rv.SetZero()
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decode(val, rv.Elem())
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return decodeError(val, rv.Type())
+		}
+		rv.Set(reflect.ValueOf(natural(val)))
+		return nil
+	case reflect.Struct:
+		if val.Kind != ucl.Object {
+			return decodeError(val, rv.Type())
+		}
+		return decodeStruct(val, rv)
+	case reflect.Map:
+		if val.Kind != ucl.Object || rv.Type().Key().Kind() != reflect.String {
+			return decodeError(val, rv.Type())
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(val.Keys)))
+		}
+		for _, key := range val.Keys {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decode(val.Fields[key], elem); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		elems := val.Elems
+		switch val.Kind {
+		case ucl.Array:
+		case ucl.Object:
+			return decodeError(val, rv.Type())
+		default:
+			elems = []*ucl.Value{val}
+		}
+		out := reflect.MakeSlice(rv.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := decode(elem, out.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(out)
+		return nil
+	}
+
+	if val.Kind == ucl.Object || val.Kind == ucl.Array {
+		return decodeError(val, rv.Type())
+	}
+	raw := Value(val.Raw)
+	var err error
+	switch {
+	case rv.Type() == durationType:
+		var d time.Duration
+		if d, err = raw.Duration(); err == nil {
+			rv.SetInt(int64(d))
+		}
+	case rv.Kind() == reflect.String:
+		rv.SetString(val.Raw)
+	case rv.Kind() == reflect.Bool:
+		var b bool
+		if b, err = raw.Bool(); err == nil {
+			rv.SetBool(b)
+		}
+	case rv.CanInt():
+		var n int64
+		if n, err = raw.Size(); err == nil {
+			if rv.OverflowInt(n) {
+				err = errors.New("out of range")
+			} else {
+				rv.SetInt(n)
+			}
+		}
+	case rv.CanUint():
+		var n int64
+		if n, err = raw.Size(); err == nil {
+			if n < 0 || rv.OverflowUint(uint64(n)) {
+				err = errors.New("out of range")
+			} else {
+				rv.SetUint(uint64(n))
+			}
+		}
+	case rv.CanFloat():
+		var f float64
+		if f, err = raw.Float(); err == nil {
+			rv.SetFloat(f)
+		}
+	default:
+		return decodeError(val, rv.Type())
+	}
+	if err != nil {
+		return &ParseError{File: val.Pos.File, Line: val.Pos.Line, Column: val.Pos.Column,
+			Err: withCode(CodeInvalidValue, fmt.Errorf("cannot decode %q into %s: %w", val.Raw, rv.Type(), err))}
+	}
+	return nil
+}
+
+func decodeStruct(val *ucl.Value, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, hasTag := field.Tag.Lookup("ucl")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct {
+			if err := decodeStruct(val, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		var node *ucl.Value
+		if name != "" {
+			node = val.Fields[name]
+		} else {
+			for _, key := range val.Keys {
+				if strings.EqualFold(strings.ReplaceAll(key, "_", ""), field.Name) {
+					node = val.Fields[key]
+					break
+				}
+			}
+		}
+		if node == nil {
+			continue
+		}
+		if err := decode(node, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// natural converts val into plain Go values for interface fields.
+func natural(val *ucl.Value) any {
+	switch val.Kind {
+	case ucl.Object:
+		m := make(map[string]any, len(val.Keys))
+		for _, key := range val.Keys {
+			m[key] = natural(val.Fields[key])
+		}
+		return m
+	case ucl.Array:
+		s := make([]any, len(val.Elems))
+		for i, elem := range val.Elems {
+			s[i] = natural(elem)
+		}
+		return s
+	case ucl.Int:
+		return val.Int
+	case ucl.Float:
+		return val.Float
+	case ucl.Bool:
+		return val.Bool
+	case ucl.Time:
+		return val.Time
+	case ucl.Null:
+		return nil
+	default:
+		return val.Raw
+	}
+}
+
+func decodeError(val *ucl.Value, t reflect.Type) error {
+	return &ParseError{File: val.Pos.File, Line: val.Pos.Line, Column: val.Pos.Column,
+		Err: withCode(CodeInvalidValue, fmt.Errorf("cannot decode %s into %s", val.Kind, t))}
+}
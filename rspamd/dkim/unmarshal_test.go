@@ -0,0 +1,84 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/ucl"
+	"github.com/stretchr/testify/require"
+)
+
+type redisOptions struct {
+	Servers []string      `ucl:"servers"`
+	Timeout time.Duration `ucl:"timeout"`
+}
+
+type customConf struct {
+	redisOptions
+	Selector string   `ucl:"selector"`
+	Enabled  *bool    `ucl:"enabled"`
+	MaxSize  int      `ucl:"max_size"`
+	Ratio    float64  `ucl:"ratio"`
+	Headers  []string `ucl:"sign_headers"`
+	Domain   map[string]struct {
+		Selector string
+		Path     string
+	} `ucl:"domain"`
+	Raw     *ucl.Value `ucl:"extra"`
+	Any     any        `ucl:"any"`
+	Skipped string     `ucl:"-"`
+	UseESLD bool
+}
+
+func TestUnmarshal(t *testing.T) {
+	var c customConf
+	c.Skipped = "kept"
+	err := Unmarshal(strings.NewReader(`
+selector = "s1";
+enabled = "yes";
+max_size = 10k;
+ratio = 0.5;
+sign_headers = "from";
+servers = ["127.0.0.1", "10.0.0.1:6379"];
+timeout = 5;
+use_esld = true;
+domain {
+  a.com { selector = "a"; path = "/keys/a.key"; }
+}
+extra { x = 1; }
+any { list = [1, 2.5, "s", 10s, null]; }
+unknown_key = "ignored";
+`), &c)
+	require.NoError(t, err)
+	require.Equal(t, "s1", c.Selector)
+	require.True(t, *c.Enabled)
+	require.Equal(t, 10000, c.MaxSize)
+	require.Equal(t, 0.5, c.Ratio)
+	require.Equal(t, []string{"from"}, c.Headers)
+	require.Equal(t, []string{"127.0.0.1", "10.0.0.1:6379"}, c.Servers)
+	require.Equal(t, 5*time.Second, c.Timeout)
+	require.True(t, c.UseESLD)
+	require.Equal(t, "a", c.Domain["a.com"].Selector)
+	require.Equal(t, "/keys/a.key", c.Domain["a.com"].Path)
+	require.Equal(t, int64(1), c.Raw.Get("x").Int)
+	require.Equal(t, map[string]any{"list": []any{int64(1), 2.5, "s", 10 * time.Second, nil}}, c.Any)
+	require.Equal(t, "kept", c.Skipped)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var c customConf
+	err := Unmarshal(strings.NewReader("selector = \"s1\";\nmax_size = lots;\n"), &c, WithFilename("x.conf"))
+	require.EqualError(t, err, `x.conf:2:1: [DKIMCONF0004] cannot decode "lots" into int: invalid size "lots"`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+
+	err = Unmarshal(strings.NewReader("selector { a = 1; }\n"), &c)
+	require.EqualError(t, err, "line 1, column 1: [DKIMCONF0004] cannot decode object into string")
+
+	var small struct {
+		N int8 `ucl:"n"`
+	}
+	require.ErrorContains(t, Unmarshal(strings.NewReader("n = 1000;"), &small), "out of range")
+
+	require.ErrorContains(t, Unmarshal(strings.NewReader(""), c), "need a non-nil pointer")
+}
@@ -0,0 +1,203 @@
+package dkim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value is a raw scalar from a configuration file, with accessors that
+// interpret it the way UCL does.
+type Value string
+
+// String returns the value as written.
+func (v Value) String() string {
+	return string(v)
+}
+
+// Bool parses the value as a UCL boolean (true/false, yes/no, on/off, 1/0).
+func (v Value) Bool() (bool, error) {
+	return parseBool(string(v))
+}
+
+// Int parses the value as a plain decimal integer.
+func (v Value) Int() (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q", string(v))
+	}
+	return n, nil
+}
+
+// Float parses the value as a plain decimal number.
+func (v Value) Float() (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", string(v))
+	}
+	return f, nil
+}
+
+// Size parses a number with an optional UCL multiplier suffix: k, m and g
+// multiply by powers of 1000, kb, mb and gb by powers of 1024.
+func (v Value) Size() (int64, error) {
+	num, suffix := splitSuffix(string(v))
+	mult, ok := sizeSuffixes[strings.ToLower(suffix)]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", string(v))
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", string(v))
+	}
+	f *= mult
+	if f > math.MaxInt64 || f < math.MinInt64 {
+		return 0, fmt.Errorf("size %q out of range", string(v))
+	}
+	return int64(f), nil
+}
+
+// Duration parses a UCL time value: a number of seconds with an optional ms,
+// s, min, h, d, w or y suffix, e.g. "10s", "1.5h" or "1d".
+func (v Value) Duration() (time.Duration, error) {
+	num, suffix := splitSuffix(string(v))
+	unit, ok := timeSuffixes[strings.ToLower(suffix)]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q", string(v))
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", string(v))
+	}
+	d := f * float64(unit)
+	if d > math.MaxInt64 || d < math.MinInt64 {
+		return 0, fmt.Errorf("duration %q out of range", string(v))
+	}
+	return time.Duration(d), nil
+}
+
+var sizeSuffixes = map[string]float64{
+	"":   1,
+	"k":  1e3,
+	"m":  1e6,
+	"g":  1e9,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+var timeSuffixes = map[string]time.Duration{
+	"":    time.Second,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+	"w":   7 * 24 * time.Hour,
+	"y":   365 * 24 * time.Hour,
+}
+
+// splitSuffix splits a trailing alphabetic suffix off a number.
+func splitSuffix(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := len(s)
+	for i > 0 && (s[i-1] >= 'a' && s[i-1] <= 'z' || s[i-1] >= 'A' && s[i-1] <= 'Z') {
+		i--
+	}
+	return s[:i], s[i:]
+}
+
+// GetString returns the scalar assigned to key, or def if key is not set.
+// It fails if key holds an array or a block.
+func (s *Section) GetString(key, def string) (string, error) {
+	val, ok, err := s.scalar(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	return val.String(), nil
+}
+
+// GetBool returns key parsed as a UCL boolean, or def if key is not set.
+func (s *Section) GetBool(key string, def bool) (bool, error) {
+	val, ok, err := s.scalar(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	b, err := val.Bool()
+	if err != nil {
+		return def, s.parseError(key, err)
+	}
+	return b, nil
+}
+
+// GetInt returns key parsed as a decimal integer, or def if key is not set.
+func (s *Section) GetInt(key string, def int64) (int64, error) {
+	val, ok, err := s.scalar(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	n, err := val.Int()
+	if err != nil {
+		return def, s.parseError(key, err)
+	}
+	return n, nil
+}
+
+// GetDuration returns key parsed as a UCL time value, or def if key is not
+// set.
+func (s *Section) GetDuration(key string, def time.Duration) (time.Duration, error) {
+	val, ok, err := s.scalar(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	d, err := val.Duration()
+	if err != nil {
+		return def, s.parseError(key, err)
+	}
+	return d, nil
+}
+
+// GetStringSlice returns the elements of the array assigned to key, or def
+// if key is not set. A scalar is returned as a slice of one element, as
+// rspamd accepts either for list options.
+func (s *Section) GetStringSlice(key string, def []string) ([]string, error) {
+	if s == nil {
+		return def, nil
+	}
+	if vals, ok := s.Arrays[key]; ok {
+		return append([]string(nil), vals...), nil
+	}
+	val, ok, err := s.scalar(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	return []string{val.String()}, nil
+}
+
+// scalar returns the scalar assigned to key, failing if key is set to
+// something else. A nil Section has no keys.
+func (s *Section) scalar(key string) (Value, bool, error) {
+	if s == nil {
+		return "", false, nil
+	}
+	if val, ok := s.Value(key); ok {
+		return val, true, nil
+	}
+	if _, ok := s.Arrays[key]; ok {
+		return "", false, s.parseError(key, errors.New("is an array, not a single value"))
+	}
+	if _, ok := s.Sections[key]; ok {
+		return "", false, s.parseError(key, errors.New("is a block, not a single value"))
+	}
+	return "", false, nil
+}
+
+func (s *Section) parseError(key string, err error) error {
+	if CodeOf(err) == "" {
+		err = withCode(CodeInvalidValue, err)
+	}
+	return s.errorAt(key, fmt.Errorf("parse %s: %w", key, err))
+}
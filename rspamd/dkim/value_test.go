@@ -0,0 +1,141 @@
+package dkim
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueDuration(t *testing.T) {
+	cases := map[Value]time.Duration{
+		"10":    10 * time.Second,
+		"10s":   10 * time.Second,
+		"250ms": 250 * time.Millisecond,
+		"5min":  5 * time.Minute,
+		"1.5h":  90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+	}
+	for in, want := range cases {
+		got, err := in.Duration()
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+
+	for _, bad := range []Value{"", "s", "10x", "ten"} {
+		_, err := bad.Duration()
+		require.Error(t, err, bad)
+	}
+}
+
+func TestValueNumbers(t *testing.T) {
+	cases := map[Value]int64{
+		"512": 512,
+		"2k":  2000,
+		"2K":  2000,
+		"1m":  1000000,
+		"1g":  1000000000,
+		"1kb": 1024,
+		"4mb": 4 << 20,
+		"1gb": 1 << 30,
+	}
+	for in, want := range cases {
+		got, err := in.Size()
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	_, err := Value("1tb").Size()
+	require.Error(t, err)
+
+	n, err := Value("42").Int()
+	require.NoError(t, err)
+	require.EqualValues(t, 42, n)
+	_, err = Value("2k").Int()
+	require.Error(t, err)
+
+	f, err := Value("0.5").Float()
+	require.NoError(t, err)
+	require.Equal(t, 0.5, f)
+
+	b, err := Value("on").Bool()
+	require.NoError(t, err)
+	require.True(t, b)
+}
+
+func TestSectionValue(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader(`
+cache {
+  dkim_cache_expire = 1d;
+  dkim_cache_size = 2k;
+}
+`))
+	require.NoError(t, err)
+
+	v, ok := conf.Sections["cache"].Value("dkim_cache_expire")
+	require.True(t, ok)
+	d, err := v.Duration()
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, d)
+
+	v, ok = conf.Sections["cache"].Value("dkim_cache_size")
+	require.True(t, ok)
+	n, err := v.Size()
+	require.NoError(t, err)
+	require.EqualValues(t, 2000, n)
+
+	_, ok = conf.Sections["cache"].Value("missing")
+	require.False(t, ok)
+}
+
+func TestSectionGetters(t *testing.T) {
+	conf, err := ParseDKIMConf(strings.NewReader(`
+dkim_cache_expire = 1d;
+max_sigs = 5;
+trusted_only = yes;
+check_authed = maybe;
+whitelisted_signers_map = ["a.com", "b.com"];
+check_local = "x";
+whitelist { }
+`), WithFilename("dkim.conf"))
+	require.NoError(t, err)
+	root := conf.Root
+
+	d, err := root.GetDuration("dkim_cache_expire", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, d)
+	d, err = root.GetDuration("time_jitter", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, d)
+
+	n, err := root.GetInt("max_sigs", 1)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, n)
+
+	b, err := root.GetBool("trusted_only", false)
+	require.NoError(t, err)
+	require.True(t, b)
+	b, err = root.GetBool("check_authed", true)
+	require.EqualError(t, err, `dkim.conf:5:1: [DKIMCONF0004] parse check_authed: invalid boolean "maybe"`)
+	require.Equal(t, CodeInvalidValue, CodeOf(err))
+	require.True(t, b)
+
+	list, err := root.GetStringSlice("whitelisted_signers_map", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.com", "b.com"}, list)
+	list, err = root.GetStringSlice("check_local", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"x"}, list)
+
+	_, err = root.GetString("whitelisted_signers_map", "")
+	require.EqualError(t, err, "dkim.conf:6:1: [DKIMCONF0004] parse whitelisted_signers_map: is an array, not a single value")
+	_, err = root.GetInt("whitelist", 0)
+	require.EqualError(t, err, "dkim.conf:8:1: [DKIMCONF0004] parse whitelist: is a block, not a single value")
+
+	// Configurations built in code have no root; defaults apply.
+	var none *Section
+	s, err := none.GetString("selector", "dkim")
+	require.NoError(t, err)
+	require.Equal(t, "dkim", s)
+}
@@ -0,0 +1,310 @@
+package dkimservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+// GraphQLData is what QueryGraphQL serves: the effective signing
+// configuration, the findings of the last audit and the owners of domains.
+type GraphQLData struct {
+	Config   dkim.EffectiveSigningConf
+	Findings []dkim.Finding
+	Owners   *dkim.Owners
+}
+
+// QueryGraphQL runs a read-only GraphQL query against data and returns the
+// value of the response's data member. The schema is
+//
+//	type Query   { domains: [Domain!]! findings: [Finding!]! }
+//	type Domain  { name signingDomain selector keyPath keySource: String
+//	               owners: [String!]! findings: [Finding!]! }
+//	type Finding { domain check code message: String owners: [String!]! }
+//
+// with a domain for each domain rule or map entry, in domain order, and the
+// key fields null for domains Resolve finds no key for. Only a single query
+// of fields, aliases and __typename is supported: arguments, variables,
+// fragments and directives are rejected.
+func QueryGraphQL(query string, data GraphQLData) (json.RawMessage, error) {
+	sel, err := parseGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := executeGraphQL(graphQLRoot(data), sel)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// gqlObject is a value of an object type. Fields holds every field of the
+// type; lists of objects are []*gqlObject.
+type gqlObject struct {
+	typ    string
+	fields map[string]any
+}
+
+func graphQLRoot(data GraphQLData) *gqlObject {
+	findingObject := func(f dkim.Finding) *gqlObject {
+		domain := any(nil)
+		if f.Domain != "" {
+			domain = f.Domain
+		}
+		return &gqlObject{typ: "Finding", fields: map[string]any{
+			"domain":  domain,
+			"check":   f.Check,
+			"code":    string(f.Code),
+			"message": f.Message,
+			"owners":  stringList(data.Owners.Lookup(f.Domain)),
+		}}
+	}
+	findings := make([]*gqlObject, 0, len(data.Findings))
+	byDomain := make(map[string][]*gqlObject)
+	for _, f := range data.Findings {
+		obj := findingObject(f)
+		findings = append(findings, obj)
+		if f.Domain != "" {
+			key := strings.ToLower(strings.TrimPrefix(strings.TrimSuffix(f.Domain, "."), "@"))
+			byDomain[key] = append(byDomain[key], obj)
+		}
+	}
+	domains := make([]*gqlObject, 0)
+	for _, domain := range data.Config.Domains() {
+		obj := &gqlObject{typ: "Domain", fields: map[string]any{
+			"name":          domain,
+			"signingDomain": nil,
+			"selector":      nil,
+			"keyPath":       nil,
+			"keySource":     nil,
+			"owners":        stringList(data.Owners.Lookup(domain)),
+			"findings":      append([]*gqlObject{}, byDomain[domain]...),
+		}}
+		if key, ok := data.Config.Resolve(domain); ok {
+			obj.fields["signingDomain"] = key.Domain
+			obj.fields["selector"] = key.Selector
+			obj.fields["keyPath"] = key.Path
+			obj.fields["keySource"] = key.Source
+		}
+		domains = append(domains, obj)
+	}
+	return &gqlObject{typ: "Query", fields: map[string]any{"domains": domains, "findings": findings}}
+}
+
+func stringList(s []string) []string {
+	return append([]string{}, s...)
+}
+
+// gqlField is a field of a selection set, with its alias if any.
+type gqlField struct {
+	alias string
+	name  string
+	sel   []gqlField
+}
+
+func (f gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// gqlResult is an object of the response, keeping the order of the query.
+type gqlResult []gqlEntry
+
+type gqlEntry struct {
+	key string
+	val any
+}
+
+// MarshalJSON writes the entries in order.
+func (r gqlResult) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, e := range r {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(e.key)
+		b.Write(key)
+		b.WriteByte(':')
+		val, err := json.Marshal(e.val)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func executeGraphQL(obj *gqlObject, sel []gqlField) (gqlResult, error) {
+	out := make(gqlResult, 0, len(sel))
+	seen := make(map[string]bool)
+	for _, f := range sel {
+		if seen[f.key()] {
+			return nil, fmt.Errorf("field %q selected more than once on %s", f.key(), obj.typ)
+		}
+		seen[f.key()] = true
+		if f.name == "__typename" {
+			if f.sel != nil {
+				return nil, fmt.Errorf("field __typename of %s must not have a selection", obj.typ)
+			}
+			out = append(out, gqlEntry{f.key(), obj.typ})
+			continue
+		}
+		val, ok := obj.fields[f.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q on %s", f.name, obj.typ)
+		}
+		switch v := val.(type) {
+		case []*gqlObject:
+			if f.sel == nil {
+				return nil, fmt.Errorf("field %q of %s must have a selection", f.name, obj.typ)
+			}
+			list := make([]gqlResult, 0, len(v))
+			for _, elem := range v {
+				res, err := executeGraphQL(elem, f.sel)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, res)
+			}
+			out = append(out, gqlEntry{f.key(), list})
+		default:
+			if f.sel != nil {
+				return nil, fmt.Errorf("field %q of %s must not have a selection", f.name, obj.typ)
+			}
+			out = append(out, gqlEntry{f.key(), v})
+		}
+	}
+	return out, nil
+}
+
+// gqlParser reads the GraphQL subset QueryGraphQL supports.
+type gqlParser struct {
+	src string
+	pos int
+}
+
+func parseGraphQL(query string) ([]gqlField, error) {
+	p := &gqlParser{src: query}
+	tok := p.peek()
+	if isGraphQLName(tok) {
+		switch p.next() {
+		case "query":
+		case "mutation", "subscription":
+			return nil, errors.New("only queries are supported")
+		default:
+			return nil, p.errorf("unexpected %q", tok)
+		}
+		if isGraphQLName(p.peek()) {
+			p.next()
+		}
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != "" {
+		if tok == "{" || isGraphQLName(tok) {
+			return nil, errors.New("only a single query is supported")
+		}
+		return nil, p.errorf("unexpected %q", tok)
+	}
+	return sel, nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if tok := p.next(); tok != "{" {
+		return nil, p.errorf("expected {, found %q", tok)
+	}
+	var out []gqlField
+	for {
+		tok := p.next()
+		switch {
+		case tok == "}":
+			if len(out) == 0 {
+				return nil, p.errorf("empty selection set")
+			}
+			return out, nil
+		case tok == "...":
+			return nil, p.errorf("fragments are not supported")
+		case !isGraphQLName(tok):
+			return nil, p.errorf("expected field name, found %q", tok)
+		}
+		f := gqlField{name: tok}
+		if p.peek() == ":" {
+			p.next()
+			name := p.next()
+			if !isGraphQLName(name) {
+				return nil, p.errorf("expected field name, found %q", name)
+			}
+			f.alias, f.name = f.name, name
+		}
+		switch p.peek() {
+		case "(":
+			return nil, p.errorf("arguments are not supported")
+		case "@":
+			return nil, p.errorf("directives are not supported")
+		case "{":
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			f.sel = sel
+		}
+		out = append(out, f)
+	}
+}
+
+// next returns the next token, or "" at the end of the query. Commas are
+// insignificant, as in GraphQL.
+func (p *gqlParser) next() string {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "..."):
+			p.pos += 3
+			return "..."
+		case isGraphQLNameByte(c, true):
+			start := p.pos
+			for p.pos < len(p.src) && isGraphQLNameByte(p.src[p.pos], false) {
+				p.pos++
+			}
+			return p.src[start:p.pos]
+		default:
+			p.pos++
+			return string(c)
+		}
+	}
+	return ""
+}
+
+func (p *gqlParser) peek() string {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+func (p *gqlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func isGraphQLName(tok string) bool {
+	return tok != "" && isGraphQLNameByte(tok[0], true)
+}
+
+func isGraphQLNameByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
@@ -0,0 +1,66 @@
+//go:build !dkimconf_nohttp
+
+package dkimservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// GraphQLHandler serves QueryGraphQL over HTTP, taking the query from the
+// query parameter of a GET or the JSON body of a POST, as
+// {"query": "..."}. Load is called for every request, so the answers
+// follow the configuration as it changes. Errors are returned in the
+// response's errors member.
+type GraphQLHandler struct {
+	Load func(ctx context.Context) (GraphQLData, error)
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []graphQLError  `json:"errors,omitempty"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+// ServeHTTP answers one query.
+func (h GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var query string
+	switch r.Method {
+	case http.MethodGet:
+		query = r.URL.Query().Get("query")
+	case http.MethodPost:
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeGraphQL(w, http.StatusBadRequest, graphQLResponse{Errors: []graphQLError{{"invalid request body: " + err.Error()}}})
+			return
+		}
+		query = req.Query
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := h.Load(r.Context())
+	if err != nil {
+		writeGraphQL(w, http.StatusInternalServerError, graphQLResponse{Errors: []graphQLError{{err.Error()}}})
+		return
+	}
+	res, err := QueryGraphQL(query, data)
+	if err != nil {
+		writeGraphQL(w, http.StatusOK, graphQLResponse{Errors: []graphQLError{{err.Error()}}})
+		return
+	}
+	writeGraphQL(w, http.StatusOK, graphQLResponse{Data: res})
+}
+
+func writeGraphQL(w http.ResponseWriter, status int, resp graphQLResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
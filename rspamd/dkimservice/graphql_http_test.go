@@ -0,0 +1,57 @@
+//go:build !dkimconf_nohttp
+
+package dkimservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLHandler(t *testing.T) {
+	data := graphQLTestData(t)
+	var loadErr error
+	srv := httptest.NewServer(GraphQLHandler{Load: func(context.Context) (GraphQLData, error) { return data, loadErr }})
+	defer srv.Close()
+
+	do := func(resp *http.Response, err error) (int, string) {
+		t.Helper()
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, strings.TrimSpace(string(body))
+	}
+
+	status, body := do(http.Get(srv.URL + "?query=" + url.QueryEscape("{ domains { name } }")))
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `{"data":{"domains":[{"name":"a.com"},{"name":"b.com"},{"name":"c.com"}]}}`, body)
+
+	status, body = do(http.Post(srv.URL, "application/json", strings.NewReader(`{"query": "{ findings { code } }"}`)))
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `{"data":{"findings":[{"code":"X1"},{"code":"X2"}]}}`, body)
+
+	status, body = do(http.Post(srv.URL, "application/json", strings.NewReader(`{"query": "{ nope }"}`)))
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `{"errors":[{"message":"unknown field \"nope\" on Query"}]}`, body)
+
+	status, _ = do(http.Post(srv.URL, "application/json", strings.NewReader(`{`)))
+	require.Equal(t, http.StatusBadRequest, status)
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	require.NoError(t, err)
+	status, _ = do(http.DefaultClient.Do(req))
+	require.Equal(t, http.StatusMethodNotAllowed, status)
+
+	loadErr = errors.New("config unavailable")
+	status, body = do(http.Get(srv.URL + "?query=" + url.QueryEscape("{ domains { name } }")))
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, `{"errors":[{"message":"config unavailable"}]}`, body)
+}
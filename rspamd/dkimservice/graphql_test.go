@@ -0,0 +1,77 @@
+package dkimservice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+	"github.com/stretchr/testify/require"
+)
+
+func graphQLTestData(t *testing.T) GraphQLData {
+	t.Helper()
+	no := false
+	owners, err := dkim.ParseOwners(strings.NewReader("* @mail\nb.com @esp-team\n"))
+	require.NoError(t, err)
+	return GraphQLData{
+		Config: dkim.EffectiveSigningConf{Conf: &dkim.DKIMSigningConf{
+			TryFallback: &no,
+			Domain: map[string]dkim.DomainRule{
+				"a.com": {Selector: "s1", Path: "/keys/a.key"},
+				"b.com": {Selector: "esp", Path: "/keys/esp.key", SigningDomain: "esp.net"},
+			},
+		}, Maps: &dkim.Maps{Selectors: map[string]string{"c.com": "s2"}}},
+		Findings: []dkim.Finding{
+			{Domain: "B.com", Check: "dns", Code: "X1", Message: "key not published"},
+			{Check: "modules", Code: "X2", Message: "global"},
+		},
+		Owners: owners,
+	}
+}
+
+func TestQueryGraphQL(t *testing.T) {
+	data := graphQLTestData(t)
+	res, err := QueryGraphQL(`
+# domains with findings and their owners
+query Dashboard {
+  domains { name, d: signingDomain selector owners findings { check message } }
+  findings { __typename domain owners }
+}`, data)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "domains": [
+    {"name": "a.com", "d": "a.com", "selector": "s1", "owners": ["@mail"], "findings": []},
+    {"name": "b.com", "d": "esp.net", "selector": "esp", "owners": ["@esp-team"], "findings": [{"check": "dns", "message": "key not published"}]},
+    {"name": "c.com", "d": null, "selector": null, "owners": ["@mail"], "findings": []}
+  ],
+  "findings": [
+    {"__typename": "Finding", "domain": "B.com", "owners": ["@esp-team"]},
+    {"__typename": "Finding", "domain": null, "owners": ["@mail"]}
+  ]
+}`, string(res))
+	require.True(t, strings.HasPrefix(string(res), `{"domains":[{"name":"a.com","d":"a.com",`), string(res))
+
+	res, err = QueryGraphQL(`{ findings { code } }`, GraphQLData{})
+	require.NoError(t, err)
+	require.Equal(t, `{"findings":[]}`, string(res))
+}
+
+func TestQueryGraphQLErrors(t *testing.T) {
+	data := graphQLTestData(t)
+	for query, msg := range map[string]string{
+		`mutation { domains { name } }`:              "only queries are supported",
+		`{ domains { nme } }`:                        `unknown field "nme" on Domain`,
+		`{ domains }`:                                `field "domains" of Query must have a selection`,
+		`{ domains { name { x } } }`:                 `field "name" of Domain must not have a selection`,
+		`{ domains(name: "a.com") { name } }`:        "arguments are not supported",
+		`{ domains { ...F } }`:                       "fragments are not supported",
+		`{ domains { name @skip } }`:                 "directives are not supported",
+		`{ domains { name name } }`:                  `field "name" selected more than once on Domain`,
+		`{ domains { name } } { findings { code } }`: "only a single query is supported",
+		`{ domains { name }`:                         `expected field name, found ""`,
+		`{ }`:                                        "empty selection set",
+	} {
+		_, err := QueryGraphQL(query, data)
+		require.ErrorContains(t, err, msg, query)
+	}
+}
@@ -0,0 +1,108 @@
+package dkimservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+// Notification is a message for the people on call, such as drift found by
+// a Reconciler. DedupKey identifies the problem, so that channels that
+// support it, such as PagerDuty, group repeats into one incident.
+type Notification struct {
+	Summary  string
+	Details  string
+	Severity dkim.Severity
+	Source   string
+	DedupKey string
+}
+
+// Notifier sends notifications to a channel such as email, a chat webhook
+// or a paging service.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Notifiers sends every notification to each of its notifiers, returning
+// the errors of those that failed.
+type Notifiers []Notifier
+
+// Notify sends n to every notifier.
+func (ns Notifiers) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, notifier := range ns {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SMTPNotifier sends notifications by email through the server at Addr
+// (host:port). Auth may be nil for servers that accept mail without it.
+type SMTPNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Notify sends n as a plain text email. The context is not used, as
+// net/smtp does not support one.
+func (s SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, s.To, s.message(n, time.Now())); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+func (s SMTPNotifier) message(n Notification, now time.Time) []byte {
+	var b strings.Builder
+	header := func(key, val string) {
+		b.WriteString(key + ": " + strings.NewReplacer("\r", " ", "\n", " ").Replace(val) + "\r\n")
+	}
+	header("From", s.From)
+	header("To", strings.Join(s.To, ", "))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Subject", fmt.Sprintf("[%s] %s", n.Severity, n.Summary))
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+	body := n.Details
+	if n.Source != "" {
+		body = strings.TrimRight(body, "\n") + "\n\nSource: " + n.Source + "\n"
+	}
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// driftNotification describes the result of a reconciliation, or returns
+// false if there is nothing to report.
+func driftNotification(host string, drift Drift, err error) (Notification, bool) {
+	n := Notification{Source: host, DedupKey: "dkimconf-drift-" + host}
+	switch {
+	case err != nil:
+		n.Summary = "reconciling DKIM config failed"
+		n.Details = err.Error()
+		n.Severity = dkim.SeverityError
+	case len(drift.Changes) > 0:
+		var names []string
+		for _, c := range drift.Changes {
+			names = append(names, c.Name)
+		}
+		n.Details = strings.Join(names, "\n") + "\n"
+		n.Severity = dkim.SeverityWarning
+		n.Summary = fmt.Sprintf("DKIM config drift: %d files differ", len(names))
+		if drift.Applied {
+			n.Summary = fmt.Sprintf("DKIM config drift corrected: %d files written", len(names))
+			n.Severity = dkim.SeverityNote
+		}
+	default:
+		return Notification{}, false
+	}
+	return n, true
+}
@@ -0,0 +1,101 @@
+//go:build !dkimconf_nohttp
+
+package dkimservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+// WebhookNotifier posts notifications to a Slack-compatible incoming
+// webhook as {"text": ...}, which Slack, Mattermost and Rocket.Chat accept.
+// A nil Client means http.DefaultClient.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify posts n to the webhook.
+func (w WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	text := fmt.Sprintf("[%s] %s", n.Severity, n.Summary)
+	if n.Source != "" {
+		text += " (" + n.Source + ")"
+	}
+	if n.Details != "" {
+		text += "\n```\n" + n.Details + "```"
+	}
+	return postJSON(ctx, w.Client, w.URL, map[string]string{"text": text})
+}
+
+// PagerDutyEventsURL is the endpoint of the PagerDuty Events API v2.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier triggers PagerDuty incidents through the Events API v2
+// with the integration's RoutingKey. URL defaults to PagerDutyEventsURL and
+// a nil Client means http.DefaultClient.
+type PagerDutyNotifier struct {
+	RoutingKey string
+	URL        string
+	Client     *http.Client
+}
+
+// Notify triggers an event for n, deduplicated by n.DedupKey.
+func (p PagerDutyNotifier) Notify(ctx context.Context, n Notification) error {
+	url := p.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+	severity := map[dkim.Severity]string{dkim.SeverityError: "error", dkim.SeverityWarning: "warning"}[n.Severity]
+	if severity == "" {
+		severity = "info"
+	}
+	source := n.Source
+	if source == "" {
+		source = "dkimconf"
+	}
+	event := map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"payload": map[string]any{
+			"summary":        n.Summary,
+			"source":         source,
+			"severity":       severity,
+			"custom_details": map[string]string{"details": n.Details},
+		},
+	}
+	if n.DedupKey != "" {
+		event["dedup_key"] = n.DedupKey
+	}
+	return postJSON(ctx, p.Client, url, event)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
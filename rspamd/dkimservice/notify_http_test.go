@@ -0,0 +1,51 @@
+//go:build !dkimconf_nohttp
+
+package dkimservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPNotifiers(t *testing.T) {
+	var got map[string]any
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		got = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	n := Notification{Summary: "drift", Details: "a.conf\n", Severity: dkim.SeverityWarning, Source: "/etc/rspamd", DedupKey: "k"}
+
+	require.NoError(t, WebhookNotifier{URL: srv.URL}.Notify(context.Background(), n))
+	require.Equal(t, map[string]any{"text": "[warning] drift (/etc/rspamd)\n```\na.conf\n```"}, got)
+
+	require.NoError(t, PagerDutyNotifier{RoutingKey: "R", URL: srv.URL}.Notify(context.Background(), n))
+	require.Equal(t, map[string]any{
+		"routing_key":  "R",
+		"event_action": "trigger",
+		"dedup_key":    "k",
+		"payload": map[string]any{
+			"summary":        "drift",
+			"source":         "/etc/rspamd",
+			"severity":       "warning",
+			"custom_details": map[string]any{"details": "a.conf\n"},
+		},
+	}, got)
+
+	n.Severity = dkim.SeverityNote
+	require.NoError(t, PagerDutyNotifier{URL: srv.URL}.Notify(context.Background(), n))
+	require.Equal(t, "info", got["payload"].(map[string]any)["severity"])
+
+	status = http.StatusBadRequest
+	require.ErrorContains(t, WebhookNotifier{URL: srv.URL}.Notify(context.Background(), n), "400 Bad Request")
+}
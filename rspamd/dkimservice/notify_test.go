@@ -0,0 +1,83 @@
+package dkimservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+	"github.com/stretchr/testify/require"
+)
+
+type notifierFunc func(context.Context, Notification) error
+
+func (f notifierFunc) Notify(ctx context.Context, n Notification) error { return f(ctx, n) }
+
+func TestSMTPNotifierMessage(t *testing.T) {
+	s := SMTPNotifier{From: "dkim@example.com", To: []string{"a@example.com", "b@example.com"}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	msg := string(s.message(Notification{Summary: "drift\r\nBcc: x@evil", Details: "a.conf\nb.conf\n", Severity: dkim.SeverityWarning, Source: "/etc/rspamd"}, now))
+	require.Equal(t, "From: dkim@example.com\r\n"+
+		"To: a@example.com, b@example.com\r\n"+
+		"Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n"+
+		"Subject: [warning] drift  Bcc: x@evil\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"\r\n"+
+		"a.conf\r\nb.conf\r\n\r\nSource: /etc/rspamd\r\n", msg)
+}
+
+func TestNotifiers(t *testing.T) {
+	var got []string
+	ok := notifierFunc(func(_ context.Context, n Notification) error {
+		got = append(got, n.Summary)
+		return nil
+	})
+	failing := notifierFunc(func(context.Context, Notification) error { return errors.New("down") })
+	err := Notifiers{failing, ok}.Notify(context.Background(), Notification{Summary: "hi"})
+	require.EqualError(t, err, "down")
+	require.Equal(t, []string{"hi"}, got)
+	require.NoError(t, Notifiers{ok}.Notify(context.Background(), Notification{}))
+}
+
+func TestDriftNotification(t *testing.T) {
+	_, ok := driftNotification("/h", Drift{}, nil)
+	require.False(t, ok)
+
+	n, ok := driftNotification("/h", Drift{Changes: []dkim.FileChange{{Name: "a.conf"}, {Name: "b.conf"}}}, nil)
+	require.True(t, ok)
+	require.Equal(t, Notification{Summary: "DKIM config drift: 2 files differ", Details: "a.conf\nb.conf\n", Severity: dkim.SeverityWarning, Source: "/h", DedupKey: "dkimconf-drift-/h"}, n)
+
+	n, _ = driftNotification("/h", Drift{Changes: []dkim.FileChange{{Name: "a.conf"}}, Applied: true}, nil)
+	require.Equal(t, dkim.SeverityNote, n.Severity)
+	require.Equal(t, "DKIM config drift corrected: 1 files written", n.Summary)
+
+	n, _ = driftNotification("/h", Drift{}, errors.New("boom"))
+	require.Equal(t, dkim.SeverityError, n.Severity)
+	require.Equal(t, "boom", n.Details)
+}
+
+func TestReconcilerRunNotifies(t *testing.T) {
+	desired := writeFiles(t, map[string]string{"a.conf": "x"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var sent []Notification
+	var results []error
+	r := &Reconciler{
+		Desired: DirSource(desired),
+		Host:    t.TempDir(),
+		Notifier: notifierFunc(func(_ context.Context, n Notification) error {
+			sent = append(sent, n)
+			return errors.New("webhook down")
+		}),
+		OnResult: func(_ Drift, err error) {
+			results = append(results, err)
+			cancel()
+		},
+	}
+	require.ErrorIs(t, r.Run(ctx), context.Canceled)
+	require.Len(t, sent, 1)
+	require.True(t, strings.HasPrefix(sent[0].Summary, "DKIM config drift"))
+	require.EqualError(t, results[0], "notify: webhook down")
+}
@@ -0,0 +1,156 @@
+// Package dkimservice holds the long-running parts built on package dkim:
+// a Reconciler keeping hosts in line with a desired configuration, the
+// notifiers it reports to and a GraphQL endpoint over the configuration.
+// They live apart so that users of the parser do not pull them in.
+package dkimservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+// Source provides the desired configuration files, such as a git checkout
+// or an archive served over HTTP.
+type Source interface {
+	Fetch(ctx context.Context) (fs.FS, error)
+}
+
+// DirSource is a Source reading a local directory, such as a git checkout
+// kept up to date by another process.
+type DirSource string
+
+// Fetch returns the directory as a filesystem.
+func (d DirSource) Fetch(ctx context.Context) (fs.FS, error) {
+	if _, err := os.Stat(string(d)); err != nil {
+		return nil, err
+	}
+	return os.DirFS(string(d)), nil
+}
+
+// Drift is the difference between the desired files and those on the host.
+// Changes are the files to write for the host to match; Applied is set when
+// they were written.
+type Drift struct {
+	Changes []dkim.FileChange
+	Applied bool
+}
+
+// Reconciler compares the files of Desired with those below Host and
+// reports the difference or, with Enforce set, writes it to Sink. Files on
+// the host that Desired does not have are left alone, and hidden files and
+// directories of Desired, such as .git, are skipped.
+type Reconciler struct {
+	Desired Source
+	Host    string
+
+	// Sink receives the changes in enforce mode; nil means
+	// dkim.DirSink(Host).
+	Sink    dkim.Sink
+	Enforce bool
+	// Hooks are run before changes are applied, see dkim.ApplyChangeSet.
+	Hooks []dkim.ApplyHook
+
+	// Interval is the time between runs of Run; zero means one minute.
+	Interval time.Duration
+	// OnResult, if set, is called by Run after every reconciliation.
+	OnResult func(Drift, error)
+	// Notifier, if set, is told by Run about drift and failures. Failures
+	// to notify are passed to OnResult.
+	Notifier Notifier
+}
+
+// Reconcile compares the desired files with the host once and, in enforce
+// mode, applies the drift found.
+func (r *Reconciler) Reconcile(ctx context.Context) (Drift, error) {
+	desired, err := r.Desired.Fetch(ctx)
+	if err != nil {
+		return Drift{}, fmt.Errorf("fetch desired config: %w", err)
+	}
+	var drift Drift
+	err = fs.WalkDir(desired, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		want, err := fs.ReadFile(desired, name)
+		if err != nil {
+			return err
+		}
+		have, err := os.ReadFile(filepath.Join(r.Host, filepath.FromSlash(name)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err != nil || !bytes.Equal(want, have) {
+			c := dkim.FileChange{Name: name, Data: want}
+			if info, err := d.Info(); err == nil {
+				c.Perm = info.Mode().Perm()
+			}
+			drift.Changes = append(drift.Changes, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return Drift{}, err
+	}
+	if !r.Enforce || len(drift.Changes) == 0 {
+		return drift, nil
+	}
+	sink := r.Sink
+	if sink == nil {
+		sink = dkim.DirSink(r.Host)
+	}
+	if _, err := dkim.ApplyChangeSet(sink, dkim.ChangeSet{Changes: drift.Changes}, r.Hooks...); err != nil {
+		return drift, err
+	}
+	drift.Applied = true
+	return drift, nil
+}
+
+// Run reconciles every Interval until ctx is done, passing each result to
+// Notifier and OnResult. It returns the error of ctx.
+func (r *Reconciler) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		drift, err := r.Reconcile(ctx)
+		if ctx.Err() == nil {
+			if n, ok := driftNotification(r.Host, drift, err); ok && r.Notifier != nil {
+				if nerr := r.Notifier.Notify(ctx, n); nerr != nil {
+					err = errors.Join(err, fmt.Errorf("notify: %w", nerr))
+				}
+			}
+			if r.OnResult != nil {
+				r.OnResult(drift, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
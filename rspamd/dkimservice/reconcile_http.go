@@ -0,0 +1,53 @@
+//go:build !dkimconf_nohttp
+
+package dkimservice
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+)
+
+// HTTPSource is a Source fetching an archive of the desired configuration
+// from URL, in a format dkim.ReadArchive understands by the URL's
+// extension.
+// Header is added to the request. A nil Client means http.DefaultClient.
+// Limits bounds the archive as unpacked; zero means dkim.UntrustedLimits.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+	Header http.Header
+	Limits dkim.Limits
+}
+
+// Fetch downloads and reads the archive.
+func (s HTTPSource) Fetch(ctx context.Context) (fs.FS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, vals := range s.Header {
+		req.Header[key] = append([]string(nil), vals...)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	limits := s.Limits
+	if limits == (dkim.Limits{}) {
+		limits = dkim.UntrustedLimits
+	}
+	return dkim.ReadArchive(resp.Body, path.Base(req.URL.Path), dkim.WithLimits(limits))
+}
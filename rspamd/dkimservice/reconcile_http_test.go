@@ -0,0 +1,46 @@
+//go:build !dkimconf_nohttp && !dkimconf_noarchive
+
+package dkimservice
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSource(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("local.d/dkim_signing.conf")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`selector = "s2";`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/desired.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	r := &Reconciler{Desired: HTTPSource{URL: srv.URL + "/desired.zip"}, Host: t.TempDir()}
+	drift, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Len(t, drift.Changes, 1)
+	require.Equal(t, `selector = "s2";`, string(drift.Changes[0].Data))
+
+	_, err = HTTPSource{URL: srv.URL + "/desired.zip", Limits: dkim.Limits{InputSize: 8}}.Fetch(context.Background())
+	require.ErrorIs(t, err, dkim.ErrLimitExceeded)
+
+	_, err = HTTPSource{URL: srv.URL + "/missing.zip"}.Fetch(context.Background())
+	require.EqualError(t, err, "GET "+srv.URL+"/missing.zip: 404 Not Found")
+}
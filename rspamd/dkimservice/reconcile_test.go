@@ -0,0 +1,127 @@
+package dkimservice
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/littlebugger/dkim.conf/rspamd/dkim"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestReconcile(t *testing.T) {
+	desired := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": `selector = "s2";`,
+		"maps.d/selectors.map":      "a.com s2\n",
+	})
+	host := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": `selector = "s1";`,
+		"local.d/other.conf":        `x = 1;`,
+	})
+
+	r := &Reconciler{Desired: DirSource(desired), Host: host}
+	drift, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.False(t, drift.Applied)
+	require.Len(t, drift.Changes, 2)
+	require.Equal(t, "local.d/dkim_signing.conf", drift.Changes[0].Name)
+	require.Equal(t, "maps.d/selectors.map", drift.Changes[1].Name)
+	data, err := os.ReadFile(filepath.Join(host, "local.d", "dkim_signing.conf"))
+	require.NoError(t, err)
+	require.Equal(t, `selector = "s1";`, string(data))
+
+	r.Enforce = true
+	r.Hooks = []dkim.ApplyHook{dkim.RequireTicket(regexp.MustCompile(`.`))}
+	_, err = r.Reconcile(context.Background())
+	require.ErrorIs(t, err, dkim.ErrChangeRejected)
+
+	r.Hooks = nil
+	drift, err = r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.True(t, drift.Applied)
+	data, err = os.ReadFile(filepath.Join(host, "maps.d", "selectors.map"))
+	require.NoError(t, err)
+	require.Equal(t, "a.com s2\n", string(data))
+	require.FileExists(t, filepath.Join(host, "local.d", "other.conf"))
+
+	drift, err = r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, drift.Changes)
+
+	_, err = (&Reconciler{Desired: DirSource(filepath.Join(desired, "missing")), Host: host}).Reconcile(context.Background())
+	require.ErrorContains(t, err, "fetch desired config")
+}
+
+func TestReconcileReadOnly(t *testing.T) {
+	desired := writeFiles(t, map[string]string{"local.d/dkim_signing.conf": `selector = "s2";`})
+	host := t.TempDir()
+	dkim.SetReadOnly(true)
+	defer dkim.SetReadOnly(false)
+
+	r := &Reconciler{Desired: DirSource(desired), Host: host}
+	drift, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Len(t, drift.Changes, 1)
+
+	r.Enforce = true
+	_, err = r.Reconcile(context.Background())
+	require.ErrorIs(t, err, dkim.ErrReadOnly)
+	entries, err := os.ReadDir(host)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestReconcileSkipsHidden(t *testing.T) {
+	desired := writeFiles(t, map[string]string{
+		"local.d/dkim_signing.conf": `selector = "s2";`,
+		".git/config":               "[core]\n",
+		".git/objects/ab/cdef":      "blob",
+		".hg/hgrc":                  "[paths]\n",
+		".svn/entries":              "12\n",
+		".gitignore":                "*.key\n",
+		"local.d/.cache/state":      "x",
+	})
+	host := t.TempDir()
+
+	r := &Reconciler{Desired: DirSource(desired), Host: host, Enforce: true}
+	drift, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Len(t, drift.Changes, 1)
+	require.Equal(t, "local.d/dkim_signing.conf", drift.Changes[0].Name)
+	for _, name := range []string{".git", ".hg", ".svn", ".gitignore", "local.d/.cache"} {
+		_, err := os.Stat(filepath.Join(host, name))
+		require.ErrorIs(t, err, fs.ErrNotExist, name)
+	}
+}
+
+func TestReconcilerRun(t *testing.T) {
+	desired := writeFiles(t, map[string]string{"a.conf": "x"})
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	r := &Reconciler{Desired: DirSource(desired), Host: t.TempDir(), Interval: time.Millisecond,
+		OnResult: func(d Drift, err error) {
+			require.NoError(t, err)
+			if runs++; runs == 2 {
+				cancel()
+			}
+		}}
+	err := r.Run(ctx)
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Equal(t, 2, runs)
+}
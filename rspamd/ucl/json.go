@@ -0,0 +1,142 @@
+package ucl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MarshalJSON writes v as JSON. Objects keep their key order and Time values
+// become a number of seconds, the unit UCL uses for plain numbers. Comments
+// and positions are not written.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	if err := v.writeJSON(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (v *Value) writeJSON(b *bytes.Buffer) error {
+	if v == nil {
+		b.WriteString("null")
+		return nil
+	}
+	switch v.Kind {
+	case Object:
+		b.WriteByte('{')
+		for i, key := range v.Keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			b.Write(k)
+			b.WriteByte(':')
+			if err := v.Fields[key].writeJSON(b); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case Array:
+		b.WriteByte('[')
+		for i, elem := range v.Elems {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := elem.writeJSON(b); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case String:
+		s, _ := json.Marshal(v.Raw)
+		b.Write(s)
+	case Int:
+		b.WriteString(strconv.FormatInt(v.Int, 10))
+	case Float:
+		b.WriteString(strconv.FormatFloat(v.Float, 'g', -1, 64))
+	case Bool:
+		b.WriteString(strconv.FormatBool(v.Bool))
+	case Time:
+		b.WriteString(strconv.FormatFloat(v.Time.Seconds(), 'g', -1, 64))
+	case Null:
+		b.WriteString("null")
+	default:
+		return fmt.Errorf("ucl: cannot write %v as JSON", v.Kind)
+	}
+	return nil
+}
+
+// UnmarshalJSON reads a JSON document into v, keeping the order of object
+// keys. Whole numbers become Int values and other numbers Float values. Raw
+// holds the scalar text as written in the JSON.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	val, err := readJSON(dec)
+	if err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("ucl: unexpected data after JSON value")
+	}
+	*v = *val
+	return nil
+}
+
+func readJSON(dec *json.Decoder) (*Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			v := &Value{Kind: Array}
+			for dec.More() {
+				elem, err := readJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				v.Elems = append(v.Elems, elem)
+			}
+			_, err := dec.Token()
+			return v, err
+		}
+		v := &Value{Kind: Object, Fields: make(map[string]*Value)}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			field, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := v.Fields[key]; !ok {
+				v.Keys = append(v.Keys, key)
+			}
+			v.Fields[key] = field
+		}
+		_, err := dec.Token()
+		return v, err
+	case string:
+		return &Value{Kind: String, Raw: t}, nil
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return &Value{Kind: Int, Raw: t.String(), Int: n}, nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("ucl: invalid number %s", t)
+		}
+		return &Value{Kind: Float, Raw: t.String(), Float: f}, nil
+	case bool:
+		return &Value{Kind: Bool, Raw: strconv.FormatBool(t), Bool: t}, nil
+	default:
+		return &Value{Kind: Null, Raw: "null"}, nil
+	}
+}
@@ -0,0 +1,53 @@
+package ucl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueMarshalJSON(t *testing.T) {
+	v := &Value{
+		Kind: Object,
+		Keys: []string{"selector", "limits"},
+		Fields: map[string]*Value{
+			"selector": {Kind: String, Raw: "s\"1"},
+			"limits": {Kind: Object, Keys: []string{"size", "list"}, Fields: map[string]*Value{
+				"size": {Kind: Int, Raw: "10k", Int: 10240},
+				"list": {Kind: Array, Elems: []*Value{
+					{Kind: Float, Raw: "1.5", Float: 1.5},
+					{Kind: Bool, Raw: "yes", Bool: true},
+					{Kind: Time, Raw: "2min", Time: 2 * time.Minute},
+					{Kind: Null, Raw: "null"},
+				}},
+			}},
+		},
+	}
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, `{"selector":"s\"1","limits":{"size":10240,"list":[1.5,true,120,null]}}`, string(out))
+}
+
+func TestValueUnmarshalJSON(t *testing.T) {
+	var v Value
+	require.NoError(t, json.Unmarshal([]byte(`{"b": [1, 2.5, "x", false, null], "a": {"n": -3}, "b2": 1e3}`), &v))
+	require.Equal(t, []string{"b", "a", "b2"}, v.Keys)
+	list := v.Get("b")
+	require.Equal(t, Array, list.Kind)
+	require.Equal(t, &Value{Kind: Int, Raw: "1", Int: 1}, list.Elems[0])
+	require.Equal(t, &Value{Kind: Float, Raw: "2.5", Float: 2.5}, list.Elems[1])
+	require.Equal(t, &Value{Kind: String, Raw: "x"}, list.Elems[2])
+	require.Equal(t, &Value{Kind: Bool, Raw: "false"}, list.Elems[3])
+	require.Equal(t, Null, list.Elems[4].Kind)
+	require.Equal(t, int64(-3), v.Get("a", "n").Int)
+	require.Equal(t, &Value{Kind: Float, Raw: "1e3", Float: 1000}, v.Get("b2"))
+
+	out, err := json.Marshal(&v)
+	require.NoError(t, err)
+	require.Equal(t, `{"b":[1,2.5,"x",false,null],"a":{"n":-3},"b2":1000}`, string(out))
+
+	require.Error(t, json.Unmarshal([]byte(`{"a": 1`), &v))
+	require.Error(t, (&v).UnmarshalJSON([]byte(`{} {}`)))
+}
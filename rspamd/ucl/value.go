@@ -0,0 +1,91 @@
+// Package ucl is a generic object model for UCL documents such as the rspamd
+// configuration files. Values are produced by dkim.Parse.
+package ucl
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kind is the type of a Value.
+type Kind int
+
+const (
+	Null Kind = iota
+	Object
+	Array
+	String
+	Int
+	Float
+	Bool
+	Time
+)
+
+var kindNames = map[Kind]string{
+	Null:   "null",
+	Object: "object",
+	Array:  "array",
+	String: "string",
+	Int:    "int",
+	Float:  "float",
+	Bool:   "bool",
+	Time:   "time",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Position is where a value appears in its source. Line and Column are
+// 1-based; Column counts characters.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Value is a node of a UCL document. Objects keep their keys in the order
+// they first appeared. Scalars keep the text as written in Raw, without
+// quotes, and the interpreted value in the field matching Kind: Int for
+// integers and sizes such as 10k, Float, Bool, or Time for durations such as
+// 10s. Quoted scalars are always of Kind String.
+type Value struct {
+	Kind Kind
+	Pos  Position
+	// Comments are the comments written before the key of this value, with
+	// their markers.
+	Comments []string
+
+	Keys   []string
+	Fields map[string]*Value
+	Elems  []*Value
+
+	Raw   string
+	Int   int64
+	Float float64
+	Bool  bool
+	Time  time.Duration
+}
+
+// Get follows path through nested objects and returns the value found, or
+// nil if any step is missing or not an object. Get on a nil Value returns
+// nil.
+func (v *Value) Get(path ...string) *Value {
+	for _, key := range path {
+		if v == nil || v.Kind != Object {
+			return nil
+		}
+		v = v.Fields[key]
+	}
+	return v
+}
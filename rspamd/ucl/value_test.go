@@ -0,0 +1,34 @@
+package ucl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueGet(t *testing.T) {
+	leaf := &Value{Kind: String, Raw: "s1"}
+	root := &Value{
+		Kind: Object,
+		Keys: []string{"domain"},
+		Fields: map[string]*Value{
+			"domain": {
+				Kind:   Object,
+				Keys:   []string{"a.com"},
+				Fields: map[string]*Value{"a.com": {Kind: Object, Keys: []string{"selector"}, Fields: map[string]*Value{"selector": leaf}}},
+			},
+		},
+	}
+	require.Same(t, leaf, root.Get("domain", "a.com", "selector"))
+	require.Nil(t, root.Get("domain", "b.com", "selector"))
+	require.Nil(t, root.Get("domain", "a.com", "selector", "x"))
+	require.Same(t, root, root.Get())
+	require.Nil(t, (*Value)(nil).Get("x"))
+}
+
+func TestKindAndPositionString(t *testing.T) {
+	require.Equal(t, "time", Time.String())
+	require.Equal(t, "Kind(42)", Kind(42).String())
+	require.Equal(t, "a.conf:3:7", Position{File: "a.conf", Line: 3, Column: 7}.String())
+	require.Equal(t, "3:7", Position{Line: 3, Column: 7}.String())
+}